@@ -8,99 +8,86 @@ import (
 	"log"
 	"os"
 	"os/exec"
-	"strings"
-
-	"github.com/revrost/go-openrouter"
 )
 
-func Run1Agent1UserTest() {
-	zRspStart := "Z_RSP_START"
-	zRspEnd := "Z_RSP_END"
-	zRspFormat := "python code"
-	zRspFormatPrompt := fmt.Sprintf("exact string %s, right after that valid %s, right after that exact string %s", zRspStart, zRspFormat, zRspEnd)
-
-	fmt.Printf("zRspFormatPrompt=%s\n", zRspFormatPrompt)
-
-	llmSystemPrompt := fmt.Sprintf(
-		`
-You are coding assistant (named Z_AI).
-
-Z_AI can only give an answer (named Z_RSP, format of which is described below).
-
-Z_AI answer contains only text, strictly compatible with Z_RSP.
-
-Z_RSP format is completely defined by Z_RSP_FORMAT.
+// testgenFormat is the Z_RSP format the test writer answers in.
+const testgenFormat = "python code"
 
-Z_RSP_FORMAT is placed right between words Z_RSP_FORM_START and Z_RSP_FORM_END.
-
-Z_RSP_FORM_START
-%s
-Z_RSP_FORM_END
-`,
-		zRspFormatPrompt)
+// Run1Agent1UserTest asks the model for pytest tests of srcPath, nil prompts uses the embedded ones.
+func Run1Agent1UserTest(ctx context.Context, model ChatModel, prompts *Prompts, srcPath, outPath string) error {
+	if prompts == nil {
+		prompts = DefaultPrompts()
+	}
+	data := TestgenPromptData{Markers: defaultPromptMarkers, Format: testgenFormat}
+	llmSystemPrompt := prompts.mustRender(PromptTestgenSystem, data)
 
 	llmSystemPromptEscaped, err := json.Marshal(llmSystemPrompt)
 	if err != nil {
-		log.Fatalf("failed to marshal text tp json string: %v", err)
+		return fmt.Errorf("failed to marshal text tp json string: %w", err)
 	}
 
 	fmt.Printf("basicPrompt=%s\n", llmSystemPrompt)
 
-	codeToTest, err := readFileToString("function_python.py")
+	codeToTest, err := readFileToString(srcPath)
 	if err != nil {
-		log.Fatalf("failed to read code: %v", err)
+		return fmt.Errorf("failed to read code: %w", err)
 	}
 
-	llmUserPromptStr := strings.TrimSpace("write test for the python code below") + "\n" + codeToTest
+	data.Code = codeToTest
+	llmUserPromptStr := prompts.mustRender(PromptTestgenUser, data)
 
 	llmUserPromptEscaped, err := json.Marshal(llmUserPromptStr)
 	if err != nil {
-		log.Fatalf("failed to marshal text tp json string: %v", err)
+		return fmt.Errorf("failed to marshal text tp json string: %w", err)
 	}
 
-	llmToken := os.Getenv("OPENROUTER_API_KEY")
-	if llmToken == "" {
-		log.Fatal("export OPENROUTER_API_KEY first")
+	if model == nil {
+		if model, err = NewChatModel(ChatModelConfigFromEnv(usageAgentTestgen, defaultTestgenModel)); err != nil {
+			return err
+		}
 	}
-	llmClient := openrouter.NewClient(llmToken)
-
-	resp, err := llmClient.CreateChatCompletion(
-		context.Background(),
-		openrouter.ChatCompletionRequest{
-			Model: "moonshotai/kimi-k2:free",
-			//Model: "deepseek/deepseek-chat-v3-0324:free",
-			//Model: "qwen/qwen3-coder:free",
-			Messages: []openrouter.ChatCompletionMessage{
+
+	resp, err := model.Chat(
+		ctx,
+		ChatRequest{
+			Messages: []ChatMessage{
 				{
-					Role:    openrouter.ChatMessageRoleSystem,
-					Content: openrouter.Content{Text: string(llmSystemPromptEscaped)},
+					Role:    ChatRoleSystem,
+					Content: string(llmSystemPromptEscaped),
 				},
 				{
-					Role:    openrouter.ChatMessageRoleUser,
-					Content: openrouter.Content{Text: string(llmUserPromptEscaped)},
+					Role:    ChatRoleUser,
+					Content: string(llmUserPromptEscaped),
 				},
 			},
 		},
 	)
 
 	if err != nil {
-		log.Fatalf("llm err: %v", err)
+		return fmt.Errorf("llm err: %w", err)
 	}
 
-	respStr := resp.Choices[0].Message.Content.Text
+	respStr := resp.Text
 	fmt.Printf("llm rsp: %s\n", respStr)
 
-	codeOfTest, err := cutN2(respStr, zRspStart, zRspEnd)
+	parser := NewMarkerParser(MarkerPair{Kind: SegmentResponse, Start: data.Markers.RspStart, End: data.Markers.RspEnd})
+	segments, err := parser.Parse(respStr)
 	if err != nil {
-		log.Fatalf("cur codeOfTest err: %v", err)
+		log.Printf("parse codeOfTest: %v", err)
+	}
+	codeOfTestSeg, ok := LastSegment(segments, SegmentResponse)
+	if !ok {
+		return fmt.Errorf("cut codeOfTest err: no %s ... %s block in llm rsp", data.Markers.RspStart, data.Markers.RspEnd)
 	}
+	codeOfTest := codeOfTestSeg.Text
 
-	err = writeStringToFile("tmp/test_python.py", codeOfTest)
+	err = writeStringToFile(outPath, codeOfTest)
 	if err != nil {
 		log.Printf("ошибка записи файла с тестом: %v", err)
 	}
 
-	cmd := exec.Command(
+	cmd := exec.CommandContext(
+		ctx,
 		"docker",
 		"build",
 		"--progress=plain",
@@ -116,12 +103,13 @@ Z_RSP_FORM_END
 	cmd.Stderr = os.Stderr
 
 	if err := cmd.Start(); err != nil {
-		log.Fatalf("Failed to start docker container: %v", err)
+		return fmt.Errorf("failed to start docker container: %w", err)
 	}
 
 	if err := cmd.Wait(); err != nil {
-		log.Fatalf("Failed to wait for docker container: %v", err)
+		return fmt.Errorf("failed to wait for docker container: %w", err)
 	}
+	return nil
 }
 
 func readFileToString(path string) (string, error) {
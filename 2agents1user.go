@@ -4,8 +4,9 @@ import (
 	"context"
 )
 
-func Run2Agents1User() error {
-	inspector := NewSimpleAgentInspector(nil)
-	interviewer := NewAgentInterviewer(nil, inspector)
-	return interviewer.Run(context.Background())
+// Run2Agents1User runs the interviewer dialog with finalized responses reviewed by inspector,
+// nil means the LLM inspector on the interviewer model.
+func Run2Agents1User(ctx context.Context, interviewerModel ChatModel, inspector AgentInspector, schema *ResponseSchema, opts ...InterviewerOption) error {
+	interviewer := NewAgentInterviewer(interviewerModel, inspector, schema, opts...)
+	return interviewer.Run(ctx)
 }
@@ -12,7 +12,7 @@ import (
 	"github.com/metoro-io/mcp-golang/transport/stdio"
 )
 
-func Run2MCP() {
+func Run2MCP(dir string) {
 	githubToken := os.Getenv("GITHUB_PERSONAL_ACCESS_TOKEN")
 	if githubToken == "" {
 		log.Fatal("export GITHUB_PERSONAL_ACCESS_TOKEN first")
@@ -51,7 +51,7 @@ func Run2MCP() {
 			"-i",
 			"--rm",
 			"--mount",
-			"type=bind,src=/home/clayly/projects/course/elite-engineers_advent-of-ai_2025_00/tmp,dst=/projects",
+			"type=bind,src="+dir+",dst=/projects",
 			"mcp/filesystem",
 			"/projects",
 		),
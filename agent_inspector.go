@@ -2,63 +2,133 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"log"
-	"os"
-
-	"github.com/revrost/go-openrouter"
+	"strings"
 )
 
+// Verdict is the inspector decision about a finalized response.
+type Verdict struct {
+	Approved bool              `json:"approved"`
+	Issues   []InspectionIssue `json:"issues,omitempty"`
+	// Inspector names who decided, composite inspectors join the names of their members.
+	Inspector string `json:"inspector,omitempty"`
+	// Votes of the members of a QuorumInspector.
+	Votes []InspectorVote `json:"votes,omitempty"`
+}
+
+// InspectionIssue is a problem with one field of one item of the response.
+type InspectionIssue struct {
+	// Item is the index in the "items" array, -1 when the issue is about the whole response.
+	Item int `json:"item"`
+	// Field is the JSON name of the field, empty when the issue is about the whole item.
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+	// Question is a follow-up question for Z_USER that would resolve the issue.
+	Question string `json:"question,omitempty"`
+}
+
+func (issue InspectionIssue) String() string {
+	var sb strings.Builder
+	if issue.Item >= 0 {
+		fmt.Fprintf(&sb, "items[%d]", issue.Item)
+	} else {
+		sb.WriteString("response")
+	}
+	if issue.Field != "" {
+		sb.WriteString("." + issue.Field)
+	}
+	sb.WriteString(": " + issue.Message)
+	if issue.Question != "" {
+		sb.WriteString(" (ask: " + issue.Question + ")")
+	}
+	return sb.String()
+}
+
 type AgentInspector interface {
-	Inspect(ctx context.Context, resp ZRsp) error
+	Inspect(ctx context.Context, resp StructuredResponse) (Verdict, error)
 }
 
 type SimpleAgentInspector struct {
-	client *openrouter.Client
+	model ChatModel
 
-	sysPrompt string
+	prompts       *Prompts
+	sysPrompt     string
+	focus         string
+	verdictSchema *ResponseSchema
 }
 
-func NewSimpleAgentInspector(client *openrouter.Client) *SimpleAgentInspector {
-	if client == nil {
-		apiKey := os.Getenv("OPENROUTER_API_KEY")
-		if apiKey == "" {
-			log.Fatal("export OPENROUTER_API_KEY first")
-		}
-		client = openrouter.NewClient(apiKey)
+// InspectorOption configures optional SimpleAgentInspector behaviour.
+type InspectorOption func(*SimpleAgentInspector)
+
+// WithInspectorFocus appends instructions to the system prompt, e.g. to review only plausibility of numbers.
+func WithInspectorFocus(focus string) InspectorOption {
+	return func(agent *SimpleAgentInspector) {
+		agent.focus = focus
 	}
+}
 
-	agent := &SimpleAgentInspector{
-		client: client,
+// WithInspectorPrompts renders the system prompt from prompts instead of the embedded ones.
+func WithInspectorPrompts(prompts *Prompts) InspectorOption {
+	return func(agent *SimpleAgentInspector) {
+		if prompts != nil {
+			agent.prompts = prompts
+		}
 	}
-	agent.sysPrompt = "You are an AI inspector. Briefly acknowledge the structured JSON payload and do not include extra prose."
-	return agent
 }
 
-func (agent *SimpleAgentInspector) Inspect(ctx context.Context, zResp ZRsp) error {
-	zRespStr, err := json.Marshal(zResp)
+func NewSimpleAgentInspector(model ChatModel, opts ...InspectorOption) *SimpleAgentInspector {
+	if model == nil {
+		model = NewChatModelFromEnv(usageAgentInspector, defaultInspectorModel)
+	}
+
+	verdictSchema, err := newVerdictSchema()
 	if err != nil {
-		return fmt.Errorf("marshal ZRsp: %w", err)
+		log.Fatal(err)
 	}
 
-	resp, err := agent.client.CreateChatCompletion(
-		ctx,
-		openrouter.ChatCompletionRequest{
-			Model: "deepseek/deepseek-chat-v3-0324:free",
-			Messages: []openrouter.ChatCompletionMessage{
-				{Role: openrouter.ChatMessageRoleSystem, Content: openrouter.Content{Text: agent.sysPrompt}},
-				{Role: openrouter.ChatMessageRoleUser, Content: openrouter.Content{Text: string(zRespStr)}},
-			},
+	agent := &SimpleAgentInspector{
+		model:         model,
+		verdictSchema: verdictSchema,
+		prompts:       DefaultPrompts(),
+	}
+	for _, opt := range opts {
+		opt(agent)
+	}
+	agent.sysPrompt = agent.prompts.mustRender(PromptInspectorSystem, InspectorPromptData{
+		Template: string(verdictSchema.Template),
+		Focus:    agent.focus,
+	})
+	return agent
+}
+
+// newVerdictSchema is the schema of inspector answers, its template shows one issue.
+func newVerdictSchema() (*ResponseSchema, error) {
+	return NewResponseSchemaFor("verdict", &Verdict{
+		Approved: false,
+		Issues: []InspectionIssue{
+			{Item: 0, Field: "value1", Message: "{what is wrong}", Question: "{question to the user that fixes it}"},
 		},
-	)
+	})
+}
 
+func (agent *SimpleAgentInspector) Inspect(ctx context.Context, zResp StructuredResponse) (Verdict, error) {
+	resp, err := agent.model.Chat(ctx, ChatRequest{
+		Messages: []ChatMessage{
+			{Role: ChatRoleSystem, Content: agent.sysPrompt},
+			{Role: ChatRoleUser, Content: string(zResp.Raw)},
+		},
+	})
 	if err != nil {
-		return fmt.Errorf("inspector openrouter call: %w", err)
+		return Verdict{}, fmt.Errorf("inspector chat call: %w", err)
 	}
+	fmt.Printf("Inspector LLM rsp: %s\n", resp.Text)
 
-	if len(resp.Choices) > 0 {
-		fmt.Printf("Inspector LLM ack: %s\n", resp.Choices[0].Message.Content.Text)
+	parsed, err := agent.verdictSchema.Parse([]byte(stripCodeFence(StripReasoning(resp.Text))))
+	if err != nil {
+		return Verdict{}, fmt.Errorf("inspector verdict: %w", err)
 	}
-	return nil
+	verdict := *parsed.Value.(*Verdict)
+	verdict.Inspector = "llm:" + resp.Model
+	return verdict, nil
 }
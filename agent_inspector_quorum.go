@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Quorum policies.
+const (
+	// QuorumUnanimous approves when every inspector that voted approved.
+	QuorumUnanimous = "unanimous"
+	// QuorumMajority approves when more inspectors approved than rejected.
+	QuorumMajority = "majority"
+	// QuorumWeighted approves when the approving share of the voting weight exceeds the threshold.
+	QuorumWeighted = "weighted"
+)
+
+const defaultQuorumTimeout = 60 * time.Second
+
+// InspectorVote is how one quorum member voted. Members that failed or timed out abstain.
+type InspectorVote struct {
+	Inspector string  `json:"inspector"`
+	Weight    float64 `json:"weight"`
+	Approved  bool    `json:"approved"`
+	Abstained bool    `json:"abstained,omitempty"`
+	Error     string  `json:"error,omitempty"`
+	Issues    int     `json:"issues,omitempty"`
+	// Elapsed is how long the member took, in milliseconds.
+	Elapsed int64 `json:"elapsedMs"`
+}
+
+type QuorumMember struct {
+	Name      string
+	Inspector AgentInspector
+	// Weight counts in QuorumWeighted, default 1.
+	Weight float64
+	// Timeout bounds the member, default 60s.
+	Timeout time.Duration
+}
+
+// QuorumInspector asks all members in parallel and combines their verdicts under a policy.
+// A slow or failing member abstains instead of blocking the others.
+type QuorumInspector struct {
+	policy    string
+	threshold float64
+	members   []QuorumMember
+}
+
+// NewQuorumInspector builds a quorum. threshold is used by QuorumWeighted, 0 means 0.5.
+func NewQuorumInspector(policy string, threshold float64, members ...QuorumMember) (*QuorumInspector, error) {
+	switch policy {
+	case QuorumUnanimous, QuorumMajority, QuorumWeighted:
+	default:
+		return nil, fmt.Errorf("unknown quorum policy %q, expected %s, %s or %s", policy, QuorumUnanimous, QuorumMajority, QuorumWeighted)
+	}
+	if len(members) == 0 {
+		return nil, errors.New("quorum needs at least one inspector")
+	}
+	if threshold == 0 {
+		threshold = 0.5
+	}
+	for i := range members {
+		if members[i].Weight == 0 {
+			members[i].Weight = 1
+		}
+		if members[i].Timeout == 0 {
+			members[i].Timeout = defaultQuorumTimeout
+		}
+		if members[i].Name == "" {
+			members[i].Name = fmt.Sprintf("inspector%d", i+1)
+		}
+	}
+	return &QuorumInspector{policy: policy, threshold: threshold, members: members}, nil
+}
+
+type quorumResult struct {
+	index   int
+	verdict Verdict
+	err     error
+	elapsed time.Duration
+}
+
+func (quorum *QuorumInspector) Inspect(ctx context.Context, resp StructuredResponse) (Verdict, error) {
+	results := make(chan quorumResult, len(quorum.members))
+	for i, member := range quorum.members {
+		go func() {
+			memberCtx, cancel := context.WithTimeout(ctx, member.Timeout)
+			defer cancel()
+			start := time.Now()
+
+			// an inspector that ignores its context must not hold the quorum past its timeout
+			done := make(chan quorumResult, 1)
+			go func() {
+				verdict, err := member.Inspector.Inspect(memberCtx, resp)
+				done <- quorumResult{index: i, verdict: verdict, err: err}
+			}()
+			var result quorumResult
+			select {
+			case result = <-done:
+			case <-memberCtx.Done():
+				result = quorumResult{index: i, err: fmt.Errorf("%s: %w", member.Name, memberCtx.Err())}
+			}
+			result.elapsed = time.Since(start)
+			results <- result
+		}()
+	}
+
+	votes := make([]InspectorVote, len(quorum.members))
+	verdicts := make([]*Verdict, len(quorum.members))
+	for range quorum.members {
+		var result quorumResult
+		select {
+		case result = <-results:
+		case <-ctx.Done():
+			return Verdict{}, ctx.Err()
+		}
+
+		member := quorum.members[result.index]
+		vote := InspectorVote{Inspector: member.Name, Weight: member.Weight, Elapsed: result.elapsed.Milliseconds()}
+		if result.err != nil {
+			vote.Abstained = true
+			vote.Error = result.err.Error()
+		} else {
+			vote.Approved = result.verdict.Approved
+			vote.Issues = len(result.verdict.Issues)
+			verdicts[result.index] = &result.verdict
+		}
+		votes[result.index] = vote
+	}
+
+	combined := Verdict{Inspector: "quorum:" + quorum.policy, Votes: votes}
+	var approvals, rejections int
+	var approveWeight, voteWeight float64
+	for i, vote := range votes {
+		fmt.Printf("quorum vote %s: approved=%t abstained=%t issues=%d elapsed=%dms %s\n",
+			vote.Inspector, vote.Approved, vote.Abstained, vote.Issues, vote.Elapsed, vote.Error)
+		if vote.Abstained {
+			continue
+		}
+		voteWeight += vote.Weight
+		if vote.Approved {
+			approvals++
+			approveWeight += vote.Weight
+			continue
+		}
+		rejections++
+		combined.Issues = appendIssues(combined.Issues, verdicts[i].Issues)
+	}
+	if approvals+rejections == 0 {
+		return Verdict{}, fmt.Errorf("quorum: all %d inspectors abstained", len(votes))
+	}
+
+	switch quorum.policy {
+	case QuorumUnanimous:
+		combined.Approved = rejections == 0
+	case QuorumMajority:
+		combined.Approved = approvals > rejections
+	case QuorumWeighted:
+		combined.Approved = approveWeight/voteWeight > quorum.threshold
+	}
+	if combined.Approved {
+		combined.Issues = nil
+	}
+	return combined, nil
+}
+
+// appendIssues merges issues, skipping ones already reported for the same item and field.
+func appendIssues(issues, more []InspectionIssue) []InspectionIssue {
+	for _, issue := range more {
+		duplicate := false
+		for _, seen := range issues {
+			if seen.Item == issue.Item && seen.Field == issue.Field && (issue.Field != "" || seen.Message == issue.Message) {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			issues = append(issues, issue)
+		}
+	}
+	return issues
+}
+
+// QuorumConfig is the -quorum file: the policy and one LLM inspector per member.
+type QuorumConfig struct {
+	Policy    string  `json:"policy"`
+	Threshold float64 `json:"threshold,omitempty"`
+	Members   []struct {
+		Name     string  `json:"name,omitempty"`
+		Provider string  `json:"provider,omitempty"`
+		BaseURL  string  `json:"baseUrl,omitempty"`
+		Model    string  `json:"model"`
+		Weight   float64 `json:"weight,omitempty"`
+		// Timeout is a Go duration like "30s".
+		Timeout string `json:"timeout,omitempty"`
+		// Focus is appended to the inspector system prompt, so members can review different aspects.
+		Focus string `json:"focus,omitempty"`
+	} `json:"members"`
+}
+
+// LoadQuorumInspector builds a QuorumInspector of LLM inspectors from a QuorumConfig file.
+// base supplies the provider, base URL and cassette of members that don't set them.
+// opts are applied to every member inspector before its focus.
+func LoadQuorumInspector(path string, base ChatModelConfig, opts ...InspectorOption) (*QuorumInspector, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read quorum: %w", err)
+	}
+	var cfg QuorumConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parse quorum %s: %w", path, err)
+	}
+
+	var members []QuorumMember
+	for i, m := range cfg.Members {
+		modelCfg := base
+		if m.Provider != "" {
+			modelCfg.Provider = m.Provider
+			modelCfg.BaseURL = ""
+		}
+		if m.BaseURL != "" {
+			modelCfg.BaseURL = m.BaseURL
+		}
+		if m.Model != "" {
+			modelCfg.Model = m.Model
+		}
+		model, err := NewChatModel(modelCfg)
+		if err != nil {
+			return nil, fmt.Errorf("quorum member %d: %w", i+1, err)
+		}
+
+		member := QuorumMember{Name: m.Name, Weight: m.Weight}
+		if member.Name == "" {
+			member.Name = modelCfg.Model
+		}
+		if m.Timeout != "" {
+			if member.Timeout, err = time.ParseDuration(m.Timeout); err != nil {
+				return nil, fmt.Errorf("quorum member %s timeout: %w", member.Name, err)
+			}
+		}
+		memberOpts := append(slices.Clone(opts), WithInspectorFocus(strings.TrimSpace(m.Focus)))
+		member.Inspector = NewSimpleAgentInspector(model, memberOpts...)
+		members = append(members, member)
+	}
+	return NewQuorumInspector(cfg.Policy, cfg.Threshold, members...)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQuorumInspector(t *testing.T) {
+	approve := func(weight float64) QuorumMember {
+		return QuorumMember{Inspector: &verdictInspector{verdict: Verdict{Approved: true}}, Weight: weight}
+	}
+	reject := func(weight float64, field string) QuorumMember {
+		issues := []InspectionIssue{{Item: 0, Field: field, Message: field + " is wrong"}}
+		return QuorumMember{Inspector: &verdictInspector{verdict: Verdict{Issues: issues}}, Weight: weight}
+	}
+	fail := func() QuorumMember {
+		return QuorumMember{Inspector: &verdictInspector{err: errors.New("down")}}
+	}
+	slow := func() QuorumMember {
+		return QuorumMember{Inspector: &verdictInspector{verdict: Verdict{Approved: true}, delay: time.Minute}, Timeout: 10 * time.Millisecond}
+	}
+
+	tests := []struct {
+		name         string
+		policy       string
+		threshold    float64
+		members      []QuorumMember
+		wantApproved bool
+		wantIssues   int
+		wantErr      string
+	}{
+		{name: "unanimous approves", policy: QuorumUnanimous, members: []QuorumMember{approve(1), approve(1)}, wantApproved: true},
+		{name: "unanimous vetoed", policy: QuorumUnanimous, members: []QuorumMember{approve(1), approve(1), reject(1, "name")}, wantIssues: 1},
+		{name: "majority approves", policy: QuorumMajority, members: []QuorumMember{approve(1), approve(1), reject(1, "name")}, wantApproved: true},
+		{name: "majority tie rejects", policy: QuorumMajority, members: []QuorumMember{approve(1), reject(1, "name")}, wantIssues: 1},
+		{name: "weighted approves", policy: QuorumWeighted, members: []QuorumMember{approve(3), reject(1, "name"), reject(1, "size")}, wantApproved: true},
+		{name: "weighted threshold", policy: QuorumWeighted, threshold: 0.7, members: []QuorumMember{approve(3), reject(1, "name"), reject(1, "size")}, wantIssues: 2},
+		{name: "weighted default weight", policy: QuorumWeighted, members: []QuorumMember{approve(0), reject(0, "name")}, wantIssues: 1},
+		{name: "issues merged", policy: QuorumMajority, members: []QuorumMember{reject(1, "name"), reject(1, "name"), reject(1, "size")}, wantIssues: 2},
+		{name: "failure abstains", policy: QuorumUnanimous, members: []QuorumMember{approve(1), fail()}, wantApproved: true},
+		{name: "timeout abstains", policy: QuorumMajority, members: []QuorumMember{reject(1, "name"), slow()}, wantIssues: 1},
+		{name: "all abstain", policy: QuorumMajority, members: []QuorumMember{fail(), slow()}, wantErr: "all 2 inspectors abstained"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quorum, err := NewQuorumInspector(tt.policy, tt.threshold, tt.members...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			verdict, err := quorum.Inspect(context.Background(), StructuredResponse{})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if verdict.Approved != tt.wantApproved || len(verdict.Issues) != tt.wantIssues {
+				t.Errorf("verdict approved %t with %d issues, want %t with %d", verdict.Approved, len(verdict.Issues), tt.wantApproved, tt.wantIssues)
+			}
+			if len(verdict.Votes) != len(tt.members) {
+				t.Fatalf("%d votes, want %d", len(verdict.Votes), len(tt.members))
+			}
+			for i, vote := range verdict.Votes {
+				member := tt.members[i].Inspector.(*verdictInspector)
+				if want := member.err != nil || member.delay > 0; vote.Abstained != want {
+					t.Errorf("vote %d abstained = %t, want %t", i, vote.Abstained, want)
+				}
+			}
+		})
+	}
+}
+
+func TestNewQuorumInspector(t *testing.T) {
+	member := QuorumMember{Inspector: &verdictInspector{}}
+	if _, err := NewQuorumInspector("plurality", 0, member); err == nil || !strings.Contains(err.Error(), "unknown quorum policy") {
+		t.Errorf("error = %v, want an unknown policy", err)
+	}
+	if _, err := NewQuorumInspector(QuorumMajority, 0); err == nil {
+		t.Error("a quorum without inspectors was accepted")
+	}
+
+	quorum, err := NewQuorumInspector(QuorumWeighted, 0, member, QuorumMember{Name: "judge", Inspector: &verdictInspector{}, Weight: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if quorum.threshold != 0.5 {
+		t.Errorf("threshold = %v, want 0.5", quorum.threshold)
+	}
+	first, second := quorum.members[0], quorum.members[1]
+	if first.Name != "inspector1" || first.Weight != 1 || first.Timeout != defaultQuorumTimeout {
+		t.Errorf("defaults of the first member = %+v", first)
+	}
+	if second.Name != "judge" || second.Weight != 2 {
+		t.Errorf("second member = %+v", second)
+	}
+}
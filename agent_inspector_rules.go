@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Kinds of inspection rules.
+const (
+	// RuleRequired rejects items where any of Fields is missing or blank.
+	RuleRequired = "required"
+	// RuleOneOf rejects items where Field is not one of Values.
+	RuleOneOf = "oneOf"
+	// RuleNumeric rejects items where Field is not a number.
+	RuleNumeric = "numeric"
+)
+
+// RuleSet configures a RuleInspector. Rules apply to every element of the ItemsField array.
+type RuleSet struct {
+	// ItemsField is the array of the response the rules check, default "items".
+	ItemsField string `json:"itemsField,omitempty"`
+	Rules      []Rule `json:"rules"`
+}
+
+type Rule struct {
+	Kind   string   `json:"kind"`
+	Field  string   `json:"field,omitempty"`
+	Fields []string `json:"fields,omitempty"`
+	Values []string `json:"values,omitempty"`
+	// When limits the rule to items matching the condition.
+	When *RuleCondition `json:"when,omitempty"`
+	// Question overrides the follow-up question, {field} placeholders are replaced with item values.
+	Question string `json:"question,omitempty"`
+}
+
+// RuleCondition matches items whose Field is one of In, compared case-insensitively.
+type RuleCondition struct {
+	Field string   `json:"field"`
+	In    []string `json:"in"`
+}
+
+// defaultZRspRules checks the built-in zrsp schema with the built-in units and currencies.
+var defaultZRspRules = ZRspRules(DefaultUnitRegistry())
+
+// ZRspRules checks the built-in zrsp schema, units are the names units knows, so currencies
+// added with -rates are accepted too.
+func ZRspRules(units *UnitRegistry) RuleSet {
+	physicalUnits := units.Names()
+	return RuleSet{
+		ItemsField: "items",
+		Rules: []Rule{
+			{Kind: RuleRequired, Fields: []string{"itemType", "itemName", "value1Name", "value1Units", "value1"}},
+			{Kind: RuleOneOf, Field: "itemType", Values: []string{"car", "bullet", "action"}},
+			{Kind: RuleOneOf, Field: "value1Units", Values: append(slices.Clone(physicalUnits), "bash code")},
+			{
+				Kind:     RuleNumeric,
+				Field:    "value1",
+				When:     &RuleCondition{Field: "value1Units", In: physicalUnits},
+				Question: "What is the {value1Name} of {itemName} as a number in {value1Units}?",
+			},
+		},
+	}
+}
+
+// LoadRuleSet reads a RuleSet from a JSON file.
+func LoadRuleSet(path string) (*RuleSet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read rules: %w", err)
+	}
+	var rules RuleSet
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("parse rules %s: %w", path, err)
+	}
+	for i, rule := range rules.Rules {
+		switch rule.Kind {
+		case RuleRequired, RuleOneOf, RuleNumeric:
+		default:
+			return nil, fmt.Errorf("rules %s: rule %d has unknown kind %q", path, i, rule.Kind)
+		}
+	}
+	return &rules, nil
+}
+
+// RuleInspector is a deterministic AgentInspector, it needs no model calls.
+type RuleInspector struct {
+	name  string
+	rules RuleSet
+}
+
+func NewRuleInspector(name string, rules RuleSet) *RuleInspector {
+	if rules.ItemsField == "" {
+		rules.ItemsField = "items"
+	}
+	return &RuleInspector{name: name, rules: rules}
+}
+
+func (inspector *RuleInspector) Inspect(ctx context.Context, resp StructuredResponse) (Verdict, error) {
+	verdict := Verdict{Inspector: "rules:" + inspector.name}
+
+	var doc map[string]any
+	if err := json.Unmarshal(resp.Raw, &doc); err != nil {
+		return Verdict{}, fmt.Errorf("rules inspector: %w", err)
+	}
+	items, ok := doc[inspector.rules.ItemsField].([]any)
+	if !ok {
+		verdict.Issues = append(verdict.Issues, InspectionIssue{
+			Item:    -1,
+			Field:   inspector.rules.ItemsField,
+			Message: "array is missing",
+		})
+	}
+
+	for i, raw := range items {
+		item, _ := raw.(map[string]any)
+		for _, rule := range inspector.rules.Rules {
+			if rule.When != nil && !containsFold(rule.When.In, fieldString(item, rule.When.Field)) {
+				continue
+			}
+			verdict.Issues = append(verdict.Issues, rule.check(i, item)...)
+		}
+	}
+
+	verdict.Approved = len(verdict.Issues) == 0
+	return verdict, nil
+}
+
+func (rule Rule) check(index int, item map[string]any) []InspectionIssue {
+	var issues []InspectionIssue
+	switch rule.Kind {
+	case RuleRequired:
+		for _, field := range rule.Fields {
+			if strings.TrimSpace(fieldString(item, field)) == "" {
+				issues = append(issues, rule.issue(index, item, field, "is empty", fmt.Sprintf("What is the %s of item %d?", field, index+1)))
+			}
+		}
+	case RuleOneOf:
+		value := fieldString(item, rule.Field)
+		if value != "" && !containsFold(rule.Values, value) {
+			issues = append(issues, rule.issue(index, item, rule.Field,
+				fmt.Sprintf("%q is not one of %s", value, strings.Join(rule.Values, ", ")),
+				fmt.Sprintf("Which of %s is the %s of item %d?", strings.Join(rule.Values, ", "), rule.Field, index+1)))
+		}
+	case RuleNumeric:
+		value := fieldString(item, rule.Field)
+		if value != "" && !isNumeric(value) {
+			issues = append(issues, rule.issue(index, item, rule.Field,
+				fmt.Sprintf("%q is not a number", value),
+				fmt.Sprintf("What is the %s of item %d as a number?", rule.Field, index+1)))
+		}
+	}
+	return issues
+}
+
+func (rule Rule) issue(index int, item map[string]any, field, message, defaultQuestion string) InspectionIssue {
+	question := rule.Question
+	if question == "" {
+		question = defaultQuestion
+	}
+	for key := range item {
+		question = strings.ReplaceAll(question, "{"+key+"}", fieldString(item, key))
+	}
+	return InspectionIssue{Item: index, Field: field, Message: message, Question: question}
+}
+
+// fieldString returns a scalar field as text, "" when it is missing or not a scalar.
+func fieldString(item map[string]any, field string) string {
+	switch v := item[field].(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		return ""
+	}
+}
+
+func isNumeric(s string) bool {
+	_, err := parseNumber(s)
+	return err == nil
+}
+
+func containsFold(values []string, s string) bool {
+	return slices.ContainsFunc(values, func(v string) bool { return strings.EqualFold(v, s) })
+}
+
+// ChainInspector runs inspectors in order and stops at the first rejection,
+// so cheap deterministic checks run before expensive model calls.
+type ChainInspector struct {
+	inspectors []AgentInspector
+}
+
+func NewChainInspector(inspectors ...AgentInspector) *ChainInspector {
+	return &ChainInspector{inspectors: inspectors}
+}
+
+func (chain *ChainInspector) Inspect(ctx context.Context, resp StructuredResponse) (Verdict, error) {
+	combined := Verdict{Approved: true}
+	var names []string
+	for _, inspector := range chain.inspectors {
+		verdict, err := inspector.Inspect(ctx, resp)
+		if err != nil {
+			return Verdict{}, err
+		}
+		names = append(names, verdict.Inspector)
+		combined.Inspector = strings.Join(names, "+")
+		if !verdict.Approved {
+			combined.Approved = false
+			combined.Issues = verdict.Issues
+			return combined, nil
+		}
+	}
+	return combined, nil
+}
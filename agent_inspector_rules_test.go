@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// verdictInspector returns verdict or err after delay, or gives up when its context ends first.
+type verdictInspector struct {
+	verdict Verdict
+	err     error
+	delay   time.Duration
+	calls   int
+}
+
+func (inspector *verdictInspector) Inspect(ctx context.Context, _ StructuredResponse) (Verdict, error) {
+	inspector.calls++
+	select {
+	case <-time.After(inspector.delay):
+	case <-ctx.Done():
+		return Verdict{}, ctx.Err()
+	}
+	return inspector.verdict, inspector.err
+}
+
+func TestRuleInspector(t *testing.T) {
+	rules := RuleSet{Rules: []Rule{
+		{Kind: RuleRequired, Fields: []string{"name", "size"}},
+		{Kind: RuleOneOf, Field: "kind", Values: []string{"car", "Bullet"}},
+		{Kind: RuleNumeric, Field: "size", When: &RuleCondition{Field: "unit", In: []string{"km", "m"}}, Question: "How long is {name} in {unit}?"},
+	}}
+	type issue struct {
+		item     int
+		field    string
+		question string
+	}
+	tests := []struct {
+		name string
+		raw  string
+		want []issue
+	}{
+		{name: "valid", raw: `{"items":[{"name":"a","size":"12.5","kind":"car","unit":"km"}]}`},
+		{name: "one of is case-insensitive", raw: `{"items":[{"name":"a","size":"1","kind":"bullet"}]}`},
+		{name: "numbers and booleans are scalars", raw: `{"items":[{"name":true,"size":3,"unit":"m"}]}`},
+		{
+			name: "missing and blank",
+			raw:  `{"items":[{"name":"a","size":"1"},{"name":"  "}]}`,
+			want: []issue{{1, "name", "What is the name of item 2?"}, {1, "size", "What is the size of item 2?"}},
+		},
+		{name: "objects are not scalars", raw: `{"items":[{"name":{"first":"a"},"size":"1"}]}`, want: []issue{{0, "name", "What is the name of item 1?"}}},
+		{name: "not one of", raw: `{"items":[{"name":"a","size":"1","kind":"plane"}]}`, want: []issue{{0, "kind", "Which of car, Bullet is the kind of item 1?"}}},
+		{name: "not numeric", raw: `{"items":[{"name":"a","size":"fast","unit":"KM"}]}`, want: []issue{{0, "size", "How long is a in KM?"}}},
+		{name: "condition not met", raw: `{"items":[{"name":"a","size":"fast","unit":"bash code"}]}`},
+		{name: "missing array", raw: `{"rows":[]}`, want: []issue{{-1, "items", ""}}},
+	}
+	inspector := NewRuleInspector("test", rules)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verdict, err := inspector.Inspect(context.Background(), StructuredResponse{Raw: []byte(tt.raw)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []issue
+			for _, i := range verdict.Issues {
+				got = append(got, issue{i.Item, i.Field, i.Question})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("issues = %+v, want %+v", got, tt.want)
+			}
+			if verdict.Approved != (len(tt.want) == 0) {
+				t.Errorf("approved = %t with %d issues", verdict.Approved, len(tt.want))
+			}
+			if verdict.Inspector != "rules:test" {
+				t.Errorf("inspector = %q", verdict.Inspector)
+			}
+		})
+	}
+
+	if _, err := inspector.Inspect(context.Background(), StructuredResponse{Raw: []byte(`[`)}); err == nil {
+		t.Error("Inspect accepted a response that is not JSON")
+	}
+}
+
+func TestLoadRuleSet(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		err  string
+	}{
+		{name: "valid", data: `{"itemsField":"rows","rules":[{"kind":"numeric","field":"size"}]}`},
+		{name: "unknown kind", data: `{"rules":[{"kind":"required"},{"kind":"regex"}]}`, err: `rule 1 has unknown kind "regex"`},
+		{name: "not json", data: `rules`, err: "parse rules"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "rules.json")
+			if err := os.WriteFile(path, []byte(tt.data), 0644); err != nil {
+				t.Fatal(err)
+			}
+			rules, err := LoadRuleSet(path)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("error = %v, want it to contain %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rules.ItemsField != "rows" || len(rules.Rules) != 1 {
+				t.Errorf("rules = %+v", rules)
+			}
+		})
+	}
+}
+
+func TestChainInspector(t *testing.T) {
+	approve := Verdict{Approved: true, Inspector: "a"}
+	reject := Verdict{Inspector: "r", Issues: []InspectionIssue{{Item: 0, Field: "name", Message: "is empty"}}}
+	tests := []struct {
+		name          string
+		first, second *verdictInspector
+		wantApproved  bool
+		wantInspector string
+		wantSecond    int
+		wantErr       bool
+	}{
+		{name: "all approve", first: &verdictInspector{verdict: approve}, second: &verdictInspector{verdict: approve}, wantApproved: true, wantInspector: "a+a", wantSecond: 1},
+		{name: "first rejects", first: &verdictInspector{verdict: reject}, second: &verdictInspector{verdict: approve}, wantInspector: "r"},
+		{name: "second rejects", first: &verdictInspector{verdict: approve}, second: &verdictInspector{verdict: reject}, wantInspector: "a+r", wantSecond: 1},
+		{name: "first fails", first: &verdictInspector{err: errors.New("down")}, second: &verdictInspector{verdict: approve}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verdict, err := NewChainInspector(tt.first, tt.second).Inspect(context.Background(), StructuredResponse{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %t", err, tt.wantErr)
+			}
+			if tt.second.calls != tt.wantSecond {
+				t.Errorf("second inspector called %d times, want %d", tt.second.calls, tt.wantSecond)
+			}
+			if err != nil {
+				return
+			}
+			if verdict.Approved != tt.wantApproved || verdict.Inspector != tt.wantInspector {
+				t.Errorf("verdict = %+v, want approved %t by %s", verdict, tt.wantApproved, tt.wantInspector)
+			}
+			if !tt.wantApproved && !reflect.DeepEqual(verdict.Issues, reject.Issues) {
+				t.Errorf("issues = %v, want the issues of the rejecting inspector", verdict.Issues)
+			}
+		})
+	}
+}
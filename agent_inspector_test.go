@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSimpleAgentInspectorVerdict(t *testing.T) {
+	rejection := `{"approved":false,"issues":[{"item":0,"field":"value1","message":"too fast","question":"Is 900 km/h right?"}]}`
+	tests := []struct {
+		name    string
+		text    string
+		want    *Verdict
+		wantErr bool
+	}{
+		{name: "plain", text: `{"approved":true}`, want: &Verdict{Approved: true, Inspector: "llm:m"}},
+		{
+			name: "reasoning and code fence",
+			text: "<think>900 is a lot</think>\n```json\n" + rejection + "\n```",
+			want: &Verdict{Inspector: "llm:m", Issues: []InspectionIssue{{Item: 0, Field: "value1", Message: "too fast", Question: "Is 900 km/h right?"}}},
+		},
+		{name: "not a verdict", text: "Looks good to me!", wantErr: true},
+		{name: "wrong type", text: `{"approved":"yes"}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			model := &scriptedChatModel{responses: []ChatResponse{{Model: "m", Text: tt.text}}}
+			inspector := NewSimpleAgentInspector(model)
+			verdict, err := inspector.Inspect(context.Background(), StructuredResponse{Raw: []byte(validRepairPayload)})
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Inspect = %+v, want an error", verdict)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fmt.Sprintf("%+v", verdict) != fmt.Sprintf("%+v", *tt.want) {
+				t.Errorf("verdict %+v, want %+v", verdict, *tt.want)
+			}
+			if got := model.requests[0].Messages[1].Content; got != validRepairPayload {
+				t.Errorf("inspector was shown %q, want the raw response", got)
+			}
+		})
+	}
+}
+
+func TestInspectorRejectionLeadsToFollowUpQuestion(t *testing.T) {
+	markers := defaultPromptMarkers
+	answer := ChatResponse{Model: "m", Text: wrapMarkers(markers.RspStart, validRepairPayload, markers.RspEnd)}
+	issue := InspectionIssue{Item: 0, Field: "value1", Message: "too slow", Question: "Is 180 km/h right?"}
+	inspector := &verdictInspector{verdict: Verdict{Issues: []InspectionIssue{issue}}}
+	model := &scriptedChatModel{responses: []ChatResponse{answer, markersQuestion("Is 180 km/h right?"), answer}}
+	agent := NewAgentInterviewer(model, inspector, MustDefaultResponseSchema(), WithInspectionRounds(1), WithStreaming(false))
+
+	var got []InterviewerEvent
+	onEvent := func(event InterviewerEvent) { got = append(got, event) }
+	if err := agent.Step(context.Background(), "Audi TT, 180 km/h", onEvent); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].Type != EventResponse || got[0].Accepted || got[1].Type != EventQuestion {
+		t.Fatalf("events %+v, want a rejected response and a follow-up question", got)
+	}
+	feedback := model.requests[1].Messages
+	if last := feedback[len(feedback)-1].Content; !strings.Contains(last, issue.String()) {
+		t.Errorf("the model was told %q, want the issue %q", last, issue.String())
+	}
+
+	// the second rejection is one more than the inspection rounds allow
+	got = nil
+	if err := agent.Step(context.Background(), "yes", onEvent); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].Type != EventResponse || got[1].Type != EventReset || len(agent.dialog) != 0 {
+		t.Errorf("events %+v with %d turns left, want a rejected response and a reset", got, len(agent.dialog))
+	}
+}
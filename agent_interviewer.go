@@ -3,19 +3,20 @@ package main
 import (
 	"bufio"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"io"
 	"os"
 	"strings"
-
-	"github.com/revrost/go-openrouter"
+	"time"
 )
 
 type AgentInterviewer struct {
-	client    *openrouter.Client
+	model     ChatModel
 	reader    *bufio.Reader
 	inspector AgentInspector
+	schema    *ResponseSchema
+	mode      string
 
 	zProvideDataStart string
 	zProvideDataEnd   string
@@ -24,108 +25,190 @@ type AgentInterviewer struct {
 	zRspStart         string
 	zRspEnd           string
 	zRspFormat        string
-	zRspFormatPrompt  string
+	prompts           *Prompts
 	sysPrompt         string
 	basicPrompt       string
-	zDialog           string
+	wrapMarkers       bool
+	maxRepairs        int
+	repairLogPath     string
+	stream            bool
+	session           *Session
+	units             *UnitRegistry
+	sinks             []ResponseSink
+	maxRejections     int
+	window            ContextWindow
+	// withoutInspector keeps a nil inspector instead of building an LLM inspector.
+	withoutInspector bool
+	// rejections counts inspector rejections of the current dialog.
+	rejections int
+	// dialog holds the user, assistant and tool turns that follow the system prompt.
+	dialog []dialogTurn
+	// stepTurns counts the turns the running Step appended, they are rolled back when it fails.
+	stepTurns int
+	// repairs records every repair attempt of this interviewer.
+	repairs []RepairAttempt
+	// emit receives the events of the running Step.
+	emit func(InterviewerEvent)
 }
 
-func NewAgentInterviewer(client *openrouter.Client, inspector AgentInspector) *AgentInterviewer {
-	if client == nil {
-		apiKey := os.Getenv("OPENROUTER_API_KEY")
-		if apiKey == "" {
-			log.Fatal("export OPENROUTER_API_KEY first")
-		}
-		client = openrouter.NewClient(apiKey)
-	}
-	if inspector == nil {
-		inspector = NewSimpleAgentInspector(client)
-	}
+// InterviewerOption configures optional AgentInterviewer behaviour.
+type InterviewerOption func(*AgentInterviewer)
 
-	agent := &AgentInterviewer{
-		client:            client,
-		reader:            bufio.NewReader(os.Stdin),
-		inspector:         inspector,
-		zProvideDataStart: "Z_PROVIDE_DATA_START",
-		zProvideDataEnd:   "Z_PROVIDE_DATA_END",
-		zCollectDataStart: "Z_COLLECT_DATA_START",
-		zCollectDataEnd:   "Z_COLLECT_DATA_END",
-		zRspStart:         "Z_RSP_START",
-		zRspEnd:           "Z_RSP_END",
-		zRspFormat:        "JSON",
+// WithInterviewerMode selects InterviewerModeMarkers (default), InterviewerModeTools or InterviewerModeJSON.
+func WithInterviewerMode(mode string) InterviewerOption {
+	return func(agent *AgentInterviewer) {
+		agent.mode = mode
 	}
+}
 
-	// Build prompts and template exactly like in 1agent1user.go
-	agent.zRspFormatPrompt = fmt.Sprintf("exact string %s, right after that valid %s, right after that exact string %s", agent.zRspStart, agent.zRspFormat, agent.zRspEnd)
-	fmt.Printf("zRspFormatPrompt=%s\n", agent.zRspFormatPrompt)
-
-	zRspTemplate := ZRsp{
-		Items: []ZRspItem{
-			{ItemType: "car", ItemName: "TT-34", Value1Name: "cost", Value1Units: "byn", Value1: "1000000"},
-			{ItemType: "bullet", ItemName: "7.62x39mm", Value1Name: "speed", Value1Units: "km/h", Value1: "360"},
-			{ItemType: "action", ItemName: "deleting folder in Linux", Value1Name: "bash command", Value1Units: "bash code", Value1: "sudo rm -rf {folder_name}"},
-		},
+// WithMarkerWrapping controls whether dialog messages are wrapped into
+// Z_PROVIDE_DATA / Z_COLLECT_DATA markers before they are sent (default true).
+func WithMarkerWrapping(wrap bool) InterviewerOption {
+	return func(agent *AgentInterviewer) {
+		agent.wrapMarkers = wrap
 	}
-	zRspTemplateStr, err := json.Marshal(zRspTemplate)
-	if err != nil {
-		log.Fatal(err)
-	}
-	fmt.Printf("zRspTemplateStr=%s\n", string(zRspTemplateStr))
-
-	agent.basicPrompt = fmt.Sprintf(`
-There is dialog (named Z_DIALOG) between me (the user, named Z_USER) and you (the AI, named Z_AI).
-Z_DIALOG starts after word Z_DIALOG_START.
+}
 
-Z_AI can only do 2 things in Z_DIALOG:
-1. Z_AI can give an answer (named Z_RSP, format of which is described below), which will finish Z_DIALOG.
-2. Z_AI can collect data from Z_USER with clarifying questions (named Z_COLLECT_DATA) in order to qualitatively fill out Z_RSP with specific information.
+// WithRepairs sets how many times an invalid Z_RSP is sent back to the model for correction
+// (default 2) and where every repair attempt is appended as JSON lines (empty: stdout only).
+func WithRepairs(maxRepairs int, logPath string) InterviewerOption {
+	return func(agent *AgentInterviewer) {
+		agent.maxRepairs = maxRepairs
+		agent.repairLogPath = logPath
+	}
+}
 
-Z_AI main and only goal in Z_DIALOG is to collect enough specific data to fill Z_RSP.
-Z_AI most ensure that it is collected enough specific data to fill Z_RSP.
+// WithStreaming prints answers while they are generated when the model is a ChatStreamer (default true).
+func WithStreaming(stream bool) InterviewerOption {
+	return func(agent *AgentInterviewer) {
+		agent.stream = stream
+	}
+}
 
-Z_USER can only do 2 things in Z_DIALOG:
-1. Z_USER can provide additional data with clarifying answers (named Z_PROVIDE_DATA). 
-2. Z_USER determines direction of Z_DIALOG and therefore content of Z_RSP with his first Z_PROVIDE_DATA.
+// WithInspectionRounds sets how many times an inspector rejection sends the interviewer back to
+// collecting data (default 3), after that the dialog is reset.
+func WithInspectionRounds(maxRejections int) InterviewerOption {
+	return func(agent *AgentInterviewer) {
+		agent.maxRejections = maxRejections
+	}
+}
 
-All Z_COLLECT_DATA in Z_DIALOG placed between words Z_COLLECT_DATA_START and Z_COLLECT_DATA_END.
+// WithoutInspector finalizes responses without inspection, no LLM inspector is built for a nil inspector.
+func WithoutInspector() InterviewerOption {
+	return func(agent *AgentInterviewer) {
+		agent.inspector = nil
+		agent.withoutInspector = true
+	}
+}
 
-All Z_PROVIDE_DATA in Z_DIALOG placed between words Z_PROVIDE_DATA_START and Z_PROVIDE_DATA_END.
+// WithUnitNormalization converts item values into canonical units before inspection.
+func WithUnitNormalization(units *UnitRegistry) InterviewerOption {
+	return func(agent *AgentInterviewer) {
+		agent.units = units
+	}
+}
 
-Z_AI finishes Z_DIALOG with Z_RSP in 2 occasions:
-1. When Z_USER when the user clearly writes that he can't provide more data.
-2. When Z_USER is stopped providing relative data in Z_PROVIDE_DATA.
+// WithSinks sends every accepted response to sinks.
+func WithSinks(sinks ...ResponseSink) InterviewerOption {
+	return func(agent *AgentInterviewer) {
+		agent.sinks = append(agent.sinks, sinks...)
+	}
+}
 
-When Z_AI decides to answer with Z_RSP, the following 8 rules applied:
-1. Z_AI response contains only text, strictly compatible with Z_RSP:
-2. Z_RSP format is completely defined by Z_RSP_FORMAT and Z_RSP_TEMPLATE.
-3. Z_RSP_FORMAT is placed right between words Z_RSP_FORM_START and Z_RSP_FORM_END.
-4. Z_RSP_FORMAT is totally defines syntax format of Z_RSP and used for automatic deserialization of Z_RSP.
-5. Z_RSP_TEMPLATE is placed right between words Z_RSP_TEMP_START and Z_RSP_TEMP_END.
-6. Z_RSP_TEMPLATE is totally defines logic format of Z_RSP and used for automatic deserialization of Z_RSP.
-7. In answer Z_AI not uses any other symbols or words before or after Z_RSP, which may interfere with deserialization of Z_RSP_FORMAT and Z_RSP_TEMPLATE.
-8. In answer Z_AI is as brief as possible, do not engages in any reasoning and only fills Z_RSP structure according to Z_RSP_FORMAT and Z_RSP_TEMPLATE,
-places in the appropriate keys and arrays those values that corresponds to the provided data.
+// WithPrompts renders the system prompt from prompts instead of the embedded ones.
+func WithPrompts(prompts *Prompts) InterviewerOption {
+	return func(agent *AgentInterviewer) {
+		if prompts != nil {
+			agent.prompts = prompts
+		}
+	}
+}
 
-Z_RSP_FORM_START
-%s
-Z_RSP_FORM_END
+// WithContextWindow sets the context of the model and how the dialog is compacted when it nears it.
+func WithContextWindow(window ContextWindow) InterviewerOption {
+	return func(agent *AgentInterviewer) {
+		agent.window = window
+	}
+}
 
-Z_RSP_TEMP_START
-%s
-Z_RSP_TEMP_END
+// WithSession writes the dialog to session and continues the dialog it restored.
+func WithSession(session *Session) InterviewerOption {
+	return func(agent *AgentInterviewer) {
+		agent.session = session
+		agent.dialog = append([]dialogTurn(nil), session.Dialog()...)
+	}
+}
 
-Z_DIALOG_START
-`, agent.zRspFormatPrompt, zRspTemplateStr)
+func NewAgentInterviewer(model ChatModel, inspector AgentInspector, schema *ResponseSchema, opts ...InterviewerOption) *AgentInterviewer {
+	if model == nil {
+		model = NewChatModelFromEnv(usageAgentInterviewer, defaultInterviewerModel)
+	}
+	if schema == nil {
+		schema = MustDefaultResponseSchema()
+	}
 
-	agent.sysPrompt = "You are an AI interviewer. Ensure you are collected all the needed data from user to give complete answer."
+	agent := &AgentInterviewer{
+		model:             model,
+		reader:            bufio.NewReader(os.Stdin),
+		inspector:         inspector,
+		schema:            schema,
+		zProvideDataStart: defaultPromptMarkers.ProvideDataStart,
+		zProvideDataEnd:   defaultPromptMarkers.ProvideDataEnd,
+		zCollectDataStart: defaultPromptMarkers.CollectDataStart,
+		zCollectDataEnd:   defaultPromptMarkers.CollectDataEnd,
+		zRspStart:         defaultPromptMarkers.RspStart,
+		zRspEnd:           defaultPromptMarkers.RspEnd,
+		zRspFormat:        "JSON",
+		mode:              InterviewerModeMarkers,
+		wrapMarkers:       true,
+		maxRepairs:        2,
+		stream:            true,
+		maxRejections:     3,
+		prompts:           DefaultPrompts(),
+		window:            defaultContextWindow,
+	}
+	for _, opt := range opts {
+		opt(agent)
+	}
+	if agent.inspector == nil && !agent.withoutInspector {
+		agent.inspector = NewSimpleAgentInspector(model)
+	}
 
+	agent.renderPrompts()
 	fmt.Printf("basicPrompt=%s\n", agent.basicPrompt)
-	agent.zDialog = agent.basicPrompt
 	return agent
 }
 
-// Run starts the interactive loop. It blocks until the context is cancelled or the process is terminated.
+// renderPrompts renders the system prompt and the protocol for the current mode,
+// they are rendered again when the mode falls back to markers.
+func (agent *AgentInterviewer) renderPrompts() {
+	data := InterviewerPromptData{
+		Mode: agent.mode,
+		Markers: PromptMarkers{
+			ProvideDataStart: agent.zProvideDataStart,
+			ProvideDataEnd:   agent.zProvideDataEnd,
+			CollectDataStart: agent.zCollectDataStart,
+			CollectDataEnd:   agent.zCollectDataEnd,
+			RspStart:         agent.zRspStart,
+			RspEnd:           agent.zRspEnd,
+		},
+		Format:   agent.zRspFormat,
+		Template: string(agent.schema.Template),
+	}
+	agent.sysPrompt = agent.prompts.mustRender(PromptInterviewerSystem, data)
+	agent.basicPrompt = agent.prompts.mustRender(PromptInterviewerProtocol, data)
+}
+
+// Run starts the interactive terminal loop. It blocks until the context is cancelled or the process is terminated.
 func (agent *AgentInterviewer) Run(ctx context.Context) error {
+	if agent.session != nil {
+		fmt.Printf("session=%s, continue it later with: advent interview -resume %s\n", agent.session.Meta.ID, agent.session.Meta.ID)
+		if len(agent.dialog) > 0 {
+			fmt.Printf("resumed dialog=%s\n", agent.dialogString())
+		}
+	}
+
+	events := terminalEvents(os.Stdout)
 	for {
 		select {
 		case <-ctx.Done():
@@ -133,63 +216,260 @@ func (agent *AgentInterviewer) Run(ctx context.Context) error {
 		default:
 		}
 
-		fmt.Print("\nПешы: ")
-		userInput, _ := agent.reader.ReadString('\n')
-		agent.zDialog = fmt.Sprintf("%s\n%s\n%s\n%s\n", agent.zDialog, agent.zProvideDataStart, strings.TrimSpace(userInput), agent.zProvideDataEnd)
-		fmt.Printf("after provide zDialog=%s\n", agent.zDialog)
-
-		escaped, err := json.Marshal(agent.zDialog)
-		if err != nil {
+		var userInput string
+		// the end of the input ends the dialog, after a last line without a newline is answered
+		eof := false
+		if !agent.awaitingModel() {
+			fmt.Print("\nПешы: ")
+			var err error
+			if userInput, err = agent.readInput(ctx); err != nil {
+				switch {
+				case ctx.Err() != nil:
+					return ctx.Err()
+				case !errors.Is(err, io.EOF):
+					return fmt.Errorf("read user input: %w", err)
+				case strings.TrimSpace(userInput) == "":
+					fmt.Println()
+					return nil
+				}
+				eof = true
+			}
+		}
+		if err := agent.Step(ctx, strings.TrimSpace(userInput), events); err != nil {
 			return err
 		}
+		if eof {
+			return nil
+		}
+	}
+}
 
-		resp, err := agent.client.CreateChatCompletion(
-			context.Background(),
-			openrouter.ChatCompletionRequest{
-				Model: "deepseek/deepseek-chat-v3-0324:free",
-				Messages: []openrouter.ChatCompletionMessage{
-					{Role: openrouter.ChatMessageRoleSystem, Content: openrouter.Content{Text: agent.sysPrompt}},
-					{Role: openrouter.ChatMessageRoleUser, Content: openrouter.Content{Text: string(escaped)}},
-				},
-			},
-		)
+// readInput reads a line from the reader, or returns ctx.Err() when ctx is done first. The read itself
+// can't be interrupted, it is left to finish with the next line or the end of the input.
+func (agent *AgentInterviewer) readInput(ctx context.Context) (string, error) {
+	type readResult struct {
+		line string
+		err  error
+	}
+	done := make(chan readResult, 1)
+	go func() {
+		line, err := agent.reader.ReadString('\n')
+		done <- readResult{line: line, err: err}
+	}()
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case result := <-done:
+		return result.line, result.err
+	}
+}
+
+// Step adds userInput to the dialog and talks to the model until it asks a clarifying question,
+// or the response is finalized or the dialog is reset. An empty userInput is skipped when the dialog
+// already waits for the model, e.g. after a resume. When a model call fails, the turns of the Step
+// are rolled back, so the input can be sent again. emit, which may be nil, receives the progress.
+// Steps of one interviewer must not run concurrently.
+func (agent *AgentInterviewer) Step(ctx context.Context, userInput string, emit func(InterviewerEvent)) error {
+	agent.emit = emit
+	defer func() { agent.emit = nil }()
+	if agent.session != nil {
+		ctx = WithUsageSession(ctx, agent.session.Meta.ID)
+	}
+
+	agent.stepTurns = 0
+	if err := agent.step(ctx, userInput); err != nil {
+		agent.rollbackStep(err)
+		return err
+	}
+	return nil
+}
+
+func (agent *AgentInterviewer) step(ctx context.Context, userInput string) error {
+	if userInput != "" || !agent.awaitingModel() {
+		agent.addUserInput(userInput)
+		fmt.Printf("after provide dialog=%s\n", agent.dialogString())
+	}
+
+	badQuestions := 0
+	for {
+		resp, err := agent.chat(ctx)
 		if err != nil {
 			return err
 		}
 
-		respStr := resp.Choices[0].Message.Content.Text
-		if strings.Contains(respStr, agent.zCollectDataStart) && strings.Contains(respStr, agent.zCollectDataEnd) {
-			fmt.Printf("zCollectData respStr=%s\n", respStr)
-			agent.zDialog = fmt.Sprintf("%s\n%s\n", agent.zDialog, respStr)
-			fmt.Printf("after collect zDialog=%s\n", agent.zDialog)
+		// a malformed submit_response goes through the repair loop below like any invalid Z_RSP
+		turn, err := agent.classifyTurn(resp)
+		if err != nil && turn.kind == turnCollectData {
+			badQuestions++
+			if badQuestions > agent.maxRepairs {
+				fmt.Printf("%v %d times, reset\n", err, badQuestions)
+				agent.reset(fmt.Sprintf("%s arguments invalid %d times", toolAskUser, badQuestions))
+				return nil
+			}
+			fmt.Printf("%v, asking again\n", err)
+			agent.addRejectedQuestion(turn, fmt.Sprintf("%v\nCall %s again with valid JSON arguments.", err, toolAskUser))
 			continue
 		}
 
-		if strings.Contains(respStr, agent.zRspStart) && strings.Contains(respStr, agent.zRspEnd) {
-			respStrCut, err := cutN2(respStr, agent.zRspStart, agent.zRspEnd)
-			if err != nil {
-				return err
-			}
-			fmt.Printf("zRsp respStrCut=%s\n", respStrCut)
+		if turn.kind == turnCollectData {
+			fmt.Printf("zCollectData respStr=%s\n", turn.text)
+			agent.addQuestion(turn)
+			fmt.Printf("after collect dialog=%s\n", agent.dialogString())
+			agent.notify(InterviewerEvent{Type: EventQuestion, Text: turn.text, Model: turn.model})
+			return nil
+		}
 
-			var structuredRsp ZRsp
-			if err := json.Unmarshal([]byte(respStrCut), &structuredRsp); err != nil {
-				return err
+		if turn.kind != turnResponse {
+			fmt.Printf("neither zRsp or zCollectData, reset; respStr=%s\n", turn.text)
+			agent.reset("neither Z_RSP nor Z_COLLECT_DATA")
+			return nil
+		}
+
+		structuredRsp, turn, err := agent.parseResponse(ctx, turn, turn.model)
+		if errors.Is(err, ErrResponseInvalid) {
+			fmt.Printf("%v, reset\n", err)
+			agent.reset(err.Error())
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if agent.units != nil {
+			if structuredRsp, err = agent.units.NormalizeResponse(agent.schema, structuredRsp); err != nil {
+				fmt.Printf("%v, kept as is\n", err)
 			}
-			fmt.Printf("structuredRsp=%v\n", structuredRsp)
+		}
+		fmt.Printf("structuredRsp=%s\n", structuredRsp.Raw)
 
-			// Send to inspector and wait for inspection
-			if agent.inspector != nil {
-				if err := agent.inspector.Inspect(ctx, structuredRsp); err != nil {
-					return err
-				}
+		verdict := agent.inspect(ctx, structuredRsp)
+		if agent.session != nil {
+			if err := agent.session.AppendResponse(structuredRsp, verdict, turn.model); err != nil {
+				fmt.Printf("session: %v\n", err)
 			}
-			agent.zDialog = agent.basicPrompt
-			continue
 		}
+		accepted := verdict == nil || verdict.Approved
+		agent.notify(InterviewerEvent{Type: EventResponse, Response: &structuredRsp, Verdict: verdict, Accepted: accepted, Model: turn.model})
+		if !accepted {
+			agent.rejections++
+			if agent.rejections <= agent.maxRejections {
+				// back to Z_COLLECT_DATA: the model asks about the issues before answering again
+				agent.addRejectedResponse(turn, agent.inspectionPrompt(*verdict))
+				continue
+			}
+			fmt.Printf("inspector rejected %d times, reset\n", agent.rejections)
+			agent.reset(fmt.Sprintf("inspector rejected %d times", agent.rejections))
+			return nil
+		}
+		agent.deliver(ctx, structuredRsp, verdict, turn.model)
+		agent.resetDialog("response finalized")
+		return nil
+	}
+}
 
-		// Keep the same fallback/reset behavior as 1agent1user.go
-		fmt.Printf("neither zRsp or zCollectData, reset; respStr=%s\n", respStr)
-		agent.zDialog = agent.basicPrompt
+// reset drops the dialog and tells the frontend why.
+func (agent *AgentInterviewer) reset(reason string) {
+	agent.resetDialog(reason)
+	agent.notify(InterviewerEvent{Type: EventReset, Text: reason})
+}
+
+// Close closes the session log, if any.
+func (agent *AgentInterviewer) Close() error {
+	if agent.session == nil {
+		return nil
+	}
+	return agent.session.Close()
+}
+
+// deliver writes an accepted response to the sinks. model is the model that answered, it is
+// recorded instead of the configured models of the session.
+func (agent *AgentInterviewer) deliver(ctx context.Context, resp StructuredResponse, verdict *Verdict, model string) {
+	if len(agent.sinks) == 0 {
+		return
+	}
+	meta := SessionMeta{Schema: agent.schema.Name, Mode: agent.mode}
+	if agent.session != nil {
+		meta = agent.session.Meta
+	}
+	meta.Model = model
+	record := SinkRecord{Session: meta, AcceptedAt: time.Now(), Response: resp, Verdict: verdict}
+	if err := WriteSinks(ctx, agent.sinks, record); err != nil {
+		fmt.Printf("sinks: %v\n", err)
+	}
+}
+
+// chat sends the dialog in the current mode. If the model rejects tools or response_format,
+// the interviewer falls back to the marker protocol for the rest of the session. Other errors,
+// such as rate limits or network failures, are returned and the mode is kept.
+func (agent *AgentInterviewer) chat(ctx context.Context) (ChatResponse, error) {
+	var req ChatRequest
+	var err error
+	switch agent.mode {
+	case InterviewerModeTools:
+		if req.Tools, err = interviewerTools(agent.schema); err != nil {
+			return ChatResponse{}, err
+		}
+		req.ToolChoice = ChatToolChoiceRequired
+	case InterviewerModeJSON:
+		if req.ResponseFormat, err = interviewerResponseFormat(agent.schema); err != nil {
+			return ChatResponse{}, err
+		}
+	}
+
+	agent.fitContext(ctx, req)
+	req.Messages = agent.messages()
+
+	resp, err := agent.complete(ctx, req)
+	if err == nil || agent.mode == InterviewerModeMarkers || !unsupportedModeError(err) {
+		return resp, err
+	}
+
+	fmt.Printf("%s mode failed, falling back to %s: %v\n", agent.mode, InterviewerModeMarkers, err)
+	agent.mode = InterviewerModeMarkers
+	agent.renderPrompts()
+	return agent.chat(ctx)
+}
+
+func (agent *AgentInterviewer) complete(ctx context.Context, req ChatRequest) (ChatResponse, error) {
+	if !agent.stream {
+		return agent.model.Chat(ctx, req)
+	}
+	printer := agent.newStreamPrinter(agent.notify)
+	resp, err := ChatOrStream(ctx, agent.model, req, printer.Write)
+	printer.Close()
+	return resp, err
+}
+
+// inspect returns nil when there is no inspector or it failed, a failing inspector does not block the dialog.
+func (agent *AgentInterviewer) inspect(ctx context.Context, resp StructuredResponse) *Verdict {
+	if agent.inspector == nil {
+		return nil
+	}
+	verdict, err := agent.inspector.Inspect(ctx, resp)
+	if err != nil {
+		fmt.Printf("inspector: %v, response kept\n", err)
+		return nil
+	}
+	fmt.Printf("verdict: approved=%t by %s\n", verdict.Approved, verdict.Inspector)
+	for _, issue := range verdict.Issues {
+		fmt.Printf("  issue %s\n", issue)
+	}
+	return &verdict
+}
+
+// inspectionPrompt turns a rejection into instructions to ask Z_USER about each issue.
+func (agent *AgentInterviewer) inspectionPrompt(verdict Verdict) string {
+	var sb strings.Builder
+	sb.WriteString("Z_INSPECTOR rejected Z_RSP above. Issues:\n")
+	for _, issue := range verdict.Issues {
+		sb.WriteString("- " + issue.String() + "\n")
+	}
+	switch agent.mode {
+	case InterviewerModeTools:
+		sb.WriteString("Call ask_user with targeted questions about these issues, then call submit_response with the corrected payload.")
+	case InterviewerModeJSON:
+		sb.WriteString(`Ask Z_USER targeted questions about these issues with {"action":"ask_user",...}, then submit the corrected payload.`)
+	default:
+		sb.WriteString("Ask Z_USER targeted Z_COLLECT_DATA questions about these issues, then answer with the corrected Z_RSP.")
 	}
+	return sb.String()
 }
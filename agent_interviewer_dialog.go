@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Protocol roles of stored dialog turns.
+const (
+	dialogKindProvideData = "provide_data"
+	dialogKindCollectData = "collect_data"
+	dialogKindResponse    = "response"
+	dialogKindFeedback    = "feedback"
+)
+
+// dialogTurn is a dialog message together with the protocol role it plays,
+// so it can be rendered differently per mode.
+type dialogTurn struct {
+	Kind    string      `json:"kind"`
+	Message ChatMessage `json:"message"`
+	// Model is the model that wrote an assistant turn, it may be a fallback of the configured one.
+	Model string `json:"model,omitempty"`
+}
+
+// addUserInput appends Z_PROVIDE_DATA. It answers the pending ask_user call when there is one.
+func (agent *AgentInterviewer) addUserInput(text string) {
+	agent.appendTurns(dialogTurn{Kind: dialogKindProvideData, Message: agent.replyMessage(text)})
+}
+
+// addQuestion appends Z_COLLECT_DATA, keeping the ask_user call it came from.
+func (agent *AgentInterviewer) addQuestion(turn interviewerTurn) {
+	agent.appendTurns(dialogTurn{Kind: dialogKindCollectData, Message: assistantMessage(turn.text, turn.toolCall), Model: turn.model})
+}
+
+// addRejectedResponse appends a Z_RSP that could not be used and the feedback explaining why.
+func (agent *AgentInterviewer) addRejectedResponse(turn interviewerTurn, feedback string) {
+	agent.appendTurns(dialogTurn{Kind: dialogKindResponse, Message: assistantMessage(turn.payload, turn.toolCall), Model: turn.model})
+	agent.appendTurns(dialogTurn{Kind: dialogKindFeedback, Message: agent.replyMessage(feedback)})
+}
+
+// addRejectedQuestion appends a clarifying question that could not be used and the feedback explaining why.
+func (agent *AgentInterviewer) addRejectedQuestion(turn interviewerTurn, feedback string) {
+	agent.appendTurns(dialogTurn{Kind: dialogKindCollectData, Message: assistantMessage(turn.text, turn.toolCall), Model: turn.model})
+	agent.appendTurns(dialogTurn{Kind: dialogKindFeedback, Message: agent.replyMessage(feedback)})
+}
+
+// appendTurns adds turns to the dialog and writes them to the session log.
+func (agent *AgentInterviewer) appendTurns(turns ...dialogTurn) {
+	agent.dialog = append(agent.dialog, turns...)
+	agent.stepTurns += len(turns)
+	if agent.session == nil {
+		return
+	}
+	for _, turn := range turns {
+		if err := agent.session.AppendTurn(turn); err != nil {
+			fmt.Printf("session: %v\n", err)
+		}
+	}
+}
+
+// resetDialog starts a new Z_DIALOG.
+func (agent *AgentInterviewer) resetDialog(reason string) {
+	agent.dialog = nil
+	agent.rejections = 0
+	agent.stepTurns = 0
+	if agent.session == nil {
+		return
+	}
+	if err := agent.session.Reset(reason); err != nil {
+		fmt.Printf("session: %v\n", err)
+	}
+}
+
+// rollbackStep drops the turns of a failed Step. Compaction only replaces older turns,
+// so the turns of the Step are still the last ones.
+func (agent *AgentInterviewer) rollbackStep(cause error) {
+	count := min(agent.stepTurns, len(agent.dialog))
+	agent.stepTurns = 0
+	if count == 0 {
+		return
+	}
+	rollback := dialogCompaction{From: len(agent.dialog) - count, Count: count}
+	agent.dialog = rollback.apply(agent.dialog)
+	fmt.Printf("step failed, rolled back %d turns: %v\n", count, cause)
+	if agent.session == nil {
+		return
+	}
+	if err := agent.session.Rollback(rollback, cause.Error()); err != nil {
+		fmt.Printf("session: %v\n", err)
+	}
+}
+
+// awaitingModel reports whether the last turn is a user or tool message the model has not answered yet,
+// which is the case when a session is resumed after a crash.
+func (agent *AgentInterviewer) awaitingModel() bool {
+	n := len(agent.dialog)
+	return n > 0 && agent.dialog[n-1].Message.Role != ChatRoleAssistant
+}
+
+func assistantMessage(content string, toolCall *ChatToolCall) ChatMessage {
+	msg := ChatMessage{Role: ChatRoleAssistant, Content: content}
+	if toolCall != nil {
+		msg.ToolCalls = []ChatToolCall{*toolCall}
+	}
+	return msg
+}
+
+// replyMessage is a user message, or the tool result of the last assistant tool call.
+func (agent *AgentInterviewer) replyMessage(text string) ChatMessage {
+	msg := ChatMessage{Role: ChatRoleUser, Content: text}
+	if n := len(agent.dialog); n > 0 && len(agent.dialog[n-1].Message.ToolCalls) > 0 {
+		msg.Role = ChatRoleTool
+		msg.ToolCallID = agent.dialog[n-1].Message.ToolCalls[0].ID
+	}
+	return msg
+}
+
+// messages renders the system prompt and the dialog for the current mode.
+func (agent *AgentInterviewer) messages() []ChatMessage {
+	messages := make([]ChatMessage, 0, len(agent.dialog)+1)
+	messages = append(messages, ChatMessage{
+		Role:    ChatRoleSystem,
+		Content: agent.sysPrompt + "\n\n" + agent.basicPrompt + "\n",
+	})
+	for _, turn := range agent.dialog {
+		messages = append(messages, agent.formatMessage(turn))
+	}
+	return messages
+}
+
+// formatMessage applies marker wrapping and, outside of tools mode, turns tool calls
+// and tool results back into plain assistant and user messages.
+func (agent *AgentInterviewer) formatMessage(turn dialogTurn) ChatMessage {
+	msg := turn.Message
+	toolsMode := agent.mode == InterviewerModeTools
+
+	if msg.Role == ChatRoleUser || msg.Role == ChatRoleTool {
+		if !toolsMode {
+			msg.Role = ChatRoleUser
+			msg.ToolCallID = ""
+		}
+		if turn.Kind == dialogKindProvideData && agent.wrapMarkers {
+			msg.Content = wrapMarkers(agent.zProvideDataStart, msg.Content, agent.zProvideDataEnd)
+		}
+		return msg
+	}
+
+	if toolsMode && len(msg.ToolCalls) > 0 {
+		msg.Content = ""
+		return msg
+	}
+	msg.ToolCalls = nil
+
+	switch {
+	case agent.mode == InterviewerModeJSON:
+		// show the model its earlier answers in the envelope it has to produce
+		envelope := jsonEnvelope{Action: toolAskUser, Question: msg.Content}
+		if turn.Kind == dialogKindResponse {
+			envelope = jsonEnvelope{Action: toolSubmitResponse, Payload: json.RawMessage(msg.Content)}
+		}
+		if data, err := json.Marshal(envelope); err == nil {
+			msg.Content = string(data)
+		}
+	case turn.Kind == dialogKindResponse:
+		msg.Content = wrapMarkers(agent.zRspStart, msg.Content, agent.zRspEnd)
+	case agent.wrapMarkers:
+		msg.Content = wrapMarkers(agent.zCollectDataStart, msg.Content, agent.zCollectDataEnd)
+	}
+	return msg
+}
+
+func wrapMarkers(start, content, end string) string {
+	return fmt.Sprintf("%s\n%s\n%s", start, content, end)
+}
+
+func (agent *AgentInterviewer) dialogString() string {
+	var sb strings.Builder
+	for _, turn := range agent.dialog {
+		fmt.Fprintf(&sb, "\n[%s] %s", turn.Kind, turn.Message.Content)
+	}
+	return sb.String()
+}
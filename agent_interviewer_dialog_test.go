@@ -0,0 +1,79 @@
+package main
+
+import "testing"
+
+func TestMessagesPerMode(t *testing.T) {
+	call := &ChatToolCall{ID: "call-1", Name: toolAskUser, Arguments: `{"question":"Which car?"}`}
+	markers := defaultPromptMarkers
+	tests := []struct {
+		name string
+		opts []InterviewerOption
+		want []ChatMessage
+	}{
+		{
+			name: "markers",
+			want: []ChatMessage{
+				{Role: ChatRoleUser, Content: wrapMarkers(markers.ProvideDataStart, "I have a car", markers.ProvideDataEnd)},
+				{Role: ChatRoleAssistant, Content: wrapMarkers(markers.CollectDataStart, "Which car?", markers.CollectDataEnd)},
+				{Role: ChatRoleUser, Content: wrapMarkers(markers.ProvideDataStart, "Audi TT", markers.ProvideDataEnd)},
+				{Role: ChatRoleAssistant, Content: wrapMarkers(markers.RspStart, validRepairPayload, markers.RspEnd)},
+				{Role: ChatRoleUser, Content: "fix the units"},
+			},
+		},
+		{
+			name: "markers without wrapping",
+			opts: []InterviewerOption{WithMarkerWrapping(false)},
+			want: []ChatMessage{
+				{Role: ChatRoleUser, Content: "I have a car"},
+				{Role: ChatRoleAssistant, Content: "Which car?"},
+				{Role: ChatRoleUser, Content: "Audi TT"},
+				// the answer keeps its markers, they are the protocol
+				{Role: ChatRoleAssistant, Content: wrapMarkers(markers.RspStart, validRepairPayload, markers.RspEnd)},
+				{Role: ChatRoleUser, Content: "fix the units"},
+			},
+		},
+		{
+			name: "tools",
+			opts: []InterviewerOption{WithInterviewerMode(InterviewerModeTools), WithMarkerWrapping(false)},
+			want: []ChatMessage{
+				{Role: ChatRoleUser, Content: "I have a car"},
+				{Role: ChatRoleAssistant, ToolCalls: []ChatToolCall{*call}},
+				{Role: ChatRoleTool, Content: "Audi TT", ToolCallID: "call-1"},
+				{Role: ChatRoleAssistant, Content: wrapMarkers(markers.RspStart, validRepairPayload, markers.RspEnd)},
+				{Role: ChatRoleUser, Content: "fix the units"},
+			},
+		},
+		{
+			name: "json",
+			opts: []InterviewerOption{WithInterviewerMode(InterviewerModeJSON), WithMarkerWrapping(false)},
+			want: []ChatMessage{
+				{Role: ChatRoleUser, Content: "I have a car"},
+				{Role: ChatRoleAssistant, Content: `{"action":"ask_user","question":"Which car?"}`},
+				{Role: ChatRoleUser, Content: "Audi TT"},
+				{Role: ChatRoleAssistant, Content: `{"action":"submit_response","payload":` + validRepairPayload + `}`},
+				{Role: ChatRoleUser, Content: "fix the units"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			agent := NewAgentInterviewer(unreachableChatModel{}, nil, MustDefaultResponseSchema(), append(tt.opts, WithoutInspector())...)
+			// the dialog is the same in every mode, the question came from an ask_user call
+			agent.addUserInput("I have a car")
+			agent.addQuestion(interviewerTurn{kind: turnCollectData, text: "Which car?", toolCall: call})
+			agent.addUserInput("Audi TT")
+			agent.addRejectedResponse(interviewerTurn{kind: turnResponse, payload: validRepairPayload}, "fix the units")
+
+			got := agent.messages()
+			if len(got) != len(tt.want)+1 || got[0].Role != ChatRoleSystem {
+				t.Fatalf("messages %+v, want the system prompt and %d turns", got, len(tt.want))
+			}
+			for i, want := range tt.want {
+				msg := got[i+1]
+				if msg.Role != want.Role || msg.Content != want.Content || msg.ToolCallID != want.ToolCallID || len(msg.ToolCalls) != len(want.ToolCalls) {
+					t.Errorf("message %d = %+v, want %+v", i+1, msg, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// Types of interviewer events.
+const (
+	// EventDelta is visible text of the answer being streamed.
+	EventDelta = "delta"
+	// EventDone ends a streamed answer.
+	EventDone = "done"
+	// EventQuestion is a clarifying question, the interviewer waits for the user's reply.
+	EventQuestion = "question"
+	// EventResponse is a finalized response with the inspector verdict.
+	EventResponse = "response"
+	// EventReset means the dialog was dropped and starts over.
+	EventReset = "reset"
+)
+
+// InterviewerEvent tells a frontend what the interviewer is doing during a Step.
+type InterviewerEvent struct {
+	Type string `json:"type"`
+	Text string `json:"text,omitempty"`
+	// Response and Verdict are set for EventResponse, Verdict is nil when there is no inspector.
+	Response *StructuredResponse `json:"response,omitempty"`
+	Verdict  *Verdict            `json:"verdict,omitempty"`
+	// Accepted is true when the response was finalized, false when it goes back to collecting data.
+	Accepted bool `json:"accepted,omitempty"`
+	// Model is the model that asked the question or gave the response.
+	Model string `json:"model,omitempty"`
+}
+
+func (agent *AgentInterviewer) notify(event InterviewerEvent) {
+	if agent.emit != nil {
+		agent.emit(event)
+	}
+}
+
+// terminalEvents prints the streamed answer to out as "Z_AI: ...", the rest is already logged.
+func terminalEvents(out io.Writer) func(InterviewerEvent) {
+	answering := false
+	return func(event InterviewerEvent) {
+		switch event.Type {
+		case EventDelta:
+			if !answering {
+				fmt.Fprint(out, "\nZ_AI: ")
+				answering = true
+			}
+			fmt.Fprint(out, event.Text)
+		case EventDone:
+			if answering {
+				fmt.Fprintln(out)
+				answering = false
+			}
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// ErrResponseInvalid is returned when Z_RSP stays invalid after all repair attempts.
+var ErrResponseInvalid = errors.New("z_rsp invalid")
+
+// RepairAttempt records one round of sending an invalid Z_RSP back to the model.
+type RepairAttempt struct {
+	Time    time.Time `json:"time"`
+	Schema  string    `json:"schema"`
+	Mode    string    `json:"mode"`
+	Attempt int       `json:"attempt"`
+	// Model produced the invalid payload, RepairModel answered the repair request.
+	Model       string `json:"model"`
+	RepairModel string `json:"repairModel"`
+	Error       string `json:"error"`
+	Repaired    bool   `json:"repaired"`
+}
+
+// parseResponse validates a Z_RSP payload. When it is invalid, the error and the payload are sent
+// back to the model, up to maxRepairs times, before giving up. It returns the turn that carried the
+// valid payload, or turn itself on errors.
+func (agent *AgentInterviewer) parseResponse(ctx context.Context, turn interviewerTurn, model string) (StructuredResponse, interviewerTurn, error) {
+	original := turn
+	structuredRsp, err := agent.schema.Parse([]byte(turn.payload))
+	for attempt := 1; err != nil; attempt++ {
+		if attempt > agent.maxRepairs {
+			return StructuredResponse{}, original, fmt.Errorf("%w after %d repair attempts: %w", ErrResponseInvalid, agent.maxRepairs, err)
+		}
+
+		record := RepairAttempt{
+			Time:    time.Now(),
+			Schema:  agent.schema.Name,
+			Mode:    agent.mode,
+			Attempt: attempt,
+			Model:   model,
+			Error:   err.Error(),
+		}
+		fmt.Printf("zRsp invalid, repair attempt %d/%d: %v\n", attempt, agent.maxRepairs, err)
+
+		agent.addRejectedResponse(turn, agent.repairPrompt(err))
+		resp, chatErr := agent.chat(ctx)
+		if chatErr != nil {
+			return StructuredResponse{}, original, chatErr
+		}
+		record.RepairModel = resp.Model
+		model = resp.Model
+
+		next, classifyErr := agent.classifyTurn(resp)
+		switch {
+		case classifyErr != nil:
+			err = classifyErr
+			turn = repairAnswerTurn(resp, next)
+		case next.kind != turnResponse:
+			err = fmt.Errorf("repair answer has no Z_RSP")
+			turn = repairAnswerTurn(resp, next)
+		default:
+			turn = next
+			fmt.Printf("zRsp repair respStrCut=%s\n", turn.payload)
+			structuredRsp, err = agent.schema.Parse([]byte(turn.payload))
+		}
+
+		record.Repaired = err == nil
+		agent.recordRepair(record)
+	}
+	return structuredRsp, turn, nil
+}
+
+// repairAnswerTurn is a repair answer without a usable Z_RSP, to be rejected in turn. It keeps the
+// tool call of the answer, so the next feedback is the tool message that answers it.
+func repairAnswerTurn(resp ChatResponse, classified interviewerTurn) interviewerTurn {
+	toolCall := classified.toolCall
+	if toolCall == nil && len(resp.ToolCalls) > 0 {
+		toolCall = &resp.ToolCalls[0]
+	}
+	return interviewerTurn{kind: turnResponse, payload: resp.Text, toolCall: toolCall, model: resp.Model}
+}
+
+func (agent *AgentInterviewer) repairPrompt(err error) string {
+	answer := fmt.Sprintf("Answer again only with the corrected Z_RSP between %s and %s.", agent.zRspStart, agent.zRspEnd)
+	switch agent.mode {
+	case InterviewerModeTools:
+		answer = "Call submit_response again with the corrected payload."
+	case InterviewerModeJSON:
+		answer = `Answer again with {"action":"submit_response","payload":...} containing the corrected Z_RSP.`
+	}
+	return fmt.Sprintf("Z_RSP above can't be deserialized: %v\nIt must strictly match Z_RSP_FORMAT and Z_RSP_TEMPLATE. %s", err, answer)
+}
+
+func (agent *AgentInterviewer) recordRepair(record RepairAttempt) {
+	agent.repairs = append(agent.repairs, record)
+	line, err := json.Marshal(record)
+	if err != nil {
+		fmt.Printf("marshal repair attempt: %v\n", err)
+		return
+	}
+	fmt.Printf("repairAttempt=%s\n", line)
+
+	if agent.repairLogPath == "" {
+		return
+	}
+	file, err := os.OpenFile(agent.repairLogPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		fmt.Printf("open repair log: %v\n", err)
+		return
+	}
+	defer file.Close()
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		fmt.Printf("write repair log: %v\n", err)
+	}
+}
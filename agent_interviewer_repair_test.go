@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+// scriptedChatModel answers with responses in order and fails once they run out.
+type scriptedChatModel struct {
+	mu        sync.Mutex
+	responses []ChatResponse
+	requests  []ChatRequest
+}
+
+func (m *scriptedChatModel) Chat(_ context.Context, req ChatRequest) (ChatResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests = append(m.requests, req)
+	if len(m.responses) == 0 {
+		return ChatResponse{}, errors.New("script exhausted")
+	}
+	resp := m.responses[0]
+	m.responses = m.responses[1:]
+	return resp, nil
+}
+
+const (
+	validRepairPayload   = `{"items":[{"itemType":"car","itemName":"TT-34","value1Name":"speed","value1Units":"km/h","value1":"180"}]}`
+	invalidRepairPayload = `{"items":"TT-34"}`
+)
+
+// checkToolCallsAnswered fails unless every assistant tool call is answered by the tool message right after it.
+func checkToolCallsAnswered(t *testing.T, messages []ChatMessage) {
+	t.Helper()
+	for i, msg := range messages {
+		if len(msg.ToolCalls) == 0 {
+			continue
+		}
+		if i+1 >= len(messages) || messages[i+1].Role != ChatRoleTool || messages[i+1].ToolCallID != msg.ToolCalls[0].ID {
+			t.Errorf("tool call %s of message %d is not answered by the next message", msg.ToolCalls[0].ID, i)
+		}
+	}
+}
+
+func TestParseResponseRepair(t *testing.T) {
+	submit := func(id, payload string) ChatToolCall {
+		return ChatToolCall{ID: id, Name: toolSubmitResponse, Arguments: `{"payload":` + payload + `}`}
+	}
+	firstCall := submit("call-1", invalidRepairPayload)
+	first := interviewerTurn{kind: turnResponse, payload: invalidRepairPayload, toolCall: &firstCall, model: "first"}
+
+	tests := []struct {
+		name      string
+		responses []ChatResponse
+		// wantCall is the tool call of the returned turn, empty when parseResponse fails.
+		wantCall string
+		wantErr  error
+	}{
+		{
+			name: "repaired after a question",
+			responses: []ChatResponse{
+				{Model: "m", ToolCalls: []ChatToolCall{{ID: "call-2", Name: toolAskUser, Arguments: `{"question":"which car?"}`}}},
+				{Model: "m", ToolCalls: []ChatToolCall{submit("call-3", validRepairPayload)}},
+			},
+			wantCall: "call-3",
+		},
+		{
+			name: "repaired after broken arguments",
+			responses: []ChatResponse{
+				{Model: "m", ToolCalls: []ChatToolCall{{ID: "call-2", Name: toolSubmitResponse, Arguments: `{"payload":`}}},
+				{Model: "m", ToolCalls: []ChatToolCall{submit("call-3", validRepairPayload)}},
+			},
+			wantCall: "call-3",
+		},
+		{
+			name: "still invalid",
+			responses: []ChatResponse{
+				{Model: "m", ToolCalls: []ChatToolCall{submit("call-2", invalidRepairPayload)}},
+				{Model: "m", ToolCalls: []ChatToolCall{{ID: "call-3", Name: toolSubmitResponse, Arguments: "nonsense"}}},
+			},
+			wantErr: ErrResponseInvalid,
+		},
+		{
+			name: "model fails",
+			responses: []ChatResponse{
+				{Model: "m", ToolCalls: []ChatToolCall{{ID: "call-2", Name: toolSubmitResponse, Arguments: "nonsense"}}},
+			},
+			wantErr: errors.New("script exhausted"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			model := &scriptedChatModel{responses: tt.responses}
+			agent := NewAgentInterviewer(model, nil, MustDefaultResponseSchema(),
+				WithInterviewerMode(InterviewerModeTools), WithRepairs(2, ""))
+
+			_, turn, err := agent.parseResponse(context.Background(), first, first.model)
+			switch {
+			case tt.wantErr == nil && err != nil:
+				t.Fatal(err)
+			case tt.wantErr != nil && err == nil:
+				t.Fatalf("parseResponse succeeded, want %v", tt.wantErr)
+			case errors.Is(tt.wantErr, ErrResponseInvalid) && !errors.Is(err, ErrResponseInvalid):
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+
+			wantCall := tt.wantCall
+			if tt.wantErr != nil {
+				wantCall = firstCall.ID
+				if turn.payload != first.payload || turn.model != first.model {
+					t.Errorf("failed parseResponse returned turn %+v, want the original turn", turn)
+				}
+			}
+			if turn.toolCall == nil || turn.toolCall.ID != wantCall {
+				t.Errorf("returned turn has tool call %+v, want %s", turn.toolCall, wantCall)
+			}
+			for _, req := range model.requests {
+				checkToolCallsAnswered(t, req.Messages)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"strings"
+)
+
+// streamBlock is a delimited part of a streamed answer, show selects whether its content is printed.
+type streamBlock struct {
+	start string
+	end   string
+	show  bool
+	// notice is printed once the block starts, instead of the content of a hidden block.
+	notice string
+}
+
+// streamPrinter emits a streamed marker-protocol answer as it arrives. Clarifying questions and
+// free text are printed without markers, reasoning blocks are skipped and Z_RSP payloads are held
+// back, they are shown only after they were parsed.
+type streamPrinter struct {
+	emit    func(InterviewerEvent)
+	blocks  []streamBlock
+	hideAll bool
+
+	pending string
+	open    *streamBlock
+	printed bool
+}
+
+func (agent *AgentInterviewer) newStreamPrinter(emit func(InterviewerEvent)) *streamPrinter {
+	blocks := []streamBlock{
+		{start: agent.zCollectDataStart, end: agent.zCollectDataEnd, show: true},
+		{start: agent.zRspStart, end: agent.zRspEnd, notice: "(Z_RSP received, checking it)"},
+	}
+	for _, tag := range reasoningTags {
+		blocks = append(blocks, streamBlock{start: tag[0], end: tag[1]})
+	}
+	return &streamPrinter{
+		emit:   emit,
+		blocks: blocks,
+		// the JSON envelope carries the payload, it is never printed raw
+		hideAll: agent.mode == InterviewerModeJSON,
+	}
+}
+
+// Write consumes the next streamed chunk.
+func (p *streamPrinter) Write(delta string) {
+	p.pending += delta
+	for {
+		if p.open == nil {
+			idx, block := p.nextStart()
+			if block == nil {
+				keep := p.partialMarkerLen(p.startMarkers())
+				p.print(p.pending[:len(p.pending)-keep])
+				p.pending = p.pending[len(p.pending)-keep:]
+				return
+			}
+			p.print(p.pending[:idx])
+			p.pending = p.pending[idx+len(block.start):]
+			p.open = block
+			if block.notice != "" {
+				p.print(block.notice)
+			}
+			continue
+		}
+
+		idx := strings.Index(p.pending, p.open.end)
+		if idx < 0 {
+			keep := p.partialMarkerLen([]string{p.open.end})
+			if p.open.show {
+				p.print(p.pending[:len(p.pending)-keep])
+			}
+			p.pending = p.pending[len(p.pending)-keep:]
+			return
+		}
+		if p.open.show {
+			p.print(p.pending[:idx])
+		}
+		p.pending = p.pending[idx+len(p.open.end):]
+		p.open = nil
+	}
+}
+
+// Close emits what is left of an unterminated question or free text.
+func (p *streamPrinter) Close() {
+	if p.open == nil || p.open.show {
+		p.print(p.pending)
+	}
+	p.pending = ""
+	if p.printed {
+		p.emit(InterviewerEvent{Type: EventDone})
+	}
+}
+
+func (p *streamPrinter) print(s string) {
+	if p.hideAll || s == "" || (!p.printed && strings.TrimSpace(s) == "") {
+		return
+	}
+	if !p.printed {
+		s = strings.TrimLeft(s, " \n")
+		p.printed = true
+	}
+	p.emit(InterviewerEvent{Type: EventDelta, Text: s})
+}
+
+func (p *streamPrinter) nextStart() (int, *streamBlock) {
+	best := -1
+	var found *streamBlock
+	for i := range p.blocks {
+		idx := strings.Index(p.pending, p.blocks[i].start)
+		if idx >= 0 && (best < 0 || idx < best) {
+			best, found = idx, &p.blocks[i]
+		}
+	}
+	return best, found
+}
+
+func (p *streamPrinter) startMarkers() []string {
+	markers := make([]string, 0, len(p.blocks))
+	for _, block := range p.blocks {
+		markers = append(markers, block.start)
+	}
+	return markers
+}
+
+// partialMarkerLen is the length of the longest suffix of pending that may grow into one of markers.
+func (p *streamPrinter) partialMarkerLen(markers []string) int {
+	longest := 0
+	for _, marker := range markers {
+		for n := min(len(marker)-1, len(p.pending)); n > longest; n-- {
+			if strings.HasSuffix(p.pending, marker[:n]) {
+				longest = n
+				break
+			}
+		}
+	}
+	return longest
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// streamChunks feeds answer to a printer of agent in chunks of size bytes, so markers are split
+// across chunks, and returns the printed text and the event types.
+func streamChunks(agent *AgentInterviewer, answer string, size int) (string, []string) {
+	var text strings.Builder
+	var types []string
+	printer := agent.newStreamPrinter(func(event InterviewerEvent) {
+		text.WriteString(event.Text)
+		if len(types) == 0 || types[len(types)-1] != event.Type {
+			types = append(types, event.Type)
+		}
+	})
+	for len(answer) > size {
+		printer.Write(answer[:size])
+		answer = answer[size:]
+	}
+	printer.Write(answer)
+	printer.Close()
+	return text.String(), types
+}
+
+func TestStreamPrinter(t *testing.T) {
+	markers := defaultPromptMarkers
+	question := wrapMarkers(markers.CollectDataStart, "Which car?", markers.CollectDataEnd)
+	response := wrapMarkers(markers.RspStart, validRepairPayload, markers.RspEnd)
+	tests := []struct {
+		name   string
+		mode   string
+		answer string
+		want   string
+	}{
+		{name: "question", answer: question, want: "Which car?\n"},
+		{name: "reasoning is skipped", answer: "<think>the user is vague</think>\n" + question, want: "Which car?\n"},
+		{name: "response is held back", answer: "Done.\n" + response, want: "Done.\n(Z_RSP received, checking it)"},
+		{name: "unterminated question", answer: markers.CollectDataStart + "\nWhich car", want: "Which car"},
+		{name: "json envelope", mode: InterviewerModeJSON, answer: `{"action":"ask_user","question":"Which car?"}`, want: ""},
+	}
+	for _, tt := range tests {
+		for _, size := range []int{1, 5, len(tt.answer)} {
+			agent := NewAgentInterviewer(unreachableChatModel{}, nil, MustDefaultResponseSchema(), WithoutInspector())
+			if tt.mode != "" {
+				agent.mode = tt.mode
+			}
+			text, types := streamChunks(agent, tt.answer, size)
+			if text != tt.want {
+				t.Errorf("%s in chunks of %d printed %q, want %q", tt.name, size, text, tt.want)
+			}
+			wantTypes := []string{EventDelta, EventDone}
+			if tt.want == "" {
+				wantTypes = nil
+			}
+			if strings.Join(types, ",") != strings.Join(wantTypes, ",") {
+				t.Errorf("%s in chunks of %d sent %v, want %v", tt.name, size, types, wantTypes)
+			}
+		}
+	}
+}
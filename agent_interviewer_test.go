@@ -0,0 +1,336 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+// stubModel is the model name the stub server expects, like a model pulled into Ollama.
+const stubModel = "stub-local"
+
+// stubChatServer is a tiny OpenAI-compatible /v1/chat/completions server with scripted answers:
+// the interviewer asks one question, then answers with a Z_RSP built from the reply, and the
+// inspector approves it. It speaks both plain and streamed (SSE) completions.
+type stubChatServer struct {
+	// apiKey is required as a bearer token when set.
+	apiKey string
+
+	mu       sync.Mutex
+	requests []stubChatRequest
+}
+
+// stubChatRequest is what the stub saw of a request.
+type stubChatRequest struct {
+	Model    string
+	Stream   bool
+	Auth     string
+	Messages int
+	Agent    string
+}
+
+func (s *stubChatServer) seen() []stubChatRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]stubChatRequest(nil), s.requests...)
+}
+
+func (s *stubChatServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/v1/chat/completions" {
+		stubError(w, http.StatusNotFound, "unknown path "+r.URL.Path)
+		return
+	}
+	if r.Method != http.MethodPost {
+		stubError(w, http.StatusMethodNotAllowed, "use POST")
+		return
+	}
+	auth := r.Header.Get("Authorization")
+	if s.apiKey != "" && auth != "Bearer "+s.apiKey {
+		stubError(w, http.StatusUnauthorized, "invalid api key")
+		return
+	}
+
+	var req openAIChatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		stubError(w, http.StatusBadRequest, "invalid request: "+err.Error())
+		return
+	}
+	if req.Model != stubModel {
+		stubError(w, http.StatusNotFound, fmt.Sprintf("model %q not found, try pulling it first", req.Model))
+		return
+	}
+	if len(req.Messages) == 0 {
+		stubError(w, http.StatusBadRequest, "messages is empty")
+		return
+	}
+
+	agent, text := stubAnswer(req.Messages)
+	s.mu.Lock()
+	s.requests = append(s.requests, stubChatRequest{Model: req.Model, Stream: req.Stream, Auth: auth, Messages: len(req.Messages), Agent: agent})
+	s.mu.Unlock()
+
+	prompt := 0
+	for _, msg := range req.Messages {
+		prompt += len(msg.Content) / 4
+	}
+	usage := map[string]int{"prompt_tokens": prompt, "completion_tokens": len(text) / 4, "total_tokens": prompt + len(text)/4}
+
+	if !req.Stream {
+		writeJSON(w, http.StatusOK, map[string]any{
+			"model": req.Model,
+			"choices": []any{map[string]any{
+				"message":       map[string]string{"role": ChatRoleAssistant, "content": text},
+				"finish_reason": "stop",
+			}},
+			"usage": usage,
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	send := func(chunk map[string]any) {
+		data, _ := json.Marshal(chunk)
+		fmt.Fprintf(w, "data: %s\n\n", data)
+	}
+	// a few chunks split mid-line, so markers arrive in pieces like from a real model
+	for i := 0; i < len(text); i += 16 {
+		delta := text[i:min(i+16, len(text))]
+		send(map[string]any{"model": req.Model, "choices": []any{map[string]any{"index": 0, "delta": map[string]string{"content": delta}}}})
+	}
+	send(map[string]any{"model": req.Model, "choices": []any{map[string]any{"index": 0, "delta": map[string]string{}, "finish_reason": "stop"}}})
+	if req.StreamOptions != nil && req.StreamOptions.IncludeUsage {
+		send(map[string]any{"model": req.Model, "choices": []any{}, "usage": usage})
+	}
+	fmt.Fprint(w, "data: [DONE]\n\n")
+}
+
+// stubAnswer scripts the answer to messages and names the agent that asked.
+func stubAnswer(messages []openAIMessage) (string, string) {
+	last := messages[len(messages)-1].Content
+	var payload struct {
+		Items json.RawMessage `json:"items"`
+	}
+	if len(messages) == 2 && json.Unmarshal([]byte(last), &payload) == nil && payload.Items != nil {
+		return usageAgentInspector, `{"approved": true}`
+	}
+
+	var replies []string
+	for _, msg := range messages {
+		if msg.Role == ChatRoleUser || msg.Role == ChatRoleTool {
+			replies = append(replies, stripStubMarkers(msg.Content))
+		}
+	}
+	markers := defaultPromptMarkers
+	if len(replies) < 2 {
+		return usageAgentInterviewer, fmt.Sprintf("%s\nWhat is the top speed of %s in km/h?\n%s", markers.CollectDataStart, replies[0], markers.CollectDataEnd)
+	}
+	item := ZRspItem{ItemType: "car", ItemName: replies[0], Value1Name: "speed", Value1Units: "km/h", Value1: replies[len(replies)-1]}
+	data, _ := json.Marshal(ZRsp{Items: []ZRspItem{item}})
+	return usageAgentInterviewer, fmt.Sprintf("Thanks, that is all I need.\n%s\n%s\n%s", markers.RspStart, data, markers.RspEnd)
+}
+
+func stripStubMarkers(content string) string {
+	content = strings.ReplaceAll(content, defaultPromptMarkers.ProvideDataStart, "")
+	content = strings.ReplaceAll(content, defaultPromptMarkers.ProvideDataEnd, "")
+	return strings.TrimSpace(content)
+}
+
+func stubError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]any{"error": map[string]string{"message": message}})
+}
+
+func TestInterviewerAgainstLocalStub(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream bool
+		apiKey string
+	}{
+		{name: "plain completions, no api key", stream: false},
+		{name: "streamed completions, no api key", stream: true},
+		{name: "streamed completions, api key", stream: true, apiKey: "stub-key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := &stubChatServer{apiKey: tt.apiKey}
+			server := httptest.NewServer(stub)
+			defer server.Close()
+
+			usage := &UsageConfig{}
+			cfg := ChatModelConfig{
+				Provider: ChatProviderOllama,
+				BaseURL:  server.URL + "/v1",
+				APIKey:   tt.apiKey,
+				Model:    stubModel,
+				Retry:    RetryPolicy{MaxRetries: 0, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond},
+				Agent:    usageAgentInterviewer,
+				Usage:    usage,
+			}
+			model, err := NewChatModel(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cfg.Agent = usageAgentInspector
+			inspectorModel, err := NewChatModel(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			inspector := NewChainInspector(NewRuleInspector(defaultResponseSchemaName, defaultZRspRules), NewSimpleAgentInspector(inspectorModel))
+			interviewer := NewAgentInterviewer(model, inspector, MustDefaultResponseSchema(), WithStreaming(tt.stream))
+
+			var events []InterviewerEvent
+			deltas := 0
+			emit := func(event InterviewerEvent) {
+				if event.Type == EventDelta {
+					deltas++
+					return
+				}
+				events = append(events, event)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			if err := interviewer.Step(ctx, "TT-34", emit); err != nil {
+				t.Fatalf("first step: %v", err)
+			}
+			question := lastEvent(events, EventQuestion)
+			if question == nil || !strings.Contains(question.Text, "TT-34") {
+				t.Fatalf("expected a question about TT-34, got events %+v", events)
+			}
+			if question.Model != stubModel {
+				t.Errorf("question answered by model %q, expected %s", question.Model, stubModel)
+			}
+
+			events = nil
+			if err := interviewer.Step(ctx, "180", emit); err != nil {
+				t.Fatalf("second step: %v", err)
+			}
+			response := lastEvent(events, EventResponse)
+			if response == nil || !response.Accepted {
+				t.Fatalf("expected an accepted response, got events %+v", events)
+			}
+			zrsp, ok := response.Response.Value.(*ZRsp)
+			if !ok || len(zrsp.Items) != 1 || zrsp.Items[0].ItemName != "TT-34" || zrsp.Items[0].Value1 != "180" {
+				t.Errorf("unexpected response %s", response.Response.Raw)
+			}
+			if response.Verdict == nil || !response.Verdict.Approved || !strings.Contains(response.Verdict.Inspector, "llm:"+stubModel) {
+				t.Errorf("expected an approval of the llm inspector, got %+v", response.Verdict)
+			}
+
+			if tt.stream && deltas == 0 {
+				t.Error("streaming run passed no text deltas")
+			}
+			agents := map[string]int{}
+			for _, req := range stub.seen() {
+				agents[req.Agent]++
+				if req.Stream != (tt.stream && req.Agent == usageAgentInterviewer) {
+					t.Errorf("%s request with stream=%t", req.Agent, req.Stream)
+				}
+				if (req.Auth != "") != (tt.apiKey != "") {
+					t.Errorf("authorization header %q with api key %q", req.Auth, tt.apiKey)
+				}
+			}
+			if agents[usageAgentInterviewer] != 2 || agents[usageAgentInspector] != 1 {
+				t.Errorf("expected 2 interviewer and 1 inspector requests, got %v", agents)
+			}
+
+			tracker, err := usage.Tracker()
+			if err != nil {
+				t.Fatal(err)
+			}
+			tracker.mu.Lock()
+			recorded := len(tracker.run)
+			tracker.mu.Unlock()
+			if recorded != len(stub.seen()) {
+				t.Errorf("usage recorded %d completions, the server answered %d", recorded, len(stub.seen()))
+			}
+		})
+	}
+}
+
+func TestInterviewerRunEndsWithInput(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    io.Reader
+		requests int
+		err      bool
+	}{
+		{name: "end of input after a question", input: strings.NewReader("TT-34\n"), requests: 1},
+		{name: "last line without newline", input: strings.NewReader("TT-34\n180"), requests: 3},
+		{name: "no input", input: strings.NewReader(""), requests: 0},
+		{name: "read error", input: iotest.ErrReader(errors.New("stdin closed")), requests: 0, err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := &stubChatServer{}
+			server := httptest.NewServer(stub)
+			defer server.Close()
+
+			model, err := NewChatModel(ChatModelConfig{Provider: ChatProviderOllama, BaseURL: server.URL + "/v1", Model: stubModel, Retry: RetryPolicy{MaxRetries: 0, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			interviewer := NewAgentInterviewer(model, nil, MustDefaultResponseSchema())
+			interviewer.reader = bufio.NewReader(tt.input)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			err = interviewer.Run(ctx)
+			if (err != nil) != tt.err {
+				t.Fatalf("Run() = %v, want error %t", err, tt.err)
+			}
+			if got := len(stub.seen()); got != tt.requests {
+				t.Errorf("the model was asked %d times, want %d", got, tt.requests)
+			}
+		})
+	}
+}
+
+func lastEvent(events []InterviewerEvent, eventType string) *InterviewerEvent {
+	for i := len(events) - 1; i >= 0; i-- {
+		if events[i].Type == eventType {
+			return &events[i]
+		}
+	}
+	return nil
+}
+
+func TestWithoutInspector(t *testing.T) {
+	markers := defaultPromptMarkers
+	answer := ChatResponse{Model: "m", Text: fmt.Sprintf("%s\n%s\n%s", markers.RspStart, validRepairPayload, markers.RspEnd)}
+	model := &scriptedChatModel{responses: []ChatResponse{answer}}
+
+	if agent := NewAgentInterviewer(model, nil, nil); agent.inspector == nil {
+		t.Error("a nil inspector without WithoutInspector built no LLM inspector")
+	}
+	agent := NewAgentInterviewer(model, nil, nil, WithoutInspector())
+	if agent.inspector != nil {
+		t.Fatalf("WithoutInspector kept inspector %T", agent.inspector)
+	}
+
+	var response *InterviewerEvent
+	err := agent.Step(context.Background(), "Audi TT, 180 km/h", func(event InterviewerEvent) {
+		if event.Type == EventResponse {
+			response = &event
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response == nil || !response.Accepted || response.Verdict != nil {
+		t.Errorf("response event %+v, want it accepted without a verdict", response)
+	}
+	if len(model.requests) != 1 {
+		t.Errorf("%d model calls, want only the interviewer's", len(model.requests))
+	}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Interviewer modes select how the model tells a clarifying question from the final answer.
+const (
+	// InterviewerModeMarkers scans free text for Z_COLLECT_DATA and Z_RSP markers.
+	InterviewerModeMarkers = "markers"
+	// InterviewerModeTools exposes ask_user and submit_response function calls.
+	InterviewerModeTools = "tools"
+	// InterviewerModeJSON requests a response_format JSON schema envelope.
+	InterviewerModeJSON = "json"
+)
+
+const (
+	toolAskUser        = "ask_user"
+	toolSubmitResponse = "submit_response"
+)
+
+type interviewerTurnKind int
+
+const (
+	turnOther interviewerTurnKind = iota
+	turnCollectData
+	turnResponse
+)
+
+// interviewerTurn is one classified model answer.
+type interviewerTurn struct {
+	kind interviewerTurnKind
+	// text is the clarifying question without markers, or the raw model text for turnOther.
+	text string
+	// toolCall is the ask_user or submit_response call the turn came from.
+	toolCall *ChatToolCall
+	// payload is the Z_RSP JSON for turnResponse.
+	payload string
+	// model is the model that answered.
+	model string
+}
+
+// jsonEnvelope is the answer shape of InterviewerModeJSON.
+type jsonEnvelope struct {
+	Action   string          `json:"action"`
+	Question string          `json:"question,omitempty"`
+	Payload  json.RawMessage `json:"payload,omitempty"`
+}
+
+// embeddableSchema returns the response schema without the top-level $schema keyword,
+// so it can be nested into tool parameters.
+func embeddableSchema(schema *ResponseSchema) (map[string]any, error) {
+	var doc map[string]any
+	if err := json.Unmarshal(schema.Schema, &doc); err != nil {
+		return nil, fmt.Errorf("parse %s schema: %w", schema.Name, err)
+	}
+	delete(doc, "$schema")
+	return doc, nil
+}
+
+func interviewerTools(schema *ResponseSchema) ([]ChatTool, error) {
+	payloadSchema, err := embeddableSchema(schema)
+	if err != nil {
+		return nil, err
+	}
+
+	askUser, err := json.Marshal(map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"question": map[string]any{"type": "string", "description": "clarifying question for Z_USER"},
+		},
+		"required": []string{"question"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	submitResponse, err := json.Marshal(map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"payload": payloadSchema,
+		},
+		"required": []string{"payload"},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return []ChatTool{
+		{Name: toolAskUser, Description: "Z_COLLECT_DATA: ask Z_USER a clarifying question and wait for the answer.", Parameters: askUser},
+		{Name: toolSubmitResponse, Description: "Z_RSP: submit the final answer, it finishes Z_DIALOG.", Parameters: submitResponse},
+	}, nil
+}
+
+func interviewerResponseFormat(schema *ResponseSchema) (*ChatResponseFormat, error) {
+	payloadSchema, err := embeddableSchema(schema)
+	if err != nil {
+		return nil, err
+	}
+
+	envelope, err := json.Marshal(map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"action":   map[string]any{"enum": []string{toolAskUser, toolSubmitResponse}},
+			"question": map[string]any{"type": "string"},
+			"payload":  payloadSchema,
+		},
+		"required": []string{"action"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &ChatResponseFormat{Name: "z_dialog_turn", Schema: envelope}, nil
+}
+
+// classifyTurn classifies resp and remembers the model that answered.
+func (agent *AgentInterviewer) classifyTurn(resp ChatResponse) (interviewerTurn, error) {
+	turn, err := agent.classifyAnswer(resp)
+	turn.model = resp.Model
+	return turn, err
+}
+
+// classifyAnswer decides the turn type from tool calls or the JSON envelope when the mode
+// provides them, and falls back to the marker protocol otherwise. A tool call with malformed
+// arguments is returned with its kind and the error, a submit_response then carries the raw
+// arguments as payload.
+func (agent *AgentInterviewer) classifyAnswer(resp ChatResponse) (interviewerTurn, error) {
+	switch agent.mode {
+	case InterviewerModeTools:
+		for _, call := range resp.ToolCalls {
+			switch call.Name {
+			case toolAskUser:
+				var args struct {
+					Question string `json:"question"`
+				}
+				if err := json.Unmarshal([]byte(call.Arguments), &args); err != nil {
+					return interviewerTurn{kind: turnCollectData, toolCall: &call}, fmt.Errorf("parse %s arguments: %w", call.Name, err)
+				}
+				return interviewerTurn{kind: turnCollectData, text: args.Question, toolCall: &call}, nil
+			case toolSubmitResponse:
+				var args struct {
+					Payload json.RawMessage `json:"payload"`
+				}
+				if err := json.Unmarshal([]byte(call.Arguments), &args); err != nil {
+					return interviewerTurn{kind: turnResponse, payload: call.Arguments, toolCall: &call}, fmt.Errorf("parse %s arguments: %w", call.Name, err)
+				}
+				return interviewerTurn{kind: turnResponse, payload: string(args.Payload), toolCall: &call}, nil
+			}
+		}
+	case InterviewerModeJSON:
+		var envelope jsonEnvelope
+		if err := json.Unmarshal([]byte(strings.TrimSpace(resp.Text)), &envelope); err == nil {
+			switch envelope.Action {
+			case toolAskUser:
+				return interviewerTurn{kind: turnCollectData, text: envelope.Question}, nil
+			case toolSubmitResponse:
+				return interviewerTurn{kind: turnResponse, payload: string(envelope.Payload)}, nil
+			}
+		}
+	}
+
+	return agent.classifyMarkers(resp.Text)
+}
+
+func (agent *AgentInterviewer) markerParser() *MarkerParser {
+	return NewMarkerParser(
+		MarkerPair{Kind: SegmentCollectData, Start: agent.zCollectDataStart, End: agent.zCollectDataEnd},
+		MarkerPair{Kind: SegmentResponse, Start: agent.zRspStart, End: agent.zRspEnd},
+	)
+}
+
+// classifyMarkers prefers clarifying questions over a final answer. Several questions are
+// asked together, of several answers the last one wins. A block missing its end marker still
+// counts, an incomplete answer then goes through the repair loop.
+func (agent *AgentInterviewer) classifyMarkers(respStr string) (interviewerTurn, error) {
+	segments, err := agent.markerParser().Parse(respStr)
+	if err != nil {
+		fmt.Printf("markers: %v\n", err)
+	}
+
+	if questions := SegmentsOf(segments, SegmentCollectData); len(questions) > 0 {
+		texts := make([]string, 0, len(questions))
+		for _, q := range questions {
+			if q.Text != "" {
+				texts = append(texts, q.Text)
+			}
+		}
+		return interviewerTurn{kind: turnCollectData, text: strings.Join(texts, "\n")}, nil
+	}
+
+	if rsp, ok := LastSegment(segments, SegmentResponse); ok {
+		return interviewerTurn{kind: turnResponse, payload: rsp.Text}, nil
+	}
+
+	return interviewerTurn{kind: turnOther, text: respStr}, nil
+}
+
+// unsupportedFeatureHints are what providers say when a model can't do tools or response_format.
+var unsupportedFeatureHints = []string{"tool", "function", "response_format", "response format", "json_schema", "json schema", "structured output"}
+
+// unsupportedModeError reports whether err is the provider rejecting tools or response_format,
+// a 400, 404 or 422 that names the feature. Every other error says nothing about the mode.
+func unsupportedModeError(err error) bool {
+	var apiErr *ChatAPIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	switch apiErr.StatusCode {
+	case http.StatusBadRequest, http.StatusNotFound, http.StatusUnprocessableEntity:
+	default:
+		return false
+	}
+	message := strings.ToLower(apiErr.Message)
+	for _, hint := range unsupportedFeatureHints {
+		if strings.Contains(message, hint) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestStepRecoversFromMalformedToolArguments(t *testing.T) {
+	ask := func(id, args string) ChatResponse {
+		return ChatResponse{Model: "m", ToolCalls: []ChatToolCall{{ID: id, Name: toolAskUser, Arguments: args}}}
+	}
+	submit := func(id, args string) ChatResponse {
+		return ChatResponse{Model: "m", ToolCalls: []ChatToolCall{{ID: id, Name: toolSubmitResponse, Arguments: args}}}
+	}
+	tests := []struct {
+		name      string
+		responses []ChatResponse
+		want      []string
+	}{
+		{
+			name:      "bad ask_user, then a question",
+			responses: []ChatResponse{ask("call-1", `{"question":`), ask("call-2", `{"question":"which car?"}`)},
+			want:      []string{EventQuestion},
+		},
+		{
+			name:      "bad submit_response, then a repaired answer",
+			responses: []ChatResponse{submit("call-1", `{"payload":{"items":[`), submit("call-2", `{"payload":`+validRepairPayload+`}`)},
+			want:      []string{EventResponse},
+		},
+		{
+			name:      "bad ask_user too often",
+			responses: []ChatResponse{ask("call-1", `{`), ask("call-2", `{`), ask("call-3", `{`)},
+			want:      []string{EventReset},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			model := &scriptedChatModel{responses: tt.responses}
+			inspector := &verdictInspector{verdict: Verdict{Approved: true}}
+			agent := NewAgentInterviewer(model, inspector, MustDefaultResponseSchema(),
+				WithInterviewerMode(InterviewerModeTools), WithRepairs(2, ""))
+
+			var got []string
+			err := agent.Step(context.Background(), "Audi TT, 250 km/h", func(event InterviewerEvent) {
+				got = append(got, event.Type)
+			})
+			if err != nil {
+				t.Fatalf("Step = %v, want the session to go on", err)
+			}
+			if len(got) != len(tt.want) || got[0] != tt.want[0] {
+				t.Errorf("events %v, want %v", got, tt.want)
+			}
+			if len(model.responses) != 0 {
+				t.Errorf("%d scripted answers left", len(model.responses))
+			}
+			for _, req := range model.requests {
+				checkToolCallsAnswered(t, req.Messages)
+			}
+		})
+	}
+}
+
+func TestJSONModeEnvelope(t *testing.T) {
+	model := &scriptedChatModel{responses: []ChatResponse{
+		{Model: "m", Text: `{"action":"ask_user","question":"Which car?"}`},
+		{Model: "m", Text: ` {"action":"submit_response","payload":` + validRepairPayload + `} `},
+	}}
+	agent := NewAgentInterviewer(model, &verdictInspector{verdict: Verdict{Approved: true}}, MustDefaultResponseSchema(),
+		WithInterviewerMode(InterviewerModeJSON))
+
+	var got []InterviewerEvent
+	onEvent := func(event InterviewerEvent) { got = append(got, event) }
+	for _, input := range []string{"I have a car", "Audi TT, 250 km/h"} {
+		if err := agent.Step(context.Background(), input, onEvent); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(got) != 2 || got[0].Type != EventQuestion || got[0].Text != "Which car?" || got[1].Type != EventResponse {
+		t.Fatalf("events %+v, want the question and the response", got)
+	}
+	for _, req := range model.requests {
+		if req.ResponseFormat == nil || len(req.Tools) != 0 {
+			t.Errorf("request with response format %v and %d tools, want the envelope schema only", req.ResponseFormat, len(req.Tools))
+		}
+	}
+}
+
+// modeRejectingChatModel fails every request using tools or response_format with err,
+// and asks a marker question otherwise.
+type modeRejectingChatModel struct {
+	err      error
+	requests []ChatRequest
+}
+
+func (m *modeRejectingChatModel) Chat(_ context.Context, req ChatRequest) (ChatResponse, error) {
+	m.requests = append(m.requests, req)
+	if len(req.Tools) > 0 || req.ResponseFormat != nil {
+		return ChatResponse{}, m.err
+	}
+	return markersQuestion("Which car?"), nil
+}
+
+func TestModeFallsBackToMarkersOnlyWhenUnsupported(t *testing.T) {
+	tests := []struct {
+		name     string
+		mode     string
+		err      error
+		wantMode string
+	}{
+		{name: "tools unsupported", mode: InterviewerModeTools, err: &ChatAPIError{StatusCode: 400, Message: "This model does not support tool use"}, wantMode: InterviewerModeMarkers},
+		{name: "response_format unsupported", mode: InterviewerModeJSON, err: &ChatAPIError{StatusCode: 422, Message: "response_format is not supported"}, wantMode: InterviewerModeMarkers},
+		{name: "rate limited", mode: InterviewerModeTools, err: &ChatAPIError{StatusCode: 429, Message: "tool quota exceeded"}, wantMode: InterviewerModeTools},
+		{name: "bad request about something else", mode: InterviewerModeJSON, err: &ChatAPIError{StatusCode: 400, Message: "context too long"}, wantMode: InterviewerModeJSON},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			model := &modeRejectingChatModel{err: tt.err}
+			agent := NewAgentInterviewer(model, nil, MustDefaultResponseSchema(), WithoutInspector(), WithInterviewerMode(tt.mode))
+
+			var question string
+			err := agent.Step(context.Background(), "I have a car", func(event InterviewerEvent) {
+				if event.Type == EventQuestion {
+					question = event.Text
+				}
+			})
+			if agent.mode != tt.wantMode {
+				t.Errorf("mode %s after %v, want %s", agent.mode, tt.err, tt.wantMode)
+			}
+			if tt.wantMode == InterviewerModeMarkers {
+				if err != nil || question != "Which car?" || len(model.requests) != 2 {
+					t.Errorf("Step = %v with question %q after %d requests, want the marker question on the retry", err, question, len(model.requests))
+				}
+			} else if err == nil {
+				t.Error("Step succeeded, want the model error")
+			}
+		})
+	}
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Chat message roles understood by every ChatModel implementation.
+const (
+	ChatRoleSystem    = "system"
+	ChatRoleUser      = "user"
+	ChatRoleAssistant = "assistant"
+	ChatRoleTool      = "tool"
+)
+
+// Supported values of ChatModelConfig.Provider.
+const (
+	ChatProviderOpenRouter = "openrouter"
+	ChatProviderOpenAI     = "openai"
+	// Self-hosted OpenAI-compatible servers, presets of ChatProviderOpenAI with their default base URL.
+	ChatProviderOllama   = "ollama"
+	ChatProviderLlamaCpp = "llamacpp"
+	ChatProviderVLLM     = "vllm"
+)
+
+// localChatProviders maps the self-hosted providers to the base URL their servers listen on by default.
+var localChatProviders = map[string]string{
+	ChatProviderOllama:   "http://localhost:11434/v1",
+	ChatProviderLlamaCpp: "http://localhost:8080/v1",
+	ChatProviderVLLM:     "http://localhost:8000/v1",
+}
+
+// Models used by the flows when nothing else is configured, the first one is asked first
+// and the others are fallbacks.
+const (
+	defaultInterviewerModel = "deepseek/deepseek-chat-v3-0324:free,moonshotai/kimi-k2:free"
+	defaultInspectorModel   = "deepseek/deepseek-chat-v3-0324:free,qwen/qwen3-coder:free"
+	defaultDigestModel      = "qwen/qwen3-coder:free,deepseek/deepseek-chat-v3-0324:free"
+	defaultTestgenModel     = "moonshotai/kimi-k2:free,qwen/qwen3-coder:free"
+)
+
+type ChatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+	// ToolCalls are the calls made by an assistant message.
+	ToolCalls []ChatToolCall `json:"toolCalls,omitempty"`
+	// ToolCallID links a tool message to the call it answers.
+	ToolCallID string `json:"toolCallId,omitempty"`
+}
+
+// ChatTool is a function the model may call instead of answering with text.
+type ChatTool struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// Parameters is the JSON Schema of the call arguments.
+	Parameters json.RawMessage `json:"parameters"`
+}
+
+type ChatToolCall struct {
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name"`
+	// Arguments is the JSON object produced by the model.
+	Arguments string `json:"arguments"`
+}
+
+// ChatResponseFormat asks the model for a JSON answer matching Schema.
+type ChatResponseFormat struct {
+	Name   string          `json:"name"`
+	Schema json.RawMessage `json:"schema"`
+	Strict bool            `json:"strict,omitempty"`
+}
+
+// Values of ChatRequest.ToolChoice.
+const (
+	ChatToolChoiceAuto     = "auto"
+	ChatToolChoiceRequired = "required"
+)
+
+type ChatRequest struct {
+	// Model overrides the default model of the ChatModel when not empty.
+	Model    string        `json:"model,omitempty"`
+	Messages []ChatMessage `json:"messages"`
+
+	Tools          []ChatTool          `json:"tools,omitempty"`
+	ToolChoice     string              `json:"toolChoice,omitempty"`
+	ResponseFormat *ChatResponseFormat `json:"responseFormat,omitempty"`
+}
+
+type ChatUsage struct {
+	PromptTokens     int     `json:"promptTokens"`
+	CompletionTokens int     `json:"completionTokens"`
+	TotalTokens      int     `json:"totalTokens"`
+	Cost             float64 `json:"cost,omitempty"`
+	// CostReported is set when the provider reported Cost, so a zero Cost means free.
+	CostReported bool `json:"costReported,omitempty"`
+}
+
+type ChatResponse struct {
+	Text string `json:"text"`
+	// Model is the model that actually produced the answer, as reported by the provider.
+	Model        string         `json:"model"`
+	FinishReason string         `json:"finishReason,omitempty"`
+	ToolCalls    []ChatToolCall `json:"toolCalls,omitempty"`
+	Usage        ChatUsage      `json:"usage"`
+}
+
+// ChatModel is a chat completion backend: messages in, text and usage out.
+type ChatModel interface {
+	Chat(ctx context.Context, req ChatRequest) (ChatResponse, error)
+}
+
+// ChatStreamer is implemented by ChatModels that can deliver the answer text as it is generated.
+type ChatStreamer interface {
+	// ChatStream calls onDelta with every text chunk and returns the assembled response.
+	ChatStream(ctx context.Context, req ChatRequest, onDelta func(string)) (ChatResponse, error)
+}
+
+// ChatOrStream streams when model is a ChatStreamer, otherwise it passes the whole answer text to onDelta at once.
+func ChatOrStream(ctx context.Context, model ChatModel, req ChatRequest, onDelta func(string)) (ChatResponse, error) {
+	if streamer, ok := model.(ChatStreamer); ok {
+		return streamer.ChatStream(ctx, req, onDelta)
+	}
+	resp, err := model.Chat(ctx, req)
+	if err == nil && onDelta != nil && resp.Text != "" {
+		onDelta(resp.Text)
+	}
+	return resp, err
+}
+
+// mergeToolCallDelta appends a streamed tool call fragment to the call at index.
+func mergeToolCallDelta(calls []ChatToolCall, index int, id, name, arguments string) []ChatToolCall {
+	for len(calls) <= index {
+		calls = append(calls, ChatToolCall{})
+	}
+	if id != "" {
+		calls[index].ID = id
+	}
+	if name != "" {
+		calls[index].Name = name
+	}
+	calls[index].Arguments += arguments
+	return calls
+}
+
+// ChatModelConfig selects the backend and the default model of a ChatModel.
+type ChatModelConfig struct {
+	Provider string
+	// BaseURL is the API root, e.g. https://openrouter.ai/api/v1. Empty means the provider default.
+	BaseURL string
+	APIKey  string
+	// Model is a model name, or a comma-separated list of models asked in order until one answers.
+	Model string
+	// Retry is how often a failing model is retried before the next one is asked,
+	// the zero value uses defaultRetryPolicy.
+	Retry RetryPolicy
+	// Cassette, when enabled, records or replays the completions of this model.
+	Cassette *CassetteConfig
+	// Cache, when enabled, answers repeated requests from disk.
+	Cache *CacheConfig
+	// Agent names the completions of this model in the usage log and its budgets.
+	Agent string
+	// Usage, when set, records the usage of this model and enforces its budgets.
+	Usage *UsageConfig
+}
+
+// Models returns the models of Model in order.
+func (cfg ChatModelConfig) Models() []string {
+	var models []string
+	for _, model := range strings.Split(cfg.Model, ",") {
+		if model = strings.TrimSpace(model); model != "" {
+			models = append(models, model)
+		}
+	}
+	return models
+}
+
+// ChatModelConfigFromEnv reads LLM_PROVIDER, LLM_BASE_URL, the model of agent, LLM_MAX_RETRIES and the
+// cassette and cache variables, falling back to OpenRouter and defaultModel. The model is read from
+// LLM_<AGENT>_MODEL, e.g. LLM_INSPECTOR_MODEL, then from LLM_MODEL, which applies to every agent.
+// The API key is resolved by NewChatModel.
+func ChatModelConfigFromEnv(agent, defaultModel string) ChatModelConfig {
+	cfg := ChatModelConfig{
+		Provider: os.Getenv("LLM_PROVIDER"),
+		BaseURL:  os.Getenv("LLM_BASE_URL"),
+		Model:    os.Getenv(agentModelEnv(agent)),
+		Retry:    defaultRetryPolicy,
+		Cassette: CassetteConfigFromEnv(),
+		Cache:    CacheConfigFromEnv(),
+		Agent:    agent,
+	}
+	if cfg.Model == "" {
+		cfg.Model = os.Getenv("LLM_MODEL")
+	}
+	if retries, err := strconv.Atoi(os.Getenv("LLM_MAX_RETRIES")); err == nil && retries >= 0 {
+		cfg.Retry.MaxRetries = retries
+	}
+	if cfg.Provider == "" {
+		cfg.Provider = ChatProviderOpenRouter
+	}
+	if cfg.Model == "" {
+		cfg.Model = defaultModel
+	}
+	return cfg
+}
+
+// agentModelEnv names the variable with the model of agent, e.g. LLM_DIGEST_MODEL.
+func agentModelEnv(agent string) string {
+	if agent == "" {
+		return "LLM_MODEL"
+	}
+	return "LLM_" + strings.ToUpper(agent) + "_MODEL"
+}
+
+func NewChatModel(cfg ChatModelConfig) (ChatModel, error) {
+	models := cfg.Models()
+	if _, local := localChatProviders[cfg.Provider]; local {
+		// the defaults name OpenRouter models, a local server knows none of them
+		for _, model := range models {
+			if strings.HasSuffix(model, ":free") {
+				return nil, fmt.Errorf("model %s is an OpenRouter model, set -model, %s or LLM_MODEL to a model served by %s", model, agentModelEnv(cfg.Agent), cfg.Provider)
+			}
+		}
+	}
+	scope := fmt.Sprintf("%s %s %s", cfg.Provider, cfg.BaseURL, strings.Join(models, ","))
+	// the cassette keys requests by the first model, so recordings survive changes of the fallbacks
+	if len(models) > 0 {
+		cfg.Model = models[0]
+	}
+	if !cfg.Cassette.enabled() {
+		return newCachingChatModel(cfg, models, scope)
+	}
+
+	// replaying never reaches the provider, so it must work without API keys
+	var inner ChatModel
+	if cfg.Cassette.Mode != CassetteModeReplay {
+		var err error
+		if inner, err = newCachingChatModel(cfg, models, scope); err != nil {
+			return nil, err
+		}
+	}
+	return NewCassetteChatModel(inner, cfg.Cassette.Dir, cfg.Cassette.Mode, cfg.Model)
+}
+
+// newCachingChatModel puts the response cache, when enabled, in front of the metered fallback chain,
+// so cached answers cost nothing. scope keys the cache by provider, base URL and all models.
+func newCachingChatModel(cfg ChatModelConfig, models []string, scope string) (ChatModel, error) {
+	inner, err := newFallbackChatModel(cfg, models)
+	if err != nil || !cfg.Cache.enabled() {
+		return inner, err
+	}
+	return NewCachingChatModel(inner, *cfg.Cache, scope)
+}
+
+// newFallbackChatModel wraps the provider into a FallbackChatModel over models, metered when cfg.Usage is set.
+// The meter sits below the cassette, replayed completions cost nothing.
+func newFallbackChatModel(cfg ChatModelConfig, models []string) (ChatModel, error) {
+	provider, err := newProviderChatModel(cfg)
+	if err != nil {
+		return nil, err
+	}
+	policy := cfg.Retry
+	if policy == (RetryPolicy{}) {
+		policy = defaultRetryPolicy
+	}
+	return newMeteredChatModel(cfg, NewFallbackChatModel(provider, models, policy))
+}
+
+func newProviderChatModel(cfg ChatModelConfig) (ChatModel, error) {
+	switch cfg.Provider {
+	case ChatProviderOpenRouter, "":
+		apiKey := cfg.APIKey
+		if apiKey == "" {
+			apiKey = os.Getenv("OPENROUTER_API_KEY")
+		}
+		if apiKey == "" {
+			return nil, fmt.Errorf("export OPENROUTER_API_KEY first")
+		}
+		return NewOpenRouterChatModel(apiKey, cfg.BaseURL, cfg.Model), nil
+	case ChatProviderOpenAI:
+		apiKey := cfg.APIKey
+		if apiKey == "" {
+			apiKey = os.Getenv("LLM_API_KEY")
+		}
+		if cfg.BaseURL == "" {
+			return nil, fmt.Errorf("provider %s needs a base URL, export LLM_BASE_URL first", cfg.Provider)
+		}
+		return NewOpenAIChatModel(cfg.BaseURL, apiKey, cfg.Model), nil
+	case ChatProviderOllama, ChatProviderLlamaCpp, ChatProviderVLLM:
+		// local servers run without a key unless started with one, e.g. vllm serve --api-key
+		baseURL := cfg.BaseURL
+		if baseURL == "" {
+			baseURL = localChatProviders[cfg.Provider]
+		}
+		apiKey := cfg.APIKey
+		if apiKey == "" {
+			apiKey = os.Getenv("LLM_API_KEY")
+		}
+		return NewOpenAIChatModel(baseURL, apiKey, cfg.Model), nil
+	default:
+		return nil, fmt.Errorf("unknown chat provider %q", cfg.Provider)
+	}
+}
+
+// NewChatModelFromEnv builds the ChatModel of agent described by the environment and exits if it can't.
+func NewChatModelFromEnv(agent, defaultModel string) ChatModel {
+	model, err := NewChatModel(ChatModelConfigFromEnv(agent, defaultModel))
+	if err != nil {
+		log.Fatal(err)
+	}
+	return model
+}
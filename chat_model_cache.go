@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CacheConfig enables the response cache in Dir.
+type CacheConfig struct {
+	Dir string
+	// TTL is how long a cached response is served, 0 serves it forever.
+	TTL time.Duration
+	// MaxMB caps the size of Dir, the least recently used responses are evicted first. 0 is unlimited.
+	MaxMB int
+	// Bypass skips lookups, fresh responses are still stored.
+	Bypass bool
+}
+
+var defaultCacheConfig = CacheConfig{TTL: 24 * time.Hour, MaxMB: 100}
+
+// CacheConfigFromEnv reads LLM_CACHE (directory), LLM_CACHE_TTL, LLM_CACHE_MAX_MB and LLM_CACHE_BYPASS.
+func CacheConfigFromEnv() *CacheConfig {
+	cfg := defaultCacheConfig
+	cfg.Dir = os.Getenv("LLM_CACHE")
+	if ttl, err := time.ParseDuration(os.Getenv("LLM_CACHE_TTL")); err == nil && ttl >= 0 {
+		cfg.TTL = ttl
+	}
+	if maxMB, err := strconv.Atoi(os.Getenv("LLM_CACHE_MAX_MB")); err == nil && maxMB >= 0 {
+		cfg.MaxMB = maxMB
+	}
+	cfg.Bypass, _ = strconv.ParseBool(os.Getenv("LLM_CACHE_BYPASS"))
+	return &cfg
+}
+
+func (c *CacheConfig) enabled() bool {
+	return c != nil && c.Dir != ""
+}
+
+// CachingChatModel serves repeated requests from files keyed by a hash of the provider, the
+// models and the request. Only successful answers are stored.
+type CachingChatModel struct {
+	inner ChatModel
+	cfg   CacheConfig
+	// scope is what besides the request selects the answer: provider, base URL and the model list.
+	scope string
+
+	// mu serializes the size check, so concurrent writers don't evict each other's entries
+	mu sync.Mutex
+}
+
+type cacheEntry struct {
+	Key      string       `json:"key"`
+	Scope    string       `json:"scope"`
+	Request  ChatRequest  `json:"request"`
+	Response ChatResponse `json:"response"`
+	StoredAt time.Time    `json:"storedAt"`
+}
+
+// NewCachingChatModel wraps inner, expired entries in cfg.Dir are removed right away.
+func NewCachingChatModel(inner ChatModel, cfg CacheConfig, scope string) (*CachingChatModel, error) {
+	if err := os.MkdirAll(cfg.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("create cache dir: %w", err)
+	}
+	m := &CachingChatModel{inner: inner, cfg: cfg, scope: scope}
+	m.prune()
+	return m, nil
+}
+
+func (m *CachingChatModel) Chat(ctx context.Context, req ChatRequest) (ChatResponse, error) {
+	return m.roundTrip(ctx, req, nil, func(ctx context.Context, req ChatRequest) (ChatResponse, error) {
+		return m.inner.Chat(ctx, req)
+	})
+}
+
+// ChatStream streams through the inner model on a miss; a cached answer is passed to onDelta at once.
+func (m *CachingChatModel) ChatStream(ctx context.Context, req ChatRequest, onDelta func(string)) (ChatResponse, error) {
+	return m.roundTrip(ctx, req, onDelta, func(ctx context.Context, req ChatRequest) (ChatResponse, error) {
+		return ChatOrStream(ctx, m.inner, req, onDelta)
+	})
+}
+
+func (m *CachingChatModel) roundTrip(ctx context.Context, req ChatRequest, onDelta func(string), call func(context.Context, ChatRequest) (ChatResponse, error)) (ChatResponse, error) {
+	key, err := m.key(req)
+	if err != nil {
+		return ChatResponse{}, err
+	}
+	path := filepath.Join(m.cfg.Dir, key+".json")
+
+	if !m.cfg.Bypass {
+		if resp, ok := m.lookup(path); ok {
+			fmt.Printf("cache: hit %s (model %s)\n", key[:12], resp.Model)
+			if onDelta != nil && resp.Text != "" {
+				onDelta(resp.Text)
+			}
+			return resp, nil
+		}
+	}
+
+	resp, err := call(ctx, req)
+	if err != nil {
+		return ChatResponse{}, err
+	}
+	if err := m.store(path, cacheEntry{Key: key, Scope: m.scope, Request: req, Response: resp, StoredAt: time.Now()}); err != nil {
+		fmt.Printf("cache: %v\n", err)
+	}
+	return resp, nil
+}
+
+// key hashes the scope and the JSON form of req: model, messages, tools and response format.
+func (m *CachingChatModel) key(req ChatRequest) (string, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return "", fmt.Errorf("marshal chat request: %w", err)
+	}
+	sum := sha256.Sum256(append([]byte(m.scope+"\n"), data...))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// lookup returns the cached response at path unless it is missing, unreadable or expired.
+func (m *CachingChatModel) lookup(path string) (ChatResponse, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			fmt.Printf("cache: %v\n", err)
+		}
+		return ChatResponse{}, false
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		fmt.Printf("cache: %s: %v, ignored\n", path, err)
+		return ChatResponse{}, false
+	}
+	if m.expired(entry.StoredAt) {
+		return ChatResponse{}, false
+	}
+	// the modification time orders entries for eviction, a hit makes an entry recent
+	now := time.Now()
+	os.Chtimes(path, now, now)
+	return entry.Response, true
+}
+
+func (m *CachingChatModel) expired(storedAt time.Time) bool {
+	return m.cfg.TTL > 0 && time.Since(storedAt) > m.cfg.TTL
+}
+
+// store writes entry through a temporary file, so concurrent readers never see half of it.
+func (m *CachingChatModel) store(path string, entry cacheEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("marshal cache entry: %w", err)
+	}
+	tmp, err := os.CreateTemp(m.cfg.Dir, ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("write cache entry: %w", err)
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("write cache entry: %w", err)
+	}
+	m.prune()
+	return nil
+}
+
+// prune removes expired entries and, past MaxMB, the least recently used ones.
+func (m *CachingChatModel) prune() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	dirEntries, err := os.ReadDir(m.cfg.Dir)
+	if err != nil {
+		fmt.Printf("cache: %v\n", err)
+		return
+	}
+	type cacheFile struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+	var files []cacheFile
+	var total int64
+	for _, dirEntry := range dirEntries {
+		if dirEntry.IsDir() || !strings.HasSuffix(dirEntry.Name(), ".json") {
+			continue
+		}
+		info, err := dirEntry.Info()
+		if err != nil {
+			continue
+		}
+		path := filepath.Join(m.cfg.Dir, dirEntry.Name())
+		// an entry is stored at or before its last modification, so an old modification time is enough to expire it
+		if m.expired(info.ModTime()) {
+			os.Remove(path)
+			continue
+		}
+		files = append(files, cacheFile{path: path, size: info.Size(), modTime: info.ModTime()})
+		total += info.Size()
+	}
+
+	limit := int64(m.cfg.MaxMB) << 20
+	if limit <= 0 || total <= limit {
+		return
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+	evicted := 0
+	for _, file := range files {
+		if total <= limit {
+			break
+		}
+		if err := os.Remove(file.path); err == nil {
+			total -= file.size
+			evicted++
+		}
+	}
+	fmt.Printf("cache: evicted %d responses to stay under %d MB\n", evicted, m.cfg.MaxMB)
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// countingChatModel answers every request with text of size bytes and counts the calls.
+type countingChatModel struct {
+	calls int
+	size  int
+}
+
+func (m *countingChatModel) Chat(_ context.Context, req ChatRequest) (ChatResponse, error) {
+	m.calls++
+	text := req.Messages[len(req.Messages)-1].Content
+	if m.size > len(text) {
+		text += strings.Repeat(".", m.size-len(text))
+	}
+	return ChatResponse{Text: text, Model: "m"}, nil
+}
+
+func cacheRequest(content string) ChatRequest {
+	return ChatRequest{Messages: []ChatMessage{{Role: ChatRoleUser, Content: content}}}
+}
+
+func TestCachingChatModelKey(t *testing.T) {
+	m := &CachingChatModel{scope: "openrouter  a,b"}
+	other := &CachingChatModel{scope: "openrouter  b,a"}
+	base, _ := m.key(cacheRequest("hi"))
+
+	tests := []struct {
+		name string
+		m    *CachingChatModel
+		req  ChatRequest
+		same bool
+	}{
+		{name: "same request", m: m, req: cacheRequest("hi"), same: true},
+		{name: "other message", m: m, req: cacheRequest("hello")},
+		{name: "other model", m: m, req: ChatRequest{Model: "c", Messages: cacheRequest("hi").Messages}},
+		{name: "other tools", m: m, req: ChatRequest{Messages: cacheRequest("hi").Messages, Tools: []ChatTool{{Name: "ask_user", Parameters: json.RawMessage(`{}`)}}}},
+		{name: "other scope", m: other, req: cacheRequest("hi")},
+	}
+	for _, tt := range tests {
+		key, err := tt.m.key(tt.req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (key == base) != tt.same {
+			t.Errorf("%s: key equal = %t, want %t", tt.name, key == base, tt.same)
+		}
+	}
+}
+
+func TestCachingChatModelHitAndBypass(t *testing.T) {
+	tests := []struct {
+		name      string
+		bypass    bool
+		wantCalls int
+	}{
+		{name: "hit", wantCalls: 1},
+		{name: "bypass", bypass: true, wantCalls: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inner := &countingChatModel{}
+			dir := t.TempDir()
+			m, err := NewCachingChatModel(inner, CacheConfig{Dir: dir, Bypass: tt.bypass}, "scope")
+			if err != nil {
+				t.Fatal(err)
+			}
+			for range 2 {
+				resp, err := m.Chat(context.Background(), cacheRequest("hi"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if resp.Text != "hi" || resp.Model != "m" {
+					t.Errorf("response %+v", resp)
+				}
+			}
+			if inner.calls != tt.wantCalls {
+				t.Errorf("inner called %d times, want %d", inner.calls, tt.wantCalls)
+			}
+			if entries, _ := filepath.Glob(filepath.Join(dir, "*.json")); len(entries) != 1 {
+				t.Errorf("%d cache entries, want 1", len(entries))
+			}
+		})
+	}
+}
+
+func TestCachingChatModelTTL(t *testing.T) {
+	tests := []struct {
+		name      string
+		age       time.Duration
+		ttl       time.Duration
+		wantCalls int
+	}{
+		{name: "fresh", age: time.Minute, ttl: time.Hour, wantCalls: 1},
+		{name: "expired", age: 2 * time.Hour, ttl: time.Hour, wantCalls: 2},
+		{name: "no ttl", age: 1000 * time.Hour, wantCalls: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inner := &countingChatModel{}
+			dir := t.TempDir()
+			cfg := CacheConfig{Dir: dir, TTL: tt.ttl}
+			m, err := NewCachingChatModel(inner, cfg, "scope")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := m.Chat(context.Background(), cacheRequest("hi")); err != nil {
+				t.Fatal(err)
+			}
+
+			// age the entry: its stored time and its modification time
+			key, _ := m.key(cacheRequest("hi"))
+			path := filepath.Join(dir, key+".json")
+			var entry cacheEntry
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal(data, &entry); err != nil {
+				t.Fatal(err)
+			}
+			entry.StoredAt = time.Now().Add(-tt.age)
+			data, _ = json.Marshal(entry)
+			if err := os.WriteFile(path, data, 0644); err != nil {
+				t.Fatal(err)
+			}
+			os.Chtimes(path, entry.StoredAt, entry.StoredAt)
+
+			if _, err := m.Chat(context.Background(), cacheRequest("hi")); err != nil {
+				t.Fatal(err)
+			}
+			if inner.calls != tt.wantCalls {
+				t.Errorf("inner called %d times, want %d", inner.calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestCachingChatModelPrunesExpiredOnOpen(t *testing.T) {
+	dir := t.TempDir()
+	old := filepath.Join(dir, "old.json")
+	if err := os.WriteFile(old, []byte(`{}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	past := time.Now().Add(-48 * time.Hour)
+	os.Chtimes(old, past, past)
+	other := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(other, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(other, past, past)
+
+	if _, err := NewCachingChatModel(&countingChatModel{}, CacheConfig{Dir: dir, TTL: 24 * time.Hour}, "scope"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Error("an expired entry survived opening the cache")
+	}
+	if _, err := os.Stat(other); err != nil {
+		t.Error("a file that is not a cache entry was removed")
+	}
+}
+
+func TestCachingChatModelEvictsLeastRecentlyUsed(t *testing.T) {
+	inner := &countingChatModel{size: 400 << 10}
+	dir := t.TempDir()
+	m, err := NewCachingChatModel(inner, CacheConfig{Dir: dir, MaxMB: 1}, "scope")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	path := func(content string) string {
+		key, _ := m.key(cacheRequest(content))
+		return filepath.Join(dir, key+".json")
+	}
+
+	for i, content := range []string{"a", "b"} {
+		if _, err := m.Chat(ctx, cacheRequest(content)); err != nil {
+			t.Fatal(err)
+		}
+		at := time.Now().Add(time.Duration(i-10) * time.Minute)
+		os.Chtimes(path(content), at, at)
+	}
+	// a hit makes a the most recently used entry, so c evicts b
+	if _, err := m.Chat(ctx, cacheRequest("a")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Chat(ctx, cacheRequest("c")); err != nil {
+		t.Fatal(err)
+	}
+
+	for content, kept := range map[string]bool{"a": true, "b": false, "c": true} {
+		if _, err := os.Stat(path(content)); (err == nil) != kept {
+			t.Errorf("entry %s kept = %t, want %t", content, err == nil, kept)
+		}
+	}
+	if inner.calls != 3 {
+		t.Errorf("inner called %d times, want 3", inner.calls)
+	}
+}
+
+func TestCacheConfigFromEnv(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want CacheConfig
+	}{
+		{name: "defaults", want: CacheConfig{TTL: defaultCacheConfig.TTL, MaxMB: defaultCacheConfig.MaxMB}},
+		{
+			name: "set",
+			env:  map[string]string{"LLM_CACHE": "cache", "LLM_CACHE_TTL": "2h", "LLM_CACHE_MAX_MB": "5", "LLM_CACHE_BYPASS": "true"},
+			want: CacheConfig{Dir: "cache", TTL: 2 * time.Hour, MaxMB: 5, Bypass: true},
+		},
+		{
+			name: "invalid values keep the defaults",
+			env:  map[string]string{"LLM_CACHE_TTL": "-1h", "LLM_CACHE_MAX_MB": "lots", "LLM_CACHE_BYPASS": "maybe"},
+			want: CacheConfig{TTL: defaultCacheConfig.TTL, MaxMB: defaultCacheConfig.MaxMB},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range []string{"LLM_CACHE", "LLM_CACHE_TTL", "LLM_CACHE_MAX_MB", "LLM_CACHE_BYPASS"} {
+				t.Setenv(name, tt.env[name])
+			}
+			if got := *CacheConfigFromEnv(); got != tt.want {
+				t.Errorf("CacheConfigFromEnv() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Cassette modes.
+const (
+	CassetteModeRecord = "record"
+	CassetteModeReplay = "replay"
+)
+
+// CassetteConfig enables recording or replaying of chat completions in Dir.
+type CassetteConfig struct {
+	Dir  string
+	Mode string
+}
+
+// CassetteConfigFromEnv reads LLM_CASSETTE (directory) and LLM_CASSETTE_MODE (record or replay, default replay).
+func CassetteConfigFromEnv() *CassetteConfig {
+	cfg := &CassetteConfig{
+		Dir:  os.Getenv("LLM_CASSETTE"),
+		Mode: os.Getenv("LLM_CASSETTE_MODE"),
+	}
+	if cfg.Mode == "" {
+		cfg.Mode = CassetteModeReplay
+	}
+	return cfg
+}
+
+func (c *CassetteConfig) enabled() bool {
+	return c != nil && c.Dir != ""
+}
+
+// CassetteChatModel records chat completions of an inner ChatModel to files keyed by
+// a request hash, or replays them without touching the network.
+type CassetteChatModel struct {
+	inner ChatModel
+	dir   string
+	mode  string
+	model string
+}
+
+type cassetteRecord struct {
+	Key        string       `json:"key"`
+	Request    ChatRequest  `json:"request"`
+	Response   ChatResponse `json:"response"`
+	RecordedAt time.Time    `json:"recordedAt"`
+}
+
+// NewCassetteChatModel wraps inner. model is the default model of inner, it is part of the key
+// of requests that don't set one. inner may be nil in replay mode.
+func NewCassetteChatModel(inner ChatModel, dir, mode, model string) (*CassetteChatModel, error) {
+	switch mode {
+	case CassetteModeRecord:
+		if inner == nil {
+			return nil, errors.New("cassette record mode needs a chat model to record")
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("create cassette dir: %w", err)
+		}
+	case CassetteModeReplay:
+	default:
+		return nil, fmt.Errorf("unknown cassette mode %q, expected %s or %s", mode, CassetteModeRecord, CassetteModeReplay)
+	}
+
+	return &CassetteChatModel{inner: inner, dir: dir, mode: mode, model: model}, nil
+}
+
+func (m *CassetteChatModel) Chat(ctx context.Context, req ChatRequest) (ChatResponse, error) {
+	return m.roundTrip(ctx, req, func(ctx context.Context, req ChatRequest) (ChatResponse, error) {
+		return m.inner.Chat(ctx, req)
+	})
+}
+
+// ChatStream streams through the inner model while recording; a replayed answer is passed to onDelta at once.
+func (m *CassetteChatModel) ChatStream(ctx context.Context, req ChatRequest, onDelta func(string)) (ChatResponse, error) {
+	resp, err := m.roundTrip(ctx, req, func(ctx context.Context, req ChatRequest) (ChatResponse, error) {
+		return ChatOrStream(ctx, m.inner, req, onDelta)
+	})
+	if err == nil && m.mode == CassetteModeReplay && onDelta != nil && resp.Text != "" {
+		onDelta(resp.Text)
+	}
+	return resp, err
+}
+
+func (m *CassetteChatModel) roundTrip(ctx context.Context, req ChatRequest, call func(context.Context, ChatRequest) (ChatResponse, error)) (ChatResponse, error) {
+	// the default model only keys the request, the inner chain still gets the request as is,
+	// so a fallback chain can fail over while recording
+	keyReq := req
+	if keyReq.Model == "" {
+		keyReq.Model = m.model
+	}
+	key, err := cassetteKey(keyReq)
+	if err != nil {
+		return ChatResponse{}, err
+	}
+	path := filepath.Join(m.dir, key+".json")
+
+	if m.mode == CassetteModeReplay {
+		data, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			return ChatResponse{}, fmt.Errorf("cassette %s: no recording for request %s (model %s, %d messages)", m.dir, key, keyReq.Model, len(req.Messages))
+		}
+		if err != nil {
+			return ChatResponse{}, fmt.Errorf("read cassette: %w", err)
+		}
+		var record cassetteRecord
+		if err := json.Unmarshal(data, &record); err != nil {
+			return ChatResponse{}, fmt.Errorf("unmarshal cassette %s: %w", path, err)
+		}
+		return record.Response, nil
+	}
+
+	resp, err := call(ctx, req)
+	if err != nil {
+		return ChatResponse{}, err
+	}
+
+	data, err := json.MarshalIndent(cassetteRecord{Key: key, Request: keyReq, Response: resp, RecordedAt: time.Now()}, "", "  ")
+	if err != nil {
+		return ChatResponse{}, fmt.Errorf("marshal cassette: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return ChatResponse{}, fmt.Errorf("write cassette: %w", err)
+	}
+	return resp, nil
+}
+
+// cassetteKey hashes the JSON form of the request, so any change of model or messages is a new recording.
+func cassetteKey(req ChatRequest) (string, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return "", fmt.Errorf("marshal chat request: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
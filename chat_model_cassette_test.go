@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestCassetteRecordThenReplay(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	inner := &countingChatModel{}
+	recorder, err := NewCassetteChatModel(inner, dir, CassetteModeRecord, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorded, err := recorder.Chat(ctx, cacheRequest("Audi TT"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// replay needs no inner model
+	player, err := NewCassetteChatModel(nil, dir, CassetteModeReplay, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var streamed string
+	replayed, err := player.ChatStream(ctx, cacheRequest("Audi TT"), func(delta string) { streamed += delta })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replayed.Text != recorded.Text || replayed.Model != recorded.Model || streamed != recorded.Text {
+		t.Errorf("replayed %+v streaming %q, want the recorded %+v", replayed, streamed, recorded)
+	}
+
+	// the default model is part of the key
+	explicit := cacheRequest("Audi TT")
+	explicit.Model = "default"
+	if _, err := player.Chat(ctx, explicit); err != nil {
+		t.Errorf("request naming the default model: %v", err)
+	}
+	for _, req := range []ChatRequest{cacheRequest("BMW Z4"), {Model: "other", Messages: explicit.Messages}} {
+		if _, err := player.Chat(ctx, req); err == nil || !strings.Contains(err.Error(), "no recording") {
+			t.Errorf("replay of an unrecorded request = %v, want a no recording error", err)
+		}
+	}
+	if inner.calls != 1 {
+		t.Errorf("inner model called %d times, want once while recording", inner.calls)
+	}
+}
+
+func TestNewCassetteChatModelErrors(t *testing.T) {
+	if _, err := NewCassetteChatModel(nil, t.TempDir(), CassetteModeRecord, "m"); err == nil {
+		t.Error("record mode without a chat model succeeded")
+	}
+	if _, err := NewCassetteChatModel(&countingChatModel{}, t.TempDir(), "rewind", "m"); err == nil {
+		t.Error("unknown mode succeeded")
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"time"
+)
+
+// RetryPolicy is how FallbackChatModel retries a model before it fails over to the next one.
+type RetryPolicy struct {
+	// MaxRetries is the number of retries per model after the first attempt.
+	MaxRetries int
+	// BaseDelay is the wait before the first retry, it doubles with every retry up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+var defaultRetryPolicy = RetryPolicy{MaxRetries: 2, BaseDelay: time.Second, MaxDelay: 30 * time.Second}
+
+// FallbackChatModel asks an ordered list of models through one backend. Rate limits, server
+// errors and network failures are retried with exponential backoff and jitter, a Retry-After
+// of the server is respected. When a model still fails, or fails for another reason, the next
+// model is asked. ChatResponse.Model tells which model answered.
+type FallbackChatModel struct {
+	inner  ChatModel
+	models []string
+	policy RetryPolicy
+}
+
+// NewFallbackChatModel asks models in order through inner. A request that sets ChatRequest.Model
+// goes to that model only, still with retries.
+func NewFallbackChatModel(inner ChatModel, models []string, policy RetryPolicy) *FallbackChatModel {
+	return &FallbackChatModel{inner: inner, models: models, policy: policy}
+}
+
+func (m *FallbackChatModel) Chat(ctx context.Context, req ChatRequest) (ChatResponse, error) {
+	return m.call(ctx, req, func(ctx context.Context, req ChatRequest) (ChatResponse, bool, error) {
+		resp, err := m.inner.Chat(ctx, req)
+		return resp, false, err
+	})
+}
+
+// ChatStream implements ChatStreamer. Once a model has streamed text its failure is returned as is,
+// retrying would repeat the text already passed to onDelta.
+func (m *FallbackChatModel) ChatStream(ctx context.Context, req ChatRequest, onDelta func(string)) (ChatResponse, error) {
+	return m.call(ctx, req, func(ctx context.Context, req ChatRequest) (ChatResponse, bool, error) {
+		streamed := false
+		resp, err := ChatOrStream(ctx, m.inner, req, func(delta string) {
+			streamed = true
+			if onDelta != nil {
+				onDelta(delta)
+			}
+		})
+		return resp, streamed, err
+	})
+}
+
+// call runs attempt for every model until one answers. attempt reports whether it delivered
+// output that can't be taken back.
+func (m *FallbackChatModel) call(ctx context.Context, req ChatRequest, attempt func(context.Context, ChatRequest) (ChatResponse, bool, error)) (ChatResponse, error) {
+	models := m.models
+	if req.Model != "" {
+		models = []string{req.Model}
+	}
+
+	var errs []error
+	for i, model := range models {
+		req.Model = model
+		for retry := 0; ; retry++ {
+			resp, delivered, err := attempt(ctx, req)
+			if err == nil {
+				if resp.Model == "" {
+					resp.Model = model
+				}
+				return resp, nil
+			}
+			if delivered || ctx.Err() != nil {
+				return ChatResponse{}, fmt.Errorf("model %s: %w", model, err)
+			}
+
+			wait, retryable := m.retryDelay(err, retry)
+			if !retryable || retry >= m.policy.MaxRetries {
+				errs = append(errs, fmt.Errorf("model %s: %w", model, err))
+				if i+1 < len(models) {
+					fmt.Printf("model %s failed, falling back to %s: %v\n", model, models[i+1], err)
+				}
+				break
+			}
+			fmt.Printf("model %s failed, retry %d/%d in %s: %v\n", model, retry+1, m.policy.MaxRetries, wait.Round(time.Millisecond), err)
+			if err := sleepContext(ctx, wait); err != nil {
+				return ChatResponse{}, err
+			}
+		}
+	}
+	if len(errs) == 1 {
+		return ChatResponse{}, errs[0]
+	}
+	return ChatResponse{}, fmt.Errorf("all %d models failed: %w", len(models), errors.Join(errs...))
+}
+
+// retryDelay decides whether err is worth retrying on the same model and how long to wait.
+// A Retry-After longer than MaxDelay fails over to the next model instead of waiting.
+func (m *FallbackChatModel) retryDelay(err error, retry int) (time.Duration, bool) {
+	var apiErr *ChatAPIError
+	if errors.As(err, &apiErr) {
+		if apiErr.StatusCode != http.StatusTooManyRequests && apiErr.StatusCode < http.StatusInternalServerError {
+			return 0, false
+		}
+		if apiErr.RetryAfter > 0 {
+			return apiErr.RetryAfter, apiErr.RetryAfter <= m.policy.MaxDelay
+		}
+		return m.backoff(retry), true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return m.backoff(retry), true
+	}
+	return 0, false
+}
+
+// backoff is BaseDelay doubled per retry and capped at MaxDelay, randomized to 50-100% of that,
+// so clients rate limited together don't retry together.
+func (m *FallbackChatModel) backoff(retry int) time.Duration {
+	delay := m.policy.BaseDelay << min(retry, 16)
+	if delay <= 0 || delay > m.policy.MaxDelay {
+		delay = m.policy.MaxDelay
+	}
+	half := delay / 2
+	if half <= 0 {
+		return delay
+	}
+	return half + rand.N(half+1)
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 12, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{value: "", want: 0},
+		{value: "7", want: 7 * time.Second},
+		{value: " 120 ", want: 2 * time.Minute},
+		{value: "0", want: 0},
+		{value: "-5", want: 0},
+		{value: "Mon, 01 Dec 2025 12:00:30 GMT", want: 30 * time.Second},
+		{value: "Mon, 01 Dec 2025 11:59:00 GMT", want: 0},
+		{value: "soon", want: 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value, now); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestFallbackRetryDelay(t *testing.T) {
+	m := NewFallbackChatModel(nil, nil, RetryPolicy{MaxRetries: 2, BaseDelay: 100 * time.Millisecond, MaxDelay: 10 * time.Second})
+	timeout := &net.DNSError{Err: "timeout", IsTimeout: true}
+	tests := []struct {
+		name      string
+		err       error
+		retryable bool
+		// exact is the expected wait, 0 means a backoff of the first retry
+		exact time.Duration
+	}{
+		{name: "rate limited", err: &ChatAPIError{StatusCode: http.StatusTooManyRequests}, retryable: true},
+		{name: "retry after", err: &ChatAPIError{StatusCode: http.StatusTooManyRequests, RetryAfter: 3 * time.Second}, retryable: true, exact: 3 * time.Second},
+		{name: "retry after too long", err: &ChatAPIError{StatusCode: http.StatusTooManyRequests, RetryAfter: time.Minute}},
+		{name: "server error", err: fmt.Errorf("chat: %w", &ChatAPIError{StatusCode: http.StatusBadGateway}), retryable: true},
+		{name: "bad request", err: &ChatAPIError{StatusCode: http.StatusBadRequest}},
+		{name: "not found", err: &ChatAPIError{StatusCode: http.StatusNotFound}},
+		{name: "network", err: fmt.Errorf("post: %w", timeout), retryable: true},
+		{name: "cut off", err: fmt.Errorf("read: %w", io.ErrUnexpectedEOF), retryable: true},
+		{name: "other", err: errors.New("marshal chat request")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wait, retryable := m.retryDelay(tt.err, 0)
+			if retryable != tt.retryable {
+				t.Fatalf("retryable = %t, want %t", retryable, tt.retryable)
+			}
+			switch {
+			case !retryable:
+			case tt.exact > 0 && wait != tt.exact:
+				t.Errorf("wait = %s, want %s", wait, tt.exact)
+			case tt.exact == 0 && (wait < 50*time.Millisecond || wait > 100*time.Millisecond):
+				t.Errorf("wait = %s, want a backoff of 50-100ms", wait)
+			}
+		})
+	}
+}
+
+func TestFallbackBackoff(t *testing.T) {
+	m := NewFallbackChatModel(nil, nil, RetryPolicy{BaseDelay: time.Second, MaxDelay: 5 * time.Second})
+	tests := []struct {
+		retry    int
+		min, max time.Duration
+	}{
+		{retry: 0, min: 500 * time.Millisecond, max: time.Second},
+		{retry: 1, min: time.Second, max: 2 * time.Second},
+		{retry: 2, min: 2 * time.Second, max: 4 * time.Second},
+		{retry: 3, min: 2500 * time.Millisecond, max: 5 * time.Second},
+		{retry: 60, min: 2500 * time.Millisecond, max: 5 * time.Second},
+	}
+	for _, tt := range tests {
+		for range 20 {
+			if got := m.backoff(tt.retry); got < tt.min || got > tt.max {
+				t.Fatalf("backoff(%d) = %s, want %s-%s", tt.retry, got, tt.min, tt.max)
+			}
+		}
+	}
+}
+
+// modelScriptChatModel fails the first calls of each model with the scripted errors, then answers.
+type modelScriptChatModel struct {
+	mu     sync.Mutex
+	errs   map[string][]error
+	called []string
+}
+
+func (m *modelScriptChatModel) Chat(_ context.Context, req ChatRequest) (ChatResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.called = append(m.called, req.Model)
+	if errs := m.errs[req.Model]; len(errs) > 0 {
+		m.errs[req.Model] = errs[1:]
+		return ChatResponse{}, errs[0]
+	}
+	return ChatResponse{Text: "ok"}, nil
+}
+
+func TestFallbackChatModel(t *testing.T) {
+	rateLimited := &ChatAPIError{StatusCode: http.StatusTooManyRequests, Message: "slow down"}
+	unsupported := &ChatAPIError{StatusCode: http.StatusBadRequest, Message: "bad model"}
+	tests := []struct {
+		name      string
+		model     string
+		errs      map[string][]error
+		wantModel string
+		wantCalls string
+		wantErr   string
+	}{
+		{name: "first answers", wantModel: "a", wantCalls: "a"},
+		{name: "retried", errs: map[string][]error{"a": {rateLimited, rateLimited}}, wantModel: "a", wantCalls: "a,a,a"},
+		{name: "retries exhausted", errs: map[string][]error{"a": {rateLimited, rateLimited, rateLimited}}, wantModel: "b", wantCalls: "a,a,a,b"},
+		{name: "not retryable", errs: map[string][]error{"a": {unsupported}}, wantModel: "b", wantCalls: "a,b"},
+		{name: "all fail", errs: map[string][]error{"a": {unsupported}, "b": {unsupported}}, wantCalls: "a,b", wantErr: "all 2 models failed"},
+		{name: "request model", model: "c", errs: map[string][]error{"c": {rateLimited}}, wantModel: "c", wantCalls: "c,c"},
+		{name: "request model fails alone", model: "c", errs: map[string][]error{"c": {unsupported}}, wantCalls: "c", wantErr: "model c: chat api error, status code: 400"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inner := &modelScriptChatModel{errs: tt.errs}
+			m := NewFallbackChatModel(inner, []string{"a", "b"}, RetryPolicy{MaxRetries: 2, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond})
+			resp, err := m.Chat(context.Background(), ChatRequest{Model: tt.model})
+			if got := strings.Join(inner.called, ","); got != tt.wantCalls {
+				t.Errorf("called %s, want %s", got, tt.wantCalls)
+			}
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.Model != tt.wantModel {
+				t.Errorf("answered by %q, want %q", resp.Model, tt.wantModel)
+			}
+		})
+	}
+}
+
+// streamFailChatModel streams a delta and then fails.
+type streamFailChatModel struct{ calls int }
+
+func (m *streamFailChatModel) Chat(context.Context, ChatRequest) (ChatResponse, error) {
+	return ChatResponse{}, errors.New("not streamed")
+}
+
+func (m *streamFailChatModel) ChatStream(_ context.Context, _ ChatRequest, onDelta func(string)) (ChatResponse, error) {
+	m.calls++
+	onDelta("partial")
+	return ChatResponse{}, &ChatAPIError{StatusCode: http.StatusBadGateway}
+}
+
+func TestFallbackChatModelStreamedFailureIsFinal(t *testing.T) {
+	inner := &streamFailChatModel{}
+	m := NewFallbackChatModel(inner, []string{"a", "b"}, RetryPolicy{MaxRetries: 2, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond})
+	if _, err := m.ChatStream(context.Background(), ChatRequest{}, nil); err == nil {
+		t.Fatal("ChatStream succeeded")
+	}
+	if inner.calls != 1 {
+		t.Errorf("a failure after streamed text was tried %d times, want 1", inner.calls)
+	}
+}
@@ -0,0 +1,346 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OpenAIChatModel is a ChatModel for any endpoint implementing the OpenAI
+// POST {baseURL}/chat/completions API.
+type OpenAIChatModel struct {
+	httpClient *http.Client
+	baseURL    string
+	apiKey     string
+	model      string
+}
+
+func NewOpenAIChatModel(baseURL, apiKey, model string) *OpenAIChatModel {
+	return &OpenAIChatModel{
+		httpClient: &http.Client{},
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		apiKey:     apiKey,
+		model:      model,
+	}
+}
+
+// ChatAPIError is a non-2xx answer of a chat completion endpoint.
+type ChatAPIError struct {
+	StatusCode int
+	Message    string
+	// RetryAfter is the wait the server asked for with a Retry-After header, 0 when it didn't.
+	RetryAfter time.Duration
+}
+
+func (e *ChatAPIError) Error() string {
+	return fmt.Sprintf("chat api error, status code: %d, message: %s", e.StatusCode, e.Message)
+}
+
+type openAIChatRequest struct {
+	Model          string                `json:"model"`
+	Messages       []openAIMessage       `json:"messages"`
+	Tools          []openAITool          `json:"tools,omitempty"`
+	ToolChoice     string                `json:"tool_choice,omitempty"`
+	ResponseFormat *openAIResponseFormat `json:"response_format,omitempty"`
+	Stream         bool                  `json:"stream,omitempty"`
+	StreamOptions  *openAIStreamOptions  `json:"stream_options,omitempty"`
+}
+
+type openAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type openAIMessage struct {
+	Role       string           `json:"role"`
+	Content    string           `json:"content"`
+	ToolCalls  []openAIToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+}
+
+type openAITool struct {
+	Type     string   `json:"type"`
+	Function ChatTool `json:"function"`
+}
+
+type openAIToolCall struct {
+	// Index is set in stream chunks only.
+	Index    *int               `json:"index,omitempty"`
+	ID       string             `json:"id,omitempty"`
+	Type     string             `json:"type"`
+	Function openAIFunctionCall `json:"function"`
+}
+
+type openAIFunctionCall struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+type openAIResponseFormat struct {
+	Type       string              `json:"type"`
+	JSONSchema *ChatResponseFormat `json:"json_schema,omitempty"`
+}
+
+type openAIChatResponse struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Message struct {
+			Content   string           `json:"content"`
+			ToolCalls []openAIToolCall `json:"tool_calls"`
+		} `json:"message"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *openAIUsage `json:"usage"`
+}
+
+type openAIChatStreamChunk struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Index int `json:"index"`
+		Delta struct {
+			Content   string           `json:"content"`
+			ToolCalls []openAIToolCall `json:"tool_calls"`
+		} `json:"delta"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *openAIUsage `json:"usage"`
+}
+
+type openAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+	// Cost is reported by some endpoints, e.g. OpenRouter.
+	Cost *float64 `json:"cost"`
+}
+
+func (u *openAIUsage) chatUsage() ChatUsage {
+	usage := ChatUsage{
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+		TotalTokens:      u.TotalTokens,
+	}
+	if u.Cost != nil {
+		usage.Cost, usage.CostReported = *u.Cost, true
+	}
+	return usage
+}
+
+type openAIErrorResponse struct {
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (m *OpenAIChatModel) newRequest(req ChatRequest) openAIChatRequest {
+	model := req.Model
+	if model == "" {
+		model = m.model
+	}
+
+	apiReq := openAIChatRequest{
+		Model:      model,
+		ToolChoice: req.ToolChoice,
+	}
+	for _, msg := range req.Messages {
+		apiMsg := openAIMessage{Role: msg.Role, Content: msg.Content, ToolCallID: msg.ToolCallID}
+		for _, call := range msg.ToolCalls {
+			apiMsg.ToolCalls = append(apiMsg.ToolCalls, openAIToolCall{
+				ID:       call.ID,
+				Type:     "function",
+				Function: openAIFunctionCall{Name: call.Name, Arguments: call.Arguments},
+			})
+		}
+		apiReq.Messages = append(apiReq.Messages, apiMsg)
+	}
+	for _, tool := range req.Tools {
+		apiReq.Tools = append(apiReq.Tools, openAITool{Type: "function", Function: tool})
+	}
+	if req.ResponseFormat != nil {
+		apiReq.ResponseFormat = &openAIResponseFormat{Type: "json_schema", JSONSchema: req.ResponseFormat}
+	}
+	return apiReq
+}
+
+// post sends apiReq and returns the response of a 2xx answer, the caller closes its body.
+func (m *OpenAIChatModel) post(ctx context.Context, apiReq openAIChatRequest, accept string) (*http.Response, error) {
+	body, err := json.Marshal(apiReq)
+	if err != nil {
+		return nil, fmt.Errorf("marshal chat request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, m.baseURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", accept)
+	if m.apiKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+m.apiKey)
+	}
+
+	httpResp, err := m.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	if httpResp.StatusCode >= http.StatusOK && httpResp.StatusCode < http.StatusMultipleChoices {
+		return httpResp, nil
+	}
+	defer httpResp.Body.Close()
+	return nil, newChatAPIError(httpResp)
+}
+
+// newChatAPIError reads a non-2xx response. The message of an OpenAI-style error body is
+// preferred over the raw body.
+func newChatAPIError(httpResp *http.Response) error {
+	respBody, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return fmt.Errorf("read chat response: %w", err)
+	}
+	apiErr := &ChatAPIError{
+		StatusCode: httpResp.StatusCode,
+		Message:    strings.TrimSpace(string(respBody)),
+		RetryAfter: parseRetryAfter(httpResp.Header.Get("Retry-After"), time.Now()),
+	}
+	var errResp openAIErrorResponse
+	if json.Unmarshal(respBody, &errResp) == nil && errResp.Error != nil {
+		apiErr.Message = errResp.Error.Message
+	}
+	return apiErr
+}
+
+// parseRetryAfter reads a Retry-After value in seconds or as an HTTP date, 0 when it is missing or invalid.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return max(time.Duration(seconds)*time.Second, 0)
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(at.Sub(now), 0)
+	}
+	return 0
+}
+
+func (m *OpenAIChatModel) Chat(ctx context.Context, req ChatRequest) (ChatResponse, error) {
+	apiReq := m.newRequest(req)
+	httpResp, err := m.post(ctx, apiReq, "application/json")
+	if err != nil {
+		return ChatResponse{}, err
+	}
+	defer httpResp.Body.Close()
+
+	respBody, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return ChatResponse{}, fmt.Errorf("read chat response: %w", err)
+	}
+
+	var resp openAIChatResponse
+	if err := json.Unmarshal(respBody, &resp); err != nil {
+		return ChatResponse{}, fmt.Errorf("unmarshal chat response: %w", err)
+	}
+	if len(resp.Choices) == 0 {
+		return ChatResponse{}, errors.New("chat response has no choices")
+	}
+
+	chatResp := ChatResponse{
+		Text:         resp.Choices[0].Message.Content,
+		Model:        resp.Model,
+		FinishReason: resp.Choices[0].FinishReason,
+	}
+	if chatResp.Model == "" {
+		chatResp.Model = apiReq.Model
+	}
+	for _, call := range resp.Choices[0].Message.ToolCalls {
+		chatResp.ToolCalls = append(chatResp.ToolCalls, ChatToolCall{
+			ID:        call.ID,
+			Name:      call.Function.Name,
+			Arguments: call.Function.Arguments,
+		})
+	}
+	if resp.Usage != nil {
+		chatResp.Usage = resp.Usage.chatUsage()
+	}
+	return chatResp, nil
+}
+
+// ChatStream implements ChatStreamer by reading the server-sent events of a "stream": true request.
+func (m *OpenAIChatModel) ChatStream(ctx context.Context, req ChatRequest, onDelta func(string)) (ChatResponse, error) {
+	apiReq := m.newRequest(req)
+	apiReq.Stream = true
+	apiReq.StreamOptions = &openAIStreamOptions{IncludeUsage: true}
+
+	httpResp, err := m.post(ctx, apiReq, "text/event-stream")
+	if err != nil {
+		return ChatResponse{}, err
+	}
+	defer httpResp.Body.Close()
+
+	var text strings.Builder
+	chatResp := ChatResponse{Model: apiReq.Model}
+	done := false
+	scanner := bufio.NewScanner(httpResp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			// comments, event names and blank separators
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			done = true
+			break
+		}
+
+		var chunk openAIChatStreamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return ChatResponse{}, fmt.Errorf("unmarshal chat stream chunk: %w", err)
+		}
+		if chunk.Model != "" {
+			chatResp.Model = chunk.Model
+		}
+		if chunk.Usage != nil {
+			chatResp.Usage = chunk.Usage.chatUsage()
+		}
+		for _, choice := range chunk.Choices {
+			if choice.Index != 0 {
+				continue
+			}
+			if choice.FinishReason != "" {
+				chatResp.FinishReason = choice.FinishReason
+			}
+			for i, call := range choice.Delta.ToolCalls {
+				index := i
+				if call.Index != nil {
+					index = *call.Index
+				}
+				chatResp.ToolCalls = mergeToolCallDelta(chatResp.ToolCalls, index, call.ID, call.Function.Name, call.Function.Arguments)
+			}
+			if choice.Delta.Content != "" {
+				text.WriteString(choice.Delta.Content)
+				if onDelta != nil {
+					onDelta(choice.Delta.Content)
+				}
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return ChatResponse{}, fmt.Errorf("read chat stream: %w", err)
+	}
+	if !done && chatResp.FinishReason == "" {
+		return ChatResponse{}, errors.New("chat stream ended without a completion")
+	}
+
+	chatResp.Text = text.String()
+	return chatResp, nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestOpenAIChatModelChat(t *testing.T) {
+	var got openAIChatRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" || r.Header.Get("Authorization") != "Bearer key" {
+			t.Errorf("request %s with Authorization %q", r.URL.Path, r.Header.Get("Authorization"))
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		fmt.Fprint(w, `{"model":"served","choices":[{"message":{"content":"hi","tool_calls":[
+			{"id":"call-1","type":"function","function":{"name":"ask_user","arguments":"{}"}}]},
+			"finish_reason":"tool_calls"}],"usage":{"prompt_tokens":3,"completion_tokens":2,"total_tokens":5,"cost":0}}`)
+	}))
+	defer server.Close()
+
+	model := NewOpenAIChatModel(server.URL+"/v1/", "key", "default")
+	resp, err := model.Chat(context.Background(), ChatRequest{
+		Messages: []ChatMessage{
+			{Role: ChatRoleAssistant, ToolCalls: []ChatToolCall{{ID: "call-0", Name: "ask_user", Arguments: "{}"}}},
+			{Role: ChatRoleTool, ToolCallID: "call-0", Content: "Audi TT"},
+		},
+		Tools:      []ChatTool{{Name: "ask_user", Parameters: json.RawMessage(`{"type":"object"}`)}},
+		ToolChoice: ChatToolChoiceRequired,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Model != "default" || got.ToolChoice != ChatToolChoiceRequired || len(got.Tools) != 1 || got.Tools[0].Type != "function" {
+		t.Errorf("sent %+v", got)
+	}
+	if len(got.Messages) != 2 || got.Messages[0].ToolCalls[0].Function.Name != "ask_user" || got.Messages[1].ToolCallID != "call-0" {
+		t.Errorf("sent messages %+v", got.Messages)
+	}
+	want := ChatUsage{PromptTokens: 3, CompletionTokens: 2, TotalTokens: 5, CostReported: true}
+	if resp.Text != "hi" || resp.Model != "served" || resp.FinishReason != "tool_calls" || resp.Usage != want {
+		t.Errorf("response %+v", resp)
+	}
+	if len(resp.ToolCalls) != 1 || resp.ToolCalls[0] != (ChatToolCall{ID: "call-1", Name: "ask_user", Arguments: "{}"}) {
+		t.Errorf("tool calls %+v", resp.ToolCalls)
+	}
+}
+
+func TestOpenAIChatModelAPIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "7")
+		w.WriteHeader(http.StatusTooManyRequests)
+		fmt.Fprint(w, `{"error":{"message":"slow down"}}`)
+	}))
+	defer server.Close()
+
+	_, err := NewOpenAIChatModel(server.URL, "", "m").Chat(context.Background(), ChatRequest{})
+	var apiErr *ChatAPIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("Chat = %v, want a ChatAPIError", err)
+	}
+	if apiErr.StatusCode != http.StatusTooManyRequests || apiErr.Message != "slow down" || apiErr.RetryAfter != 7*time.Second {
+		t.Errorf("error %+v", apiErr)
+	}
+}
+
+func TestOpenAIChatModelChatStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req openAIChatRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if !req.Stream || req.StreamOptions == nil || !req.StreamOptions.IncludeUsage {
+			t.Errorf("stream request %+v", req)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, ": keep-alive\n\n")
+		fmt.Fprint(w, `data: {"model":"served","choices":[{"index":0,"delta":{"content":"Audi"}}]}`+"\n\n")
+		fmt.Fprint(w, `data: {"choices":[{"index":0,"delta":{"content":" TT"},"finish_reason":"stop"}]}`+"\n\n")
+		fmt.Fprint(w, `data: {"choices":[],"usage":{"prompt_tokens":1,"completion_tokens":2,"total_tokens":3}}`+"\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	var deltas []string
+	resp, err := NewOpenAIChatModel(server.URL, "", "m").ChatStream(context.Background(), ChatRequest{}, func(delta string) {
+		deltas = append(deltas, delta)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deltas) != 2 || resp.Text != "Audi TT" || resp.Model != "served" || resp.FinishReason != "stop" || resp.Usage.TotalTokens != 3 {
+		t.Errorf("deltas %q, response %+v", deltas, resp)
+	}
+}
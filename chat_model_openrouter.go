@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/revrost/go-openrouter"
+)
+
+// OpenRouterChatModel is a ChatModel backed by the OpenRouter API.
+type OpenRouterChatModel struct {
+	client *openrouter.Client
+	model  string
+}
+
+func NewOpenRouterChatModel(apiKey, baseURL, model string) *OpenRouterChatModel {
+	opts := []openrouter.Option{func(c *openrouter.ClientConfig) {
+		c.HTTPClient = openRouterDoer{client: &http.Client{}}
+	}}
+	if baseURL != "" {
+		opts = append(opts, func(c *openrouter.ClientConfig) {
+			c.BaseURL = baseURL
+		})
+	}
+	return &OpenRouterChatModel{
+		client: openrouter.NewClient(apiKey, opts...),
+		model:  model,
+	}
+}
+
+// openRouterDoer turns non-2xx answers into a ChatAPIError before the openrouter client reads
+// them, the client drops the Retry-After header.
+type openRouterDoer struct {
+	client *http.Client
+}
+
+func (d openRouterDoer) Do(req *http.Request) (*http.Response, error) {
+	resp, err := d.client.Do(req)
+	if err != nil || (resp.StatusCode >= http.StatusOK && resp.StatusCode < http.StatusBadRequest) {
+		return resp, err
+	}
+	defer resp.Body.Close()
+	return nil, newChatAPIError(resp)
+}
+
+func (m *OpenRouterChatModel) newRequest(req ChatRequest) openrouter.ChatCompletionRequest {
+	model := req.Model
+	if model == "" {
+		model = m.model
+	}
+
+	messages := make([]openrouter.ChatCompletionMessage, 0, len(req.Messages))
+	for _, msg := range req.Messages {
+		orMsg := openrouter.ChatCompletionMessage{
+			Role:       msg.Role,
+			Content:    openrouter.Content{Text: msg.Content},
+			ToolCallID: msg.ToolCallID,
+		}
+		for _, call := range msg.ToolCalls {
+			orMsg.ToolCalls = append(orMsg.ToolCalls, openrouter.ToolCall{
+				ID:       call.ID,
+				Type:     openrouter.ToolTypeFunction,
+				Function: openrouter.FunctionCall{Name: call.Name, Arguments: call.Arguments},
+			})
+		}
+		messages = append(messages, orMsg)
+	}
+
+	orReq := openrouter.ChatCompletionRequest{
+		Model:    model,
+		Messages: messages,
+		// asks OpenRouter for the cost of the completion
+		Usage: &openrouter.IncludeUsage{Include: true},
+	}
+	for _, tool := range req.Tools {
+		orReq.Tools = append(orReq.Tools, openrouter.Tool{
+			Type: openrouter.ToolTypeFunction,
+			Function: &openrouter.FunctionDefinition{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.Parameters,
+			},
+		})
+	}
+	if req.ToolChoice != "" {
+		orReq.ToolChoice = req.ToolChoice
+	}
+	if req.ResponseFormat != nil {
+		orReq.ResponseFormat = &openrouter.ChatCompletionResponseFormat{
+			Type: openrouter.ChatCompletionResponseFormatTypeJSONSchema,
+			JSONSchema: &openrouter.ChatCompletionResponseFormatJSONSchema{
+				Name:   req.ResponseFormat.Name,
+				Schema: req.ResponseFormat.Schema,
+				Strict: req.ResponseFormat.Strict,
+			},
+		}
+	}
+	return orReq
+}
+
+func (m *OpenRouterChatModel) Chat(ctx context.Context, req ChatRequest) (ChatResponse, error) {
+	orReq := m.newRequest(req)
+	resp, err := m.client.CreateChatCompletion(ctx, orReq)
+	if err != nil {
+		return ChatResponse{}, err
+	}
+	if len(resp.Choices) == 0 {
+		return ChatResponse{}, errors.New("openrouter: response has no choices")
+	}
+
+	chatResp := ChatResponse{
+		Text:         resp.Choices[0].Message.Content.Text,
+		Model:        resp.Model,
+		FinishReason: string(resp.Choices[0].FinishReason),
+	}
+	if chatResp.Model == "" {
+		chatResp.Model = orReq.Model
+	}
+	for _, call := range resp.Choices[0].Message.ToolCalls {
+		chatResp.ToolCalls = append(chatResp.ToolCalls, ChatToolCall{
+			ID:        call.ID,
+			Name:      call.Function.Name,
+			Arguments: call.Function.Arguments,
+		})
+	}
+	if resp.Usage != nil {
+		chatResp.Usage = ChatUsage{
+			PromptTokens:     resp.Usage.PromptTokens,
+			CompletionTokens: resp.Usage.CompletionTokens,
+			TotalTokens:      resp.Usage.TotalTokens,
+			Cost:             resp.Usage.Cost,
+			CostReported:     true,
+		}
+	}
+	return chatResp, nil
+}
+
+// ChatStream implements ChatStreamer with the OpenRouter streaming API.
+func (m *OpenRouterChatModel) ChatStream(ctx context.Context, req ChatRequest, onDelta func(string)) (ChatResponse, error) {
+	orReq := m.newRequest(req)
+	orReq.StreamOptions = &openrouter.StreamOptions{IncludeUsage: true}
+
+	stream, err := m.client.CreateChatCompletionStream(ctx, orReq)
+	if err != nil {
+		return ChatResponse{}, err
+	}
+	defer stream.Close()
+
+	var text strings.Builder
+	chatResp := ChatResponse{Model: orReq.Model}
+	for {
+		chunk, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return ChatResponse{}, err
+		}
+		if chunk.Model != "" {
+			chatResp.Model = chunk.Model
+		}
+		if chunk.Usage != nil {
+			chatResp.Usage = ChatUsage{
+				PromptTokens:     chunk.Usage.PromptTokens,
+				CompletionTokens: chunk.Usage.CompletionTokens,
+				TotalTokens:      chunk.Usage.TotalTokens,
+				Cost:             chunk.Usage.Cost,
+				CostReported:     true,
+			}
+		}
+		for _, choice := range chunk.Choices {
+			if choice.Index != 0 {
+				continue
+			}
+			if choice.FinishReason != "" {
+				chatResp.FinishReason = string(choice.FinishReason)
+			}
+			for i, call := range choice.Delta.ToolCalls {
+				index := i
+				if call.Index != nil {
+					index = *call.Index
+				}
+				chatResp.ToolCalls = mergeToolCallDelta(chatResp.ToolCalls, index, call.ID, call.Function.Name, call.Function.Arguments)
+			}
+			if choice.Delta.Content != "" {
+				text.WriteString(choice.Delta.Content)
+				if onDelta != nil {
+					onDelta(choice.Delta.Content)
+				}
+			}
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return ChatResponse{}, err
+	}
+	if chatResp.FinishReason == "" && text.Len() == 0 && len(chatResp.ToolCalls) == 0 {
+		return ChatResponse{}, errors.New("openrouter: stream ended without a completion")
+	}
+
+	chatResp.Text = text.String()
+	return chatResp, nil
+}
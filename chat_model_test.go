@@ -0,0 +1,40 @@
+package main
+
+import "testing"
+
+func TestChatModelConfigFromEnvModel(t *testing.T) {
+	tests := []struct {
+		name       string
+		agent      string
+		agentModel string
+		model      string
+		want       string
+	}{
+		{name: "default", agent: usageAgentInspector, want: defaultInspectorModel},
+		{name: "LLM_MODEL", agent: usageAgentInspector, model: "shared", want: "shared"},
+		{name: "agent model", agent: usageAgentInspector, agentModel: "judge", want: "judge"},
+		{name: "agent model wins", agent: usageAgentInspector, agentModel: "judge", model: "shared", want: "judge"},
+		{name: "no agent", model: "shared", want: "shared"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("LLM_MODEL", tt.model)
+			t.Setenv("LLM_INSPECTOR_MODEL", tt.agentModel)
+			cfg := ChatModelConfigFromEnv(tt.agent, defaultInspectorModel)
+			if cfg.Model != tt.want {
+				t.Errorf("Model = %q, want %q", cfg.Model, tt.want)
+			}
+			if cfg.Agent != tt.agent {
+				t.Errorf("Agent = %q, want %q", cfg.Agent, tt.agent)
+			}
+		})
+	}
+}
+
+func TestChatModelConfigFromEnvOtherAgent(t *testing.T) {
+	t.Setenv("LLM_MODEL", "")
+	t.Setenv("LLM_INSPECTOR_MODEL", "judge")
+	if cfg := ChatModelConfigFromEnv(usageAgentInterviewer, defaultInterviewerModel); cfg.Model != defaultInterviewerModel {
+		t.Errorf("LLM_INSPECTOR_MODEL changed the interviewer model to %q", cfg.Model)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+type usageSessionKey struct{}
+
+// WithUsageSession attributes the completions made with ctx to session id in the usage log.
+func WithUsageSession(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, usageSessionKey{}, id)
+}
+
+func usageSession(ctx context.Context) string {
+	id, _ := ctx.Value(usageSessionKey{}).(string)
+	return id
+}
+
+// MeteredChatModel records the usage of every completion of an inner ChatModel and enforces
+// the budgets of its agent: an exceeded budget fails the call or downgrades the model.
+type MeteredChatModel struct {
+	inner   ChatModel
+	tracker *UsageTracker
+	agent   string
+	// model is the first model of inner, the one a request without ChatRequest.Model goes to.
+	model string
+}
+
+func NewMeteredChatModel(inner ChatModel, tracker *UsageTracker, agent, model string) *MeteredChatModel {
+	return &MeteredChatModel{inner: inner, tracker: tracker, agent: agent, model: model}
+}
+
+func (m *MeteredChatModel) Chat(ctx context.Context, req ChatRequest) (ChatResponse, error) {
+	return m.call(ctx, req, m.inner.Chat)
+}
+
+// ChatStream implements ChatStreamer.
+func (m *MeteredChatModel) ChatStream(ctx context.Context, req ChatRequest, onDelta func(string)) (ChatResponse, error) {
+	return m.call(ctx, req, func(ctx context.Context, req ChatRequest) (ChatResponse, error) {
+		return ChatOrStream(ctx, m.inner, req, onDelta)
+	})
+}
+
+func (m *MeteredChatModel) call(ctx context.Context, req ChatRequest, do func(context.Context, ChatRequest) (ChatResponse, error)) (ChatResponse, error) {
+	session := usageSession(ctx)
+	downgrade, err := m.tracker.Check(m.agent, session)
+	if err != nil {
+		return ChatResponse{}, err
+	}
+	record := UsageRecord{Agent: m.agent, Session: session}
+	if downgrade != "" && req.Model != downgrade {
+		record.DowngradedFrom = req.Model
+		if record.DowngradedFrom == "" {
+			record.DowngradedFrom = m.model
+		}
+		req.Model = downgrade
+	}
+
+	resp, err := do(ctx, req)
+	if err != nil {
+		return resp, err
+	}
+	record.Time = time.Now()
+	record.Model = resp.Model
+	m.tracker.Record(record, resp.Usage)
+	return resp, nil
+}
+
+// newMeteredChatModel wraps inner when cfg has a UsageConfig.
+func newMeteredChatModel(cfg ChatModelConfig, inner ChatModel) (ChatModel, error) {
+	if cfg.Usage == nil {
+		return inner, nil
+	}
+	tracker, err := cfg.Usage.Tracker()
+	if err != nil {
+		return nil, err
+	}
+	agent := cfg.Agent
+	if agent == "" {
+		agent = "default"
+	}
+	return NewMeteredChatModel(inner, tracker, agent, cfg.Model), nil
+}
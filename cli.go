@@ -0,0 +1,869 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Exit codes returned by runCLI.
+const (
+	exitOK      = 0
+	exitFailure = 1
+	exitUsage   = 2
+)
+
+type cliCommand struct {
+	name    string
+	summary string
+	run     func(args []string) error
+}
+
+// usageError marks errors caused by bad command-line input, they exit with exitUsage.
+type usageError struct {
+	msg string
+}
+
+func (e *usageError) Error() string {
+	return e.msg
+}
+
+func newUsageError(format string, args ...any) error {
+	return &usageError{msg: fmt.Sprintf(format, args...)}
+}
+
+func cliCommands() []cliCommand {
+	return []cliCommand{
+		{name: "interview", summary: "interactive data-collection dialog (interviewer + inspector)", run: cmdInterview},
+		{name: "serve", summary: "HTTP API for interviews, replies streamed as Server-Sent Events", run: cmdServe},
+		{name: "telegram", summary: "Telegram bot for interviews, one interviewer per chat", run: cmdTelegram},
+		{name: "usage", summary: "token usage and cost totals from the usage log", run: cmdUsage},
+		{name: "prompts", summary: "list prompt templates or render them with -prompts-dir overrides", run: cmdPrompts},
+		{name: "digest", summary: "summarize GitHub notifications and send them to Telegram", run: cmdDigest},
+		{name: "mcp", summary: "call GitHub MCP server tools", run: cmdMCP},
+		{name: "docker-build", summary: "build a docker image from a Dockerfile", run: cmdDockerBuild},
+		{name: "testgen", summary: "generate pytest tests for a python file and run them in docker", run: cmdTestgen},
+	}
+}
+
+func runCLI(args []string) int {
+	if len(args) == 0 {
+		printUsage(os.Stderr)
+		return exitUsage
+	}
+
+	name := args[0]
+	if name == "help" || name == "-h" || name == "-help" || name == "--help" {
+		if len(args) > 1 {
+			return runCLI([]string{args[1], "-h"})
+		}
+		printUsage(os.Stdout)
+		return exitOK
+	}
+
+	for _, cmd := range cliCommands() {
+		if cmd.name != name {
+			continue
+		}
+		err := cmd.run(args[1:])
+		var usageErr *usageError
+		switch {
+		case err == nil, errors.Is(err, flag.ErrHelp):
+			return exitOK
+		case errors.As(err, &usageErr):
+			fmt.Fprintf(os.Stderr, "advent %s: %v\n", name, err)
+			return exitUsage
+		case isFlagParseError(err):
+			// flag package already printed the error and usage
+			return exitUsage
+		default:
+			fmt.Fprintf(os.Stderr, "advent %s: %v\n", name, err)
+			return exitFailure
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "advent: unknown command %q\n\n", name)
+	printUsage(os.Stderr)
+	return exitUsage
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: advent <command> [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, cmd := range cliCommands() {
+		fmt.Fprintf(w, "  %-14s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run 'advent help <command>' or 'advent <command> -h' for command flags.")
+}
+
+// flagParseError wraps errors returned by flag.FlagSet.Parse.
+type flagParseError struct {
+	err error
+}
+
+func (e *flagParseError) Error() string {
+	return e.err.Error()
+}
+
+func (e *flagParseError) Unwrap() error {
+	return e.err
+}
+
+func isFlagParseError(err error) bool {
+	var parseErr *flagParseError
+	return errors.As(err, &parseErr)
+}
+
+func newFlagSet(name, synopsis, description string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		out := fs.Output()
+		fmt.Fprintf(out, "Usage: advent %s %s\n\n%s\n\nFlags:\n", name, synopsis, description)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return &flagParseError{err: err}
+	}
+	if fs.NArg() > 0 {
+		return newUsageError("unexpected arguments: %v", fs.Args())
+	}
+	return nil
+}
+
+// registerChatModelFlags binds -<prefix>provider, -<prefix>base-url, -<prefix>model and -<prefix>max-retries to cfg.
+func registerChatModelFlags(fs *flag.FlagSet, prefix, agent string, cfg *ChatModelConfig) {
+	fs.StringVar(&cfg.Provider, prefix+"provider", cfg.Provider, agent+" chat backend: openrouter, openai (any OpenAI-compatible endpoint), or the local servers ollama, llamacpp and vllm")
+	fs.StringVar(&cfg.BaseURL, prefix+"base-url", cfg.BaseURL, agent+" chat API base URL, empty for the provider default")
+	fs.StringVar(&cfg.Model, prefix+"model", cfg.Model, agent+" model name, or comma-separated models asked in order when one fails (defaults to "+agentModelEnv(cfg.Agent)+", then LLM_MODEL)")
+	fs.IntVar(&cfg.Retry.MaxRetries, prefix+"max-retries", cfg.Retry.MaxRetries, agent+" retries of a rate-limited or failing model before the next model is asked (defaults to LLM_MAX_RETRIES)")
+}
+
+// registerCassetteFlags binds -cassette and -cassette-mode to a CassetteConfig shared by cfgs.
+func registerCassetteFlags(fs *flag.FlagSet, cfgs ...*ChatModelConfig) {
+	cassette := CassetteConfigFromEnv()
+	fs.StringVar(&cassette.Dir, "cassette", cassette.Dir, "directory of recorded chat completions, empty talks to the provider directly")
+	fs.StringVar(&cassette.Mode, "cassette-mode", cassette.Mode, "record: call the provider and save completions to -cassette; replay: serve saved completions offline")
+	for _, cfg := range cfgs {
+		cfg.Cassette = cassette
+	}
+}
+
+// registerCacheFlags binds -cache, -cache-ttl, -cache-max-mb and -cache-bypass to a CacheConfig shared by cfgs.
+func registerCacheFlags(fs *flag.FlagSet, cfgs ...*ChatModelConfig) {
+	cache := CacheConfigFromEnv()
+	fs.StringVar(&cache.Dir, "cache", cache.Dir, "directory of cached chat completions: a repeated request is answered from disk (defaults to LLM_CACHE), empty disables the cache")
+	fs.DurationVar(&cache.TTL, "cache-ttl", cache.TTL, "how long a cached completion is served, 0 forever (defaults to LLM_CACHE_TTL)")
+	fs.IntVar(&cache.MaxMB, "cache-max-mb", cache.MaxMB, "size limit of -cache in MB, least recently used completions are evicted first, 0 is unlimited (defaults to LLM_CACHE_MAX_MB)")
+	fs.BoolVar(&cache.Bypass, "cache-bypass", cache.Bypass, "ask the model even when a cached completion exists, and refresh the cache with the answer (defaults to LLM_CACHE_BYPASS)")
+	for _, cfg := range cfgs {
+		cfg.Cache = cache
+	}
+}
+
+// registerUsageFlags binds -usage-log, -prices and -budgets to a UsageConfig shared by cfgs.
+func registerUsageFlags(fs *flag.FlagSet, cfgs ...*ChatModelConfig) *UsageConfig {
+	usage := &UsageConfig{Path: UsageLogFromEnv()}
+	fs.StringVar(&usage.Path, "usage-log", usage.Path, "append token usage and cost of every completion to this JSONL file (defaults to ADVENT_USAGE_LOG or "+defaultUsageLog+"), empty keeps it in memory")
+	fs.StringVar(&usage.PricesPath, "prices", "", "model prices, JSON {\"model\": {\"prompt\": 0.27, \"completion\": 1.1}} in USD per 1M tokens, for providers that don't report the cost")
+	fs.StringVar(&usage.BudgetsPath, "budgets", "", "budgets file: per agent and run, day or session caps on cost or tokens that stop or downgrade the model")
+	for _, cfg := range cfgs {
+		cfg.Usage = usage
+	}
+	return usage
+}
+
+// registerPromptsFlag binds -prompts-dir, the directory of prompt template overrides.
+func registerPromptsFlag(fs *flag.FlagSet) *string {
+	return fs.String("prompts-dir", PromptsDirFromEnv(), "directory of <name>.tmpl files that replace the embedded prompts (defaults to ADVENT_PROMPTS_DIR), see advent prompts list")
+}
+
+func loadPrompts(dir string) (*Prompts, error) {
+	prompts, err := LoadPrompts(dir)
+	if err != nil {
+		return nil, newUsageError("%v", err)
+	}
+	return prompts, nil
+}
+
+// interviewFlags are the interviewer flags shared by the interview, serve and telegram commands.
+type interviewFlags struct {
+	withInspector  *bool
+	quorumPath     *string
+	rulesPath      *string
+	shellSafety    *string
+	schemaName     *string
+	wrapMarkers    *bool
+	maxRepairs     *int
+	repairLog      *string
+	maxRejections  *int
+	sinksPath      *string
+	normalizeUnits *bool
+	ratesPath      *string
+	stream         *bool
+	mode           *string
+	promptsDir     *string
+	compaction     *string
+	contextTokens  *int
+	contextShare   *float64
+	sessionsDir    *string
+	usage          *UsageConfig
+	interviewerCfg ChatModelConfig
+	inspectorCfg   ChatModelConfig
+}
+
+func registerInterviewFlags(fs *flag.FlagSet) *interviewFlags {
+	f := &interviewFlags{
+		withInspector:  fs.Bool("inspector", true, "send finalized responses to the LLM inspector agent"),
+		quorumPath:     fs.String("quorum", "", "quorum file: several LLM inspectors vote in parallel (unanimous, majority or weighted) instead of the single -inspector-model"),
+		rulesPath:      fs.String("rules", "", "rule file for the deterministic inspector that runs before the LLM one; empty uses the built-in zrsp rules, none disables it"),
+		shellSafety:    fs.String("shell-safety", ShellSeverityHigh.String(), "reject \"bash code\" items with findings of this severity or worse (low, medium, high, critical), none disables the check"),
+		schemaName:     fs.String("schema", defaultResponseSchemaName, "response schema: a built-in name or a JSON Schema file"),
+		wrapMarkers:    fs.Bool("wrap-markers", true, "wrap dialog messages into Z_PROVIDE_DATA / Z_COLLECT_DATA markers; false sends a plain conversation"),
+		maxRepairs:     fs.Int("max-repairs", 2, "how many times an invalid final answer is sent back to the model for correction"),
+		repairLog:      fs.String("repair-log", "", "append every repair attempt as a JSON line to this file"),
+		maxRejections:  fs.Int("max-rejections", 3, "how many inspector rejections send the interviewer back to collecting data before the dialog is reset"),
+		sinksPath:      fs.String("sinks", "", "sinks file: where accepted responses are written (jsonl, csv, markdown, sqlite) per schema"),
+		normalizeUnits: fs.Bool("normalize-units", false, "convert item values into canonical units (m, m/s, kg, s, B, the rates base currency) before inspection"),
+		ratesPath:      fs.String("rates", "", "currency rate table for -normalize-units and the units the zrsp rules accept, JSON {\"base\":\"usd\",\"rates\":{\"eur\":1.08}}; empty uses built-in rates"),
+		stream:         fs.Bool("stream", true, "print answers while they are generated; Z_RSP payloads are shown only after they parse"),
+		mode:           fs.String("mode", InterviewerModeMarkers, "how the model signals questions and answers: markers, tools (ask_user/submit_response calls) or json (response_format); tools and json fall back to markers"),
+		promptsDir:     registerPromptsFlag(fs),
+		compaction:     fs.String("context-strategy", ContextStrategySummarize, "what happens to older turns when the prompt nears the context window: summarize (into collected facts), drop or none"),
+		contextTokens:  fs.Int("context-window", 0, "context window of the interviewer model in tokens, 0 looks it up by model name (the smallest of a fallback list)"),
+		contextShare:   fs.Float64("context-threshold", defaultContextWindow.Threshold, "share of the context window the prompt may fill before older turns are compacted"),
+		sessionsDir:    fs.String("sessions-dir", SessionDirFromEnv(), "directory of session logs (defaults to ADVENT_SESSIONS_DIR or "+defaultSessionDir+"), empty disables them"),
+		interviewerCfg: ChatModelConfigFromEnv(usageAgentInterviewer, defaultInterviewerModel),
+		inspectorCfg:   ChatModelConfigFromEnv(usageAgentInspector, defaultInspectorModel),
+	}
+	registerChatModelFlags(fs, "", "interviewer", &f.interviewerCfg)
+	registerChatModelFlags(fs, "inspector-", "inspector", &f.inspectorCfg)
+	registerCassetteFlags(fs, &f.interviewerCfg, &f.inspectorCfg)
+	registerCacheFlags(fs, &f.interviewerCfg, &f.inspectorCfg)
+	f.usage = registerUsageFlags(fs, &f.interviewerCfg, &f.inspectorCfg)
+	return f
+}
+
+// interviewSetup is what interviewFlags resolve to. Interviewers built from it share the models,
+// inspectors and sinks.
+type interviewSetup struct {
+	flags  *interviewFlags
+	model  ChatModel
+	schema *ResponseSchema
+	// inspector is nil when nothing inspects the responses.
+	inspector AgentInspector
+	opts      []InterviewerOption
+	sinks     []ResponseSink
+	// store is nil when sessions are disabled.
+	store *SessionStore
+}
+
+func (f *interviewFlags) setup() (*interviewSetup, error) {
+	switch *f.mode {
+	case InterviewerModeMarkers, InterviewerModeTools, InterviewerModeJSON:
+	default:
+		return nil, newUsageError("unknown -mode %q", *f.mode)
+	}
+	schema, err := ResolveResponseSchema(*f.schemaName)
+	if err != nil {
+		return nil, newUsageError("%v", err)
+	}
+	switch *f.compaction {
+	case ContextStrategySummarize, ContextStrategyDrop, ContextStrategyNone:
+	default:
+		return nil, newUsageError("unknown -context-strategy %q", *f.compaction)
+	}
+	if *f.contextShare <= 0 || *f.contextShare > 1 {
+		return nil, newUsageError("-context-threshold must be in (0, 1], got %v", *f.contextShare)
+	}
+	var shellBlock ShellSeverity
+	if *f.shellSafety != "none" {
+		if shellBlock, err = ParseShellSeverity(*f.shellSafety); err != nil {
+			return nil, newUsageError("%v", err)
+		}
+	}
+
+	prompts, err := loadPrompts(*f.promptsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	setup := &interviewSetup{flags: f, schema: schema}
+	setup.opts = []InterviewerOption{
+		WithPrompts(prompts),
+		WithInterviewerMode(*f.mode),
+		WithMarkerWrapping(*f.wrapMarkers),
+		WithRepairs(*f.maxRepairs, *f.repairLog),
+		WithStreaming(*f.stream),
+		WithInspectionRounds(*f.maxRejections),
+		WithContextWindow(f.contextWindowOption()),
+	}
+	// the same units normalize values and are accepted by the zrsp rules
+	rates := defaultCurrencyRates
+	if *f.ratesPath != "" {
+		if rates, err = LoadCurrencyRates(*f.ratesPath); err != nil {
+			return nil, newUsageError("%v", err)
+		}
+	}
+	units := NewUnitRegistry(rates)
+	if *f.normalizeUnits {
+		setup.opts = append(setup.opts, WithUnitNormalization(units))
+	}
+	if *f.sessionsDir != "" {
+		setup.store = NewSessionStore(*f.sessionsDir)
+	}
+
+	if setup.model, err = NewChatModel(f.interviewerCfg); err != nil {
+		return nil, err
+	}
+
+	var inspectors []AgentInspector
+	switch {
+	case *f.rulesPath == "none":
+	case *f.rulesPath != "":
+		rules, err := LoadRuleSet(*f.rulesPath)
+		if err != nil {
+			return nil, newUsageError("%v", err)
+		}
+		inspectors = append(inspectors, NewRuleInspector(*f.rulesPath, *rules))
+	case schema.Name == defaultResponseSchemaName:
+		inspectors = append(inspectors, NewRuleInspector(defaultResponseSchemaName, ZRspRules(units)))
+	}
+	if shellBlock != 0 {
+		inspectors = append(inspectors, NewShellSafetyInspector(shellBlock))
+	}
+	switch {
+	case *f.quorumPath != "":
+		quorum, err := LoadQuorumInspector(*f.quorumPath, f.inspectorCfg, WithInspectorPrompts(prompts))
+		if err != nil {
+			return nil, err
+		}
+		inspectors = append(inspectors, quorum)
+	case *f.withInspector:
+		inspectorModel, err := NewChatModel(f.inspectorCfg)
+		if err != nil {
+			return nil, err
+		}
+		inspectors = append(inspectors, NewSimpleAgentInspector(inspectorModel, WithInspectorPrompts(prompts)))
+	}
+	if len(inspectors) > 0 {
+		setup.inspector = NewChainInspector(inspectors...)
+	}
+
+	// sinks are opened last, so an error above doesn't leave them open
+	if *f.sinksPath != "" {
+		if setup.sinks, err = LoadSinks(*f.sinksPath, schema.Name); err != nil {
+			return nil, newUsageError("%v", err)
+		}
+		if len(setup.sinks) == 0 {
+			fmt.Printf("sinks %s: nothing configured for schema %s\n", *f.sinksPath, schema.Name)
+		}
+		setup.opts = append(setup.opts, WithSinks(setup.sinks...))
+	}
+	return setup, nil
+}
+
+func (f *interviewFlags) contextWindowOption() ContextWindow {
+	window := ContextWindow{
+		Strategy:     *f.compaction,
+		ModelContext: ModelContextFor(f.interviewerCfg.Models()...),
+		Threshold:    *f.contextShare,
+	}
+	if *f.contextTokens > 0 {
+		window.Tokens = *f.contextTokens
+	}
+	return window
+}
+
+// Close closes the sinks and prints the usage of the run.
+func (setup *interviewSetup) Close() error {
+	return errors.Join(CloseSinks(setup.sinks), setup.flags.usage.Close(os.Stdout))
+}
+
+// createSession starts a session log with id, empty generates one. It returns nil when sessions are disabled.
+func (setup *interviewSetup) createSession(id string) (*Session, error) {
+	if setup.store == nil {
+		return nil, nil
+	}
+	meta := SessionMeta{ID: id, Model: setup.flags.interviewerCfg.Model, Schema: *setup.flags.schemaName, Mode: *setup.flags.mode}
+	if *setup.flags.withInspector {
+		meta.InspectorModel = setup.flags.inspectorCfg.Model
+	}
+	return setup.store.Create(meta)
+}
+
+// openInterviewer builds the interviewer of a frontend session. With sessions enabled it is logged
+// as session id, and a stored session id is resumed, e.g. after it was evicted for being idle.
+func (setup *interviewSetup) openInterviewer(id string) (*AgentInterviewer, error) {
+	var session *Session
+	var err error
+	if setup.store != nil && setup.store.Exists(id) {
+		session, err = setup.store.Open(id)
+	} else {
+		session, err = setup.createSession(id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	opts := slices.Clone(setup.opts)
+	if session != nil {
+		opts = append(opts, WithSession(session))
+	}
+	if setup.inspector == nil {
+		opts = append(opts, WithoutInspector())
+	}
+	return NewAgentInterviewer(setup.model, setup.inspector, setup.schema, opts...), nil
+}
+
+func cmdInterview(args []string) error {
+	fs := newFlagSet("interview", "[flags]",
+		"Runs the interactive Z_DIALOG: the interviewer collects data from stdin until it can\n"+
+			"fill the structured response, which is then passed to the inspector agent.")
+	flags := registerInterviewFlags(fs)
+	resume := fs.String("resume", "", "continue the session with this id; its schema, mode and models are used unless set by flags")
+	list := fs.Bool("list", false, "list stored sessions and exit")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if (*list || *resume != "") && *flags.sessionsDir == "" {
+		return newUsageError("-list and -resume need -sessions-dir")
+	}
+	if *list {
+		return printSessions(os.Stdout, NewSessionStore(*flags.sessionsDir))
+	}
+
+	var session *Session
+	if *resume != "" {
+		var err error
+		if session, err = NewSessionStore(*flags.sessionsDir).Open(*resume); err != nil {
+			return err
+		}
+		defer session.Close()
+
+		set := map[string]bool{}
+		fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+		if !set["schema"] {
+			*flags.schemaName = session.Meta.Schema
+		}
+		if !set["mode"] {
+			*flags.mode = session.Meta.Mode
+		}
+		if !set["model"] {
+			flags.interviewerCfg.Model = session.Meta.Model
+		}
+		if !set["inspector-model"] && session.Meta.InspectorModel != "" {
+			flags.inspectorCfg.Model = session.Meta.InspectorModel
+		}
+	}
+
+	setup, err := flags.setup()
+	if err != nil {
+		return err
+	}
+	defer setup.Close()
+
+	if session == nil {
+		if session, err = setup.createSession(""); err != nil {
+			return err
+		}
+		if session != nil {
+			defer session.Close()
+		}
+	}
+	opts := setup.opts
+	if session != nil {
+		opts = append(opts, WithSession(session))
+	}
+
+	// Ctrl-C ends the dialog through ctx, so the deferred closes still print the usage report
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if setup.inspector == nil {
+		err = Run1Agent1User(ctx, setup.model, setup.schema, opts...)
+	} else {
+		err = Run2Agents1User(ctx, setup.model, setup.inspector, setup.schema, opts...)
+	}
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return err
+}
+
+// registerSessionManagerFlags binds -max-sessions and -idle-timeout of the frontends that serve many users.
+func registerSessionManagerFlags(fs *flag.FlagSet) (*int, *time.Duration) {
+	maxSessions := fs.Int("max-sessions", defaultMaxSessions, "how many sessions may be open at once, idle ones are evicted first")
+	idleTimeout := fs.Duration("idle-timeout", defaultIdleTimeout, "close sessions unused for this long")
+	return maxSessions, idleTimeout
+}
+
+// serveShutdownTimeout is how long serve waits for running requests after Ctrl-C.
+const serveShutdownTimeout = 5 * time.Second
+
+func cmdServe(args []string) error {
+	fs := newFlagSet("serve", "[flags]",
+		"Serves interviews over HTTP: POST /sessions creates one, POST /sessions/{id}/messages sends\n"+
+			"a user message and streams the reply as Server-Sent Events, GET /sessions/{id}/response\n"+
+			"returns the last accepted response. Interview flags work as in 'advent interview'.")
+	addr := fs.String("addr", ":8080", "listen address")
+	allowOrigin := fs.String("allow-origin", "", "Access-Control-Allow-Origin for browser frontends, empty disables CORS")
+	maxSessions, idleTimeout := registerSessionManagerFlags(fs)
+	flags := registerInterviewFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	setup, err := flags.setup()
+	if err != nil {
+		return err
+	}
+	defer setup.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	manager := NewSessionManager(setup.openInterviewer, *maxSessions, *idleTimeout)
+	defer manager.Shutdown()
+	server := &http.Server{
+		Addr:    *addr,
+		Handler: NewInterviewServer(ctx, manager, *allowOrigin).Handler(),
+		// requests end with ctx, so open streams don't hold up the shutdown
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), serveShutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			server.Close()
+		}
+	}()
+	fmt.Printf("serving interviews on %s\n", *addr)
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func cmdTelegram(args []string) error {
+	fs := newFlagSet("telegram", "[flags]",
+		"Runs interviews in Telegram over long polling. Every chat gets its own interviewer, replies\n"+
+			"become Z_PROVIDE_DATA and the finalized response is sent back as a message; /reset starts\n"+
+			"over. Interview flags work as in 'advent interview'.")
+	token := fs.String("token", os.Getenv("TELEGRAM_BOT_TOKEN"), "bot token from BotFather (defaults to TELEGRAM_BOT_TOKEN)")
+	allowChats := fs.String("allow-chats", "", "comma-separated chat ids the bot answers, empty answers every chat")
+	maxSessions, idleTimeout := registerSessionManagerFlags(fs)
+	flags := registerInterviewFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *token == "" {
+		return newUsageError("-token or TELEGRAM_BOT_TOKEN is required")
+	}
+	var allowed []int64
+	for _, field := range strings.Split(*allowChats, ",") {
+		if field = strings.TrimSpace(field); field == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(field, 10, 64)
+		if err != nil {
+			return newUsageError("invalid -allow-chats id %q", field)
+		}
+		allowed = append(allowed, id)
+	}
+
+	setup, err := flags.setup()
+	if err != nil {
+		return err
+	}
+	defer setup.Close()
+
+	bot, err := tgbotapi.NewBotAPI(*token)
+	if err != nil {
+		return fmt.Errorf("telegram: %w", err)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	manager := NewSessionManager(setup.openInterviewer, *maxSessions, *idleTimeout)
+	defer manager.Shutdown()
+	err = NewTelegramInterviewBot(bot, manager, allowed).Run(ctx)
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return err
+}
+
+func printSessions(w io.Writer, store *SessionStore) error {
+	summaries, err := store.List()
+	if err != nil {
+		return err
+	}
+	if len(summaries) == 0 {
+		fmt.Fprintln(w, "no sessions")
+		return nil
+	}
+	for _, summary := range summaries {
+		topic := strings.Join(strings.Fields(summary.FirstInput), " ")
+		if runes := []rune(topic); len(runes) > 60 {
+			topic = string(runes[:60]) + "..."
+		}
+		fmt.Fprintf(w, "%-22s %s  %-8s %-7s turns=%-3d responses=%-2d %s  %s\n",
+			summary.Meta.ID, summary.UpdatedAt.Format("2006-01-02 15:04"), summary.Meta.Schema, summary.Meta.Mode,
+			summary.Turns, summary.Responses, summary.Meta.Model, topic)
+	}
+	return nil
+}
+
+func cmdPrompts(args []string) error {
+	fs := newFlagSet("prompts", "list|render [flags]",
+		"list shows the prompt templates and where each is loaded from. render prints the final\n"+
+			"text of -name, or of every prompt, as the model receives it. Inputs only known at run time\n"+
+			"are shown as {placeholders}.")
+	promptsDir := registerPromptsFlag(fs)
+	name := fs.String("name", "", "prompt to render, empty renders every prompt")
+	schemaName := fs.String("schema", defaultResponseSchemaName, "response schema rendered into the interviewer prompts: a built-in name or a JSON Schema file")
+	mode := fs.String("mode", InterviewerModeMarkers, "interviewer mode: markers, tools or json")
+	focus := fs.String("focus", "", "inspector focus, as set per quorum member")
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		// -h prints the usage, anything else lacks the action
+		if err := parseFlags(fs, args); err != nil {
+			return err
+		}
+		return newUsageError("expected list or render")
+	}
+	action := args[0]
+	if err := parseFlags(fs, args[1:]); err != nil {
+		return err
+	}
+	switch *mode {
+	case InterviewerModeMarkers, InterviewerModeTools, InterviewerModeJSON:
+	default:
+		return newUsageError("unknown -mode %q", *mode)
+	}
+
+	prompts, err := loadPrompts(*promptsDir)
+	if err != nil {
+		return err
+	}
+	switch action {
+	case "list":
+		for _, promptName := range promptNames {
+			fmt.Printf("%-22s %s\n", promptName, prompts.Source(promptName))
+		}
+		return nil
+	case "render":
+	default:
+		return newUsageError("unknown action %q, expected list or render", action)
+	}
+
+	schema, err := ResolveResponseSchema(*schemaName)
+	if err != nil {
+		return newUsageError("%v", err)
+	}
+	names := promptNames
+	if *name != "" {
+		names = []string{*name}
+	}
+	for i, promptName := range names {
+		data, err := examplePromptData(promptName, schema, *mode)
+		if err != nil {
+			return newUsageError("%v", err)
+		}
+		if inspectorData, ok := data.(InspectorPromptData); ok {
+			inspectorData.Focus = *focus
+			data = inspectorData
+		}
+		text, err := prompts.Render(promptName, data)
+		if err != nil {
+			return err
+		}
+		if len(names) > 1 {
+			if i > 0 {
+				fmt.Println()
+			}
+			fmt.Printf("=== %s (%s)\n", promptName, prompts.Source(promptName))
+		}
+		fmt.Println(text)
+	}
+	return nil
+}
+
+func cmdUsage(args []string) error {
+	fs := newFlagSet("usage", "[flags]",
+		"Prints token usage and cost totals of the completions in the usage log, grouped by -by.\n"+
+			"Costs of models without a price are counted as 0 and flagged as unpriced.")
+	path := fs.String("usage-log", UsageLogFromEnv(), "usage log to read (defaults to ADVENT_USAGE_LOG or "+defaultUsageLog+")")
+	by := fs.String("by", "day,agent,model", "comma-separated columns to group by: agent, model, day, session")
+	since := fs.String("since", "", "only completions on or after this day, YYYY-MM-DD, or a duration like 24h")
+	session := fs.String("session", "", "only completions of this session")
+	agent := fs.String("agent", "", "only completions of this agent: interviewer, inspector, digest or testgen")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	var columns []string
+	for _, column := range strings.Split(*by, ",") {
+		column = strings.TrimSpace(column)
+		if _, ok := usageGroupKeys[column]; !ok {
+			return newUsageError("unknown -by column %q, expected agent, model, day or session", column)
+		}
+		columns = append(columns, column)
+	}
+	var from time.Time
+	if *since != "" {
+		if day, err := time.ParseInLocation(time.DateOnly, *since, time.Local); err == nil {
+			from = day
+		} else if d, err := time.ParseDuration(*since); err == nil {
+			from = time.Now().Add(-d)
+		} else {
+			return newUsageError("invalid -since %q, expected YYYY-MM-DD or a duration", *since)
+		}
+	}
+
+	records, err := ReadUsageLog(*path)
+	if errors.Is(err, os.ErrNotExist) {
+		fmt.Printf("no usage recorded in %s yet\n", *path)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read usage log: %w", err)
+	}
+	records = slices.DeleteFunc(records, func(record UsageRecord) bool {
+		return record.Time.Before(from) ||
+			(*session != "" && record.Session != *session) ||
+			(*agent != "" && record.Agent != *agent)
+	})
+	if len(records) == 0 {
+		fmt.Println("no matching usage")
+		return nil
+	}
+	PrintUsageReport(os.Stdout, records, columns)
+	return nil
+}
+
+func cmdDigest(args []string) error {
+	fs := newFlagSet("digest", "[flags]",
+		"Fetches GitHub notifications through the GitHub MCP server, summarizes them with the LLM\n"+
+			"and sends the summary to Telegram. With -schedule it repeats daily at the -at time.")
+	schedule := fs.Bool("schedule", false, "run daily at the -at time instead of once")
+	at := fs.String("at", fmt.Sprintf("%02d:%02d:%02d", timerHour, timerMinute, timerSecond), "daily run time HH:MM:SS for -schedule (defaults to Z_HOURS, Z_MINUTES, Z_SECONDS)")
+	promptsDir := registerPromptsFlag(fs)
+	llmCfg := ChatModelConfigFromEnv(usageAgentDigest, defaultDigestModel)
+	registerChatModelFlags(fs, "", "summarizer", &llmCfg)
+	registerCassetteFlags(fs, &llmCfg)
+	registerCacheFlags(fs, &llmCfg)
+	usage := registerUsageFlags(fs, &llmCfg)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	prompts, err := loadPrompts(*promptsDir)
+	if err != nil {
+		return err
+	}
+	model, err := NewChatModel(llmCfg)
+	if err != nil {
+		return err
+	}
+	defer usage.Close(os.Stdout)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if !*schedule {
+		return RunMCPGithubAndLlmAndTelegram(ctx, model, prompts)
+	}
+
+	runAt, err := time.Parse(time.TimeOnly, *at)
+	if err != nil {
+		return newUsageError("invalid -at %q, expected HH:MM:SS", *at)
+	}
+	timerHour, timerMinute, timerSecond = int64(runAt.Hour()), int64(runAt.Minute()), int64(runAt.Second())
+	err = RunMCPGithubAndLlmAndTelegramScheduled(ctx, model, prompts)
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return err
+}
+
+func cmdMCP(args []string) error {
+	fs := newFlagSet("mcp", "[flags]",
+		"Lists GitHub notifications through the GitHub MCP server. With -write the result is also\n"+
+			"written to test.txt in -dir through the filesystem MCP server.")
+	write := fs.Bool("write", false, "also write the notifications through the filesystem MCP server")
+	dir := fs.String("dir", "tmp", "host directory mounted into the filesystem MCP server for -write")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if !*write {
+		RunMCPGithub()
+		return nil
+	}
+
+	absDir, err := filepath.Abs(*dir)
+	if err != nil {
+		return fmt.Errorf("resolve -dir: %w", err)
+	}
+	Run2MCP(absDir)
+	return nil
+}
+
+func cmdDockerBuild(args []string) error {
+	fs := newFlagSet("docker-build", "[flags]", "Builds a docker image with a random mcp_docker_build_* tag from the current directory.")
+	dockerfile := fs.String("file", "Dockerfile", "path to the Dockerfile")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	RunDockerBuild(*dockerfile)
+	return nil
+}
+
+func cmdTestgen(args []string) error {
+	fs := newFlagSet("testgen", "[flags]",
+		"Asks the LLM to write pytest tests for -src, saves them to -out and runs them\n"+
+			"in docker with Dockerfile-pytest.")
+	src := fs.String("src", "function_python.py", "python file to write tests for")
+	out := fs.String("out", "tmp/test_python.py", "where to write the generated tests (Dockerfile-pytest copies tmp/*.py)")
+	promptsDir := registerPromptsFlag(fs)
+	llmCfg := ChatModelConfigFromEnv(usageAgentTestgen, defaultTestgenModel)
+	registerChatModelFlags(fs, "", "test writer", &llmCfg)
+	registerCassetteFlags(fs, &llmCfg)
+	registerCacheFlags(fs, &llmCfg)
+	usage := registerUsageFlags(fs, &llmCfg)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	prompts, err := loadPrompts(*promptsDir)
+	if err != nil {
+		return err
+	}
+	model, err := NewChatModel(llmCfg)
+	if err != nil {
+		return err
+	}
+	defer usage.Close(os.Stdout)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	return Run1Agent1UserTest(ctx, model, prompts, *src, *out)
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
+// Strategies of ContextWindow for a dialog that outgrows the context window.
+const (
+	// ContextStrategySummarize replaces older turns with a "facts collected so far" turn written by the model.
+	ContextStrategySummarize = "summarize"
+	// ContextStrategyDrop drops older turns.
+	ContextStrategyDrop = "drop"
+	// ContextStrategyNone sends the whole dialog, the provider fails once it no longer fits.
+	ContextStrategyNone = "none"
+)
+
+// dialogKindSummary is a turn that stands for compacted older turns.
+const dialogKindSummary = "summary"
+
+// ModelContext is what the interviewer knows about the context of a model.
+type ModelContext struct {
+	// Tokens is the context window.
+	Tokens int
+	// CharsPerToken estimates the tokens of a text, it errs on the low side.
+	CharsPerToken float64
+}
+
+// defaultModelContext is used for models missing from knownModelContexts.
+var defaultModelContext = ModelContext{Tokens: 32768, CharsPerToken: 3.5}
+
+// knownModelContexts maps model name prefixes to their context, the first matching prefix wins.
+var knownModelContexts = []struct {
+	prefix string
+	ModelContext
+}{
+	{"deepseek/deepseek-chat-v3", ModelContext{Tokens: 163840, CharsPerToken: 3.5}},
+	{"deepseek/", ModelContext{Tokens: 65536, CharsPerToken: 3.5}},
+	{"moonshotai/kimi-k2", ModelContext{Tokens: 131072, CharsPerToken: 3.5}},
+	{"qwen/qwen3-coder", ModelContext{Tokens: 262144, CharsPerToken: 3.5}},
+	{"qwen/", ModelContext{Tokens: 32768, CharsPerToken: 3.5}},
+	{"openai/gpt-4o", ModelContext{Tokens: 128000, CharsPerToken: 4}},
+	{"meta-llama/llama-3", ModelContext{Tokens: 131072, CharsPerToken: 4}},
+	{"mistralai/", ModelContext{Tokens: 32768, CharsPerToken: 3.5}},
+}
+
+// ModelContextFor returns the context of models: the smallest window and the lowest
+// chars per token among them, so the estimate holds whichever model of a fallback chain answers.
+func ModelContextFor(models ...string) ModelContext {
+	if len(models) == 0 {
+		return defaultModelContext
+	}
+	result := ModelContext{Tokens: math.MaxInt, CharsPerToken: math.MaxFloat64}
+	for _, model := range models {
+		modelContext := defaultModelContext
+		for _, known := range knownModelContexts {
+			if strings.HasPrefix(model, known.prefix) {
+				modelContext = known.ModelContext
+				break
+			}
+		}
+		result.Tokens = min(result.Tokens, modelContext.Tokens)
+		result.CharsPerToken = min(result.CharsPerToken, modelContext.CharsPerToken)
+	}
+	return result
+}
+
+// EstimateTokens estimates the tokens of text.
+func (c ModelContext) EstimateTokens(text string) int {
+	return int(math.Ceil(float64(utf8.RuneCountInString(text)) / c.CharsPerToken))
+}
+
+// messageOverheadTokens is what a message costs besides its content (role, separators).
+const messageOverheadTokens = 4
+
+func (c ModelContext) estimateTurn(turn dialogTurn) int {
+	tokens := messageOverheadTokens + c.EstimateTokens(turn.Message.Content)
+	for _, call := range turn.Message.ToolCalls {
+		tokens += c.EstimateTokens(call.Name) + c.EstimateTokens(call.Arguments)
+	}
+	return tokens
+}
+
+func (c ModelContext) estimateTurns(turns []dialogTurn) int {
+	tokens := 0
+	for _, turn := range turns {
+		tokens += c.estimateTurn(turn)
+	}
+	return tokens
+}
+
+// EstimateRequest estimates the prompt tokens of req, tool and response format schemas included.
+func (c ModelContext) EstimateRequest(req ChatRequest) int {
+	tokens := 0
+	for _, msg := range req.Messages {
+		tokens += messageOverheadTokens + c.EstimateTokens(msg.Content)
+		for _, call := range msg.ToolCalls {
+			tokens += c.EstimateTokens(call.Name) + c.EstimateTokens(call.Arguments)
+		}
+	}
+	for _, tool := range req.Tools {
+		tokens += c.EstimateTokens(tool.Name) + c.EstimateTokens(tool.Description) + c.EstimateTokens(string(tool.Parameters))
+	}
+	if req.ResponseFormat != nil {
+		tokens += c.EstimateTokens(string(req.ResponseFormat.Schema))
+	}
+	return tokens
+}
+
+// ContextWindow is how the interviewer keeps long dialogs within the context of its model.
+// The system prompt with the protocol and the schema and the first Z_PROVIDE_DATA, which sets
+// the topic, are always kept, as is the latest exchange.
+type ContextWindow struct {
+	Strategy string
+	ModelContext
+	// Threshold is the share of the window the prompt may fill, the rest is left for the answer.
+	// Past it older turns are compacted until the prompt is down to half of that.
+	Threshold float64
+}
+
+var defaultContextWindow = ContextWindow{Strategy: ContextStrategySummarize, ModelContext: defaultModelContext, Threshold: 0.75}
+
+// dialogCompaction replaces the dialog turns [From, From+Count) with Summary, or drops them
+// when Summary is nil. It is logged to the session, so a resumed dialog is compacted the same way.
+type dialogCompaction struct {
+	From    int         `json:"from"`
+	Count   int         `json:"count"`
+	Summary *dialogTurn `json:"summary,omitempty"`
+	// TokensBefore and TokensAfter are the estimated prompt tokens.
+	TokensBefore int `json:"tokensBefore"`
+	TokensAfter  int `json:"tokensAfter"`
+}
+
+func (c dialogCompaction) apply(dialog []dialogTurn) []dialogTurn {
+	if c.From < 0 || c.Count <= 0 || c.From+c.Count > len(dialog) {
+		return dialog
+	}
+	compacted := append([]dialogTurn(nil), dialog[:c.From]...)
+	if c.Summary != nil {
+		compacted = append(compacted, *c.Summary)
+	}
+	return append(compacted, dialog[c.From+c.Count:]...)
+}
+
+// fitContext compacts the dialog when req, with the dialog as its messages, would fill more than
+// the threshold of the context window.
+func (agent *AgentInterviewer) fitContext(ctx context.Context, req ChatRequest) {
+	window := agent.window
+	if window.Strategy == ContextStrategyNone || window.Tokens <= 0 {
+		return
+	}
+	limit := int(float64(window.Tokens) * window.Threshold)
+	req.Messages = agent.messages()
+	before := window.EstimateRequest(req)
+	if before <= limit {
+		return
+	}
+
+	// exchanges start with an assistant turn and are compacted whole, so tool calls keep their results.
+	// The latest exchange holds the turns of the running Step, they stay for a rollback of the Step.
+	stepStart := len(agent.dialog) - agent.stepTurns
+	var starts []int
+	for i := 1; i <= stepStart && i < len(agent.dialog); i++ {
+		if agent.dialog[i].Message.Role == ChatRoleAssistant {
+			starts = append(starts, i)
+		}
+	}
+	if len(starts) < 2 {
+		fmt.Printf("context: prompt ~%d tokens exceeds %d, nothing left to compact\n", before, limit)
+		return
+	}
+
+	// keep the latest exchange and as many before it as fit in half the limit,
+	// the oldest exchange is always compacted
+	fixed := before
+	for _, turn := range agent.dialog[1:] {
+		fixed -= window.estimateTurn(turn)
+	}
+	cut := starts[len(starts)-1]
+	kept := window.estimateTurns(agent.dialog[cut:])
+	for j := len(starts) - 2; j >= 1; j-- {
+		exchange := window.estimateTurns(agent.dialog[starts[j]:starts[j+1]])
+		if fixed+kept+exchange > limit/2 {
+			break
+		}
+		kept += exchange
+		cut = starts[j]
+	}
+
+	compaction := dialogCompaction{From: 1, Count: cut - 1, TokensBefore: before}
+	if window.Strategy == ContextStrategySummarize {
+		summary, err := agent.summarizeTurns(ctx, agent.dialog[1:cut])
+		if err != nil {
+			fmt.Printf("context: summarize: %v, dropping the turns instead\n", err)
+		} else {
+			compaction.Summary = &dialogTurn{Kind: dialogKindSummary, Message: ChatMessage{Role: ChatRoleUser, Content: summary}}
+		}
+	}
+	agent.dialog = compaction.apply(agent.dialog)
+	req.Messages = agent.messages()
+	compaction.TokensAfter = window.EstimateRequest(req)
+
+	fmt.Printf("context: compacted %d turns, prompt ~%d -> ~%d tokens of %d\n", compaction.Count, before, compaction.TokensAfter, window.Tokens)
+	if agent.session != nil {
+		if err := agent.session.Compact(compaction); err != nil {
+			fmt.Printf("session: %v\n", err)
+		}
+	}
+}
+
+// summarizeTurns asks the model for the facts Z_USER provided in turns.
+func (agent *AgentInterviewer) summarizeTurns(ctx context.Context, turns []dialogTurn) (string, error) {
+	var transcript strings.Builder
+	for _, turn := range turns {
+		speaker := "Z_USER"
+		if turn.Message.Role == ChatRoleAssistant {
+			speaker = "Z_AI"
+		}
+		if turn.Kind == dialogKindSummary {
+			speaker = "EARLIER SUMMARY"
+		}
+		fmt.Fprintf(&transcript, "[%s] %s\n", speaker, turn.Message.Content)
+	}
+
+	prompt, err := agent.prompts.Render(PromptContextSummary, ContextSummaryPromptData{Transcript: strings.TrimSpace(transcript.String())})
+	if err != nil {
+		return "", err
+	}
+	resp, err := agent.model.Chat(ctx, ChatRequest{Messages: []ChatMessage{{Role: ChatRoleUser, Content: prompt}}})
+	if err != nil {
+		return "", err
+	}
+	summary := strings.TrimSpace(StripReasoning(resp.Text))
+	if summary == "" {
+		return "", fmt.Errorf("model %s returned an empty summary", resp.Model)
+	}
+	return "Facts collected so far in Z_DIALOG (earlier turns, summarized):\n" + summary, nil
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func markersQuestion(text string) ChatResponse {
+	markers := defaultPromptMarkers
+	return ChatResponse{Model: "m", Text: fmt.Sprintf("%s\n%s\n%s", markers.CollectDataStart, text, markers.CollectDataEnd)}
+}
+
+func dialogContents(dialog []dialogTurn) []string {
+	contents := make([]string, len(dialog))
+	for i, turn := range dialog {
+		contents[i] = turn.Kind + ":" + turn.Message.Content
+	}
+	return contents
+}
+
+func TestCompactionDuringAFailedStepKeepsItsTurnsForTheRollback(t *testing.T) {
+	markers := defaultPromptMarkers
+	invalid := ChatResponse{Model: "m", Text: fmt.Sprintf("%s\n{\"items\":\"%s\"}\n%s", markers.RspStart, strings.Repeat("x", 1000), markers.RspEnd)}
+	model := &scriptedChatModel{responses: []ChatResponse{
+		markersQuestion("Which car?"),
+		markersQuestion("What is its top speed?"),
+		markersQuestion("In which units?"),
+		// the last Step fails: two invalid answers that fill the window, then the model is gone
+		invalid, invalid,
+	}}
+
+	store := NewSessionStore(t.TempDir())
+	session, err := store.Create(SessionMeta{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	window := ContextWindow{Strategy: ContextStrategyDrop, ModelContext: ModelContext{CharsPerToken: 1}, Threshold: 1}
+	agent := NewAgentInterviewer(model, &verdictInspector{}, MustDefaultResponseSchema(),
+		WithSession(session), WithContextWindow(window), WithRepairs(2, ""))
+	ctx := context.Background()
+	for _, input := range []string{"I have a car", "Audi TT", "250"} {
+		if err := agent.Step(ctx, input, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	before := dialogContents(agent.dialog)
+
+	// the dialog fits with room for one invalid answer, the second one forces a compaction
+	agent.window.Tokens = window.EstimateRequest(ChatRequest{Messages: agent.messages()}) + 1500
+	if err := agent.Step(ctx, "km/h", nil); err == nil {
+		t.Fatal("Step succeeded, want the model failure")
+	}
+
+	after := dialogContents(agent.dialog)
+	if len(after) == 0 || len(after) >= len(before) {
+		t.Fatalf("after the rollback the dialog is %q, want a compacted %q", after, before)
+	}
+	if after[0] != before[0] || after[len(after)-1] != before[len(before)-1] {
+		t.Errorf("after the rollback the dialog is %q, want the start and the end of %q", after, before)
+	}
+	for _, content := range after {
+		if strings.Contains(content, "km/h") || strings.Contains(content, "xxx") {
+			t.Errorf("turn %q of the failed Step survived the rollback", content)
+		}
+	}
+
+	agent.Close()
+	resumed, err := store.Open(session.Meta.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resumed.Close()
+	if got := dialogContents(resumed.dialog); strings.Join(got, "\n") != strings.Join(after, "\n") {
+		t.Errorf("the session log replays to %q, want %q", got, after)
+	}
+}
+
+func TestModelContextFor(t *testing.T) {
+	tests := []struct {
+		models []string
+		want   ModelContext
+	}{
+		{models: nil, want: defaultModelContext},
+		{models: []string{"deepseek/deepseek-chat-v3-0324:free"}, want: ModelContext{Tokens: 163840, CharsPerToken: 3.5}},
+		{models: []string{"deepseek/deepseek-r1"}, want: ModelContext{Tokens: 65536, CharsPerToken: 3.5}},
+		{models: []string{"moonshotai/kimi-k2:free", "openai/gpt-4o-mini"}, want: ModelContext{Tokens: 128000, CharsPerToken: 3.5}},
+		{models: []string{"qwen/qwen3-coder:free", "local-model"}, want: defaultModelContext},
+	}
+	for _, tt := range tests {
+		if got := ModelContextFor(tt.models...); got != tt.want {
+			t.Errorf("ModelContextFor(%q) = %+v, want %+v", tt.models, got, tt.want)
+		}
+	}
+}
+
+func TestFitContext(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy string
+		summary  []ChatResponse
+		grow     int
+		want     []string
+	}{
+		{
+			name:     "fits",
+			strategy: ContextStrategyDrop,
+			grow:     1,
+			want:     []string{"provide_data:I have a car", "collect_data:Which car?", "provide_data:Audi TT", "collect_data:Top speed?", "provide_data:250", "collect_data:Units?", "provide_data:km/h"},
+		},
+		{
+			name:     "drop",
+			strategy: ContextStrategyDrop,
+			want:     []string{"provide_data:I have a car", "collect_data:Units?", "provide_data:km/h"},
+		},
+		{
+			name:     "summarize",
+			strategy: ContextStrategySummarize,
+			summary:  []ChatResponse{{Model: "m", Text: "<think>hm</think> Audi TT, 250"}},
+			want:     []string{"provide_data:I have a car", "summary:Facts collected so far in Z_DIALOG (earlier turns, summarized):\nAudi TT, 250", "collect_data:Units?", "provide_data:km/h"},
+		},
+		{
+			name:     "summarize fails",
+			strategy: ContextStrategySummarize,
+			want:     []string{"provide_data:I have a car", "collect_data:Units?", "provide_data:km/h"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			model := &scriptedChatModel{responses: tt.summary}
+			agent := NewAgentInterviewer(model, nil, MustDefaultResponseSchema(), WithoutInspector())
+			agent.addUserInput("I have a car")
+			for _, exchange := range [][2]string{{"Which car?", "Audi TT"}, {"Top speed?", "250"}, {"Units?", "km/h"}} {
+				agent.addQuestion(interviewerTurn{kind: turnCollectData, text: exchange[0]})
+				agent.addUserInput(exchange[1])
+			}
+			// a new Step has just added the last user input
+			agent.stepTurns = 1
+
+			window := ContextWindow{Strategy: tt.strategy, ModelContext: ModelContext{CharsPerToken: 1}, Threshold: 1}
+			window.Tokens = window.EstimateRequest(ChatRequest{Messages: agent.messages()}) - 1 + tt.grow
+			agent.window = window
+			agent.fitContext(context.Background(), ChatRequest{})
+
+			if got := dialogContents(agent.dialog); strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("dialog %q, want %q", got, tt.want)
+			}
+			if tt.strategy == ContextStrategySummarize && !strings.Contains(model.requests[0].Messages[0].Content, "[Z_USER] Audi TT") {
+				t.Errorf("summary request %q, want the transcript of the compacted turns", model.requests[0].Messages[0].Content)
+			}
+		})
+	}
+}
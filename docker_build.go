@@ -8,7 +8,7 @@ import (
 	"strconv"
 )
 
-func RunDockerBuild() {
+func RunDockerBuild(dockerfile string) {
 	cmd := exec.Command(
 		"docker",
 		"build",
@@ -18,7 +18,7 @@ func RunDockerBuild() {
 		"mcp_docker_build_"+strconv.FormatUint(rand.Uint64(), 10),
 		".",
 		"--file",
-		"Dockerfile",
+		dockerfile,
 	)
 
 	cmd.Stdout = os.Stdout
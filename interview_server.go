@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// InterviewServer serves interviewer sessions over HTTP:
+//
+//	POST   /sessions                 create a session
+//	GET    /sessions/{id}            session state and the last response
+//	POST   /sessions/{id}/messages   send {"text": ...}, the reply is streamed as Server-Sent Events
+//	                                 when the request accepts text/event-stream, JSON otherwise
+//	GET    /sessions/{id}/response   the last accepted response
+//	DELETE /sessions/{id}            close the session
+//
+// Session ids are the only credential, so there is no route that lists them.
+type InterviewServer struct {
+	// ctx bounds the steps, which outlive the request that started them.
+	ctx         context.Context
+	sessions    *SessionManager
+	allowOrigin string
+}
+
+// NewInterviewServer serves the sessions of manager, a step runs until it is done or ctx is cancelled.
+// allowOrigin, when not empty, is sent as Access-Control-Allow-Origin for browser frontends on another origin.
+func NewInterviewServer(ctx context.Context, manager *SessionManager, allowOrigin string) *InterviewServer {
+	return &InterviewServer{ctx: ctx, sessions: manager, allowOrigin: allowOrigin}
+}
+
+func (server *InterviewServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /sessions", server.createSession)
+	mux.HandleFunc("GET /sessions/{id}", server.getSession)
+	mux.HandleFunc("POST /sessions/{id}/messages", server.postMessage)
+	mux.HandleFunc("GET /sessions/{id}/response", server.getResponse)
+	mux.HandleFunc("DELETE /sessions/{id}", server.deleteSession)
+	return server.cors(mux)
+}
+
+func (server *InterviewServer) cors(next http.Handler) http.Handler {
+	if server.allowOrigin == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", server.allowOrigin)
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Accept")
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (server *InterviewServer) createSession(w http.ResponseWriter, r *http.Request) {
+	session, err := server.sessions.Open("")
+	if err != nil {
+		writeSessionError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, server.sessions.Info(session))
+}
+
+func (server *InterviewServer) getSession(w http.ResponseWriter, r *http.Request) {
+	session, err := server.sessions.Get(r.PathValue("id"))
+	if err != nil {
+		writeSessionError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, server.sessions.Info(session))
+}
+
+func (server *InterviewServer) getResponse(w http.ResponseWriter, r *http.Request) {
+	session, err := server.sessions.Get(r.PathValue("id"))
+	if err != nil {
+		writeSessionError(w, err)
+		return
+	}
+	info := server.sessions.Info(session)
+	if info.Response == nil {
+		writeJSONError(w, http.StatusNotFound, fmt.Errorf("session %s has no response yet", session.ID))
+		return
+	}
+	writeJSON(w, http.StatusOK, info.Response)
+}
+
+func (server *InterviewServer) deleteSession(w http.ResponseWriter, r *http.Request) {
+	if err := server.sessions.Close(r.PathValue("id")); err != nil {
+		writeSessionError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (server *InterviewServer) postMessage(w http.ResponseWriter, r *http.Request) {
+	session, err := server.sessions.Get(r.PathValue("id"))
+	if err != nil {
+		writeSessionError(w, err)
+		return
+	}
+	var msg struct {
+		Text string `json:"text"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&msg); err != nil {
+		writeJSONError(w, http.StatusBadRequest, fmt.Errorf("decode message: %w", err))
+		return
+	}
+	if strings.TrimSpace(msg.Text) == "" {
+		writeJSONError(w, http.StatusBadRequest, fmt.Errorf("message text is empty"))
+		return
+	}
+
+	// the step finishes even if the client goes away, and stops with the server
+	ctx := server.ctx
+	if !strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		var events []InterviewerEvent
+		err := server.sessions.Step(ctx, session, msg.Text, func(event InterviewerEvent) {
+			if event.Type != EventDelta && event.Type != EventDone {
+				events = append(events, event)
+			}
+		})
+		if err != nil {
+			writeSessionError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"events": events})
+		return
+	}
+
+	started := false
+	var flusher http.Flusher
+	send := func(eventType string, data any) {
+		if !started {
+			// the status is sent with the first event, so a busy session still gets a plain 409
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Header().Set("Cache-Control", "no-cache")
+			w.Header().Set("X-Accel-Buffering", "no")
+			w.WriteHeader(http.StatusOK)
+			flusher, _ = w.(http.Flusher)
+			started = true
+		}
+		payload, _ := json.Marshal(data)
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", eventType, payload)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	err = server.sessions.Step(ctx, session, msg.Text, func(event InterviewerEvent) { send(event.Type, event) })
+	switch {
+	case err != nil && !started:
+		writeSessionError(w, err)
+	case err != nil:
+		send("error", map[string]string{"error": err.Error()})
+	default:
+		send("end", server.sessions.Info(session))
+	}
+}
+
+// writeSessionError maps SessionManager errors to HTTP statuses.
+func writeSessionError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrSessionNotFound):
+		writeJSONError(w, http.StatusNotFound, err)
+	case errors.Is(err, ErrSessionBusy):
+		writeJSONError(w, http.StatusConflict, err)
+	case errors.Is(err, ErrTooManySessions):
+		writeJSONError(w, http.StatusServiceUnavailable, err)
+	case errors.Is(err, ErrBudgetExceeded):
+		writeJSONError(w, http.StatusTooManyRequests, err)
+	default:
+		writeJSONError(w, http.StatusBadGateway, err)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeJSONError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
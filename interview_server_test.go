@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestInterviewServer(t *testing.T, ctx context.Context, model ChatModel) *httptest.Server {
+	t.Helper()
+	manager := NewSessionManager(func(id string) (*AgentInterviewer, error) {
+		return NewAgentInterviewer(model, &verdictInspector{}, MustDefaultResponseSchema()), nil
+	}, 10, time.Hour)
+	t.Cleanup(manager.Shutdown)
+	server := httptest.NewServer(NewInterviewServer(ctx, manager, "").Handler())
+	t.Cleanup(server.Close)
+	return server
+}
+
+func createTestSession(t *testing.T, server *httptest.Server) string {
+	t.Helper()
+	resp, err := http.Post(server.URL+"/sessions", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var info SessionInfo
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil || resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create session: %s, %v", resp.Status, err)
+	}
+	return info.ID
+}
+
+func TestInterviewServerMessages(t *testing.T) {
+	server := newTestInterviewServer(t, context.Background(), echoQuestionChatModel{})
+	id := createTestSession(t, server)
+
+	// streamed: Server-Sent Events up to "end"
+	req, _ := http.NewRequest(http.MethodPost, server.URL+"/sessions/"+id+"/messages", strings.NewReader(`{"text":"Audi TT"}`))
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var events []string
+	var question string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if event, ok := strings.CutPrefix(scanner.Text(), "event: "); ok {
+			events = append(events, event)
+		}
+		if data, ok := strings.CutPrefix(scanner.Text(), "data: "); ok && events[len(events)-1] == EventQuestion {
+			var event InterviewerEvent
+			json.Unmarshal([]byte(data), &event)
+			question = event.Text
+		}
+	}
+	resp.Body.Close()
+	if got := resp.Header.Get("Content-Type"); got != "text/event-stream" {
+		t.Errorf("Content-Type = %q", got)
+	}
+	if len(events) == 0 || events[len(events)-1] != "end" || question != "What about Audi TT?" {
+		t.Errorf("events %v with question %q, want a question about Audi TT and end", events, question)
+	}
+
+	// plain: one JSON object
+	resp, err = http.Post(server.URL+"/sessions/"+id+"/messages", "application/json", strings.NewReader(`{"text":"250"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body struct {
+		Events []InterviewerEvent `json:"events"`
+	}
+	json.NewDecoder(resp.Body).Decode(&body)
+	resp.Body.Close()
+	if len(body.Events) != 1 || body.Events[0].Text != "What about 250?" {
+		t.Errorf("events %+v, want the question about 250", body.Events)
+	}
+
+	tests := []struct {
+		method, path, body string
+		want               int
+	}{
+		{method: http.MethodGet, path: "/sessions", want: http.StatusMethodNotAllowed},
+		{method: http.MethodGet, path: "/sessions/" + id, want: http.StatusOK},
+		{method: http.MethodGet, path: "/sessions/" + id + "/response", want: http.StatusNotFound},
+		{method: http.MethodPost, path: "/sessions/" + id + "/messages", body: `{"text":" "}`, want: http.StatusBadRequest},
+		{method: http.MethodPost, path: "/sessions/nope/messages", body: `{"text":"hi"}`, want: http.StatusNotFound},
+		{method: http.MethodDelete, path: "/sessions/" + id, want: http.StatusNoContent},
+		{method: http.MethodGet, path: "/sessions/" + id, want: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(tt.method, server.URL+tt.path, strings.NewReader(tt.body))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.want {
+			t.Errorf("%s %s = %d, want %d", tt.method, tt.path, resp.StatusCode, tt.want)
+		}
+	}
+}
+
+// blockingChatModel answers only when its context ends, with the context error.
+type blockingChatModel struct {
+	started chan struct{}
+	stopped chan error
+}
+
+func (m blockingChatModel) Chat(ctx context.Context, req ChatRequest) (ChatResponse, error) {
+	m.started <- struct{}{}
+	<-ctx.Done()
+	m.stopped <- ctx.Err()
+	return ChatResponse{}, ctx.Err()
+}
+
+func TestInterviewServerStepOutlivesClientButNotServer(t *testing.T) {
+	serverCtx, stopServer := context.WithCancel(context.Background())
+	defer stopServer()
+	model := blockingChatModel{started: make(chan struct{}, 1), stopped: make(chan error, 1)}
+	server := newTestInterviewServer(t, serverCtx, model)
+	id := createTestSession(t, server)
+
+	clientCtx, disconnect := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(clientCtx, http.MethodPost, server.URL+"/sessions/"+id+"/messages", strings.NewReader(`{"text":"hi"}`))
+	go http.DefaultClient.Do(req)
+	<-model.started
+
+	disconnect()
+	select {
+	case err := <-model.stopped:
+		t.Fatalf("the step stopped with the client: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	stopServer()
+	select {
+	case err := <-model.stopped:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("step stopped with %v, want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the step kept running after the server context was cancelled")
+	}
+}
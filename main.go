@@ -1,25 +1,7 @@
 package main
 
-func main() {
-	//Run1Agent1User()
-
-	//if err := Run2Agents1User(); err != nil {
-	//	log.Fatal(err)
-	//}
-
-	//RunMCPGithub()
-
-	//RunMCPGithubAndLlm()
-
-	//SendToTelegram("Hello from Go to my Saved Messages!")
+import "os"
 
-	//RunMCPGithubAndLlmAndTelegram()
-
-	//RunMCPGithubAndLlmAndTelegramScheduled()
-
-	//Run2MCP()
-
-	//RunDockerBuild()
-
-	Run1Agent1UserTest()
+func main() {
+	os.Exit(runCLI(os.Args[1:]))
 }
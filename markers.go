@@ -0,0 +1,288 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type SegmentKind string
+
+const (
+	SegmentText        SegmentKind = "text"
+	SegmentReasoning   SegmentKind = "reasoning"
+	SegmentCollectData SegmentKind = "collect_data"
+	SegmentResponse    SegmentKind = "response"
+)
+
+// MarkerPair maps a start/end marker pair onto the segment kind it delimits.
+type MarkerPair struct {
+	Kind  SegmentKind
+	Start string
+	End   string
+}
+
+// Segment is a typed part of model output.
+type Segment struct {
+	Kind SegmentKind
+	// Text is the content without markers, reasoning blocks and code fences, trimmed.
+	Text string
+	// Start and End are byte offsets of the raw content (markers excluded) in the parsed string.
+	Start int
+	End   int
+	// Unterminated is set when the end marker is missing and the segment runs to the end of the string.
+	Unterminated bool
+}
+
+// MarkerError describes malformed marker usage at a position of the parsed string.
+type MarkerError struct {
+	Marker string
+	Pos    int
+	Reason string
+}
+
+func (e *MarkerError) Error() string {
+	return fmt.Sprintf("marker %s at %d: %s", e.Marker, e.Pos, e.Reason)
+}
+
+// reasoningTags are the blocks models use for chain of thought, they never contain markers that count.
+var reasoningTags = [][2]string{
+	{"<think>", "</think>"},
+	{"<thinking>", "</thinking>"},
+	{"<reasoning>", "</reasoning>"},
+}
+
+// MarkerParser splits model output into collect-data, response, reasoning and free text segments.
+//
+// Parsing rules, applied left to right:
+//   - reasoning blocks are cut out first, markers inside them are ignored; a closing tag
+//     without an opening one makes everything before it reasoning when it is the first tag of
+//     the text and is ignored otherwise; an opening tag without a closing one makes the rest reasoning;
+//   - a start marker opens a segment that is closed by the next end marker of the same pair;
+//   - a start marker repeated while its segment is open restarts the segment, the text between is free text;
+//   - an end marker without an open segment is ignored;
+//   - a start marker without an end marker yields an Unterminated segment and a *MarkerError;
+//   - code fences (```json ... ```) around segment content are stripped.
+type MarkerParser struct {
+	pairs []MarkerPair
+}
+
+func NewMarkerParser(pairs ...MarkerPair) *MarkerParser {
+	return &MarkerParser{pairs: pairs}
+}
+
+type markerHit struct {
+	pos   int
+	pair  int
+	start bool
+}
+
+// Parse returns the segments of text in order. On malformed markers it returns the segments
+// it could recover together with a *MarkerError.
+func (p *MarkerParser) Parse(text string) ([]Segment, error) {
+	var segments []Segment
+	var firstErr error
+
+	cursor := 0
+	for _, block := range reasoningBlocks(text) {
+		segs, err := p.parseRange(text, cursor, block[0])
+		segments = append(segments, segs...)
+		if firstErr == nil {
+			firstErr = err
+		}
+		segments = append(segments, newSegment(SegmentReasoning, text, block[0], block[1], false))
+		cursor = block[1]
+	}
+	segs, err := p.parseRange(text, cursor, len(text))
+	segments = append(segments, segs...)
+	if firstErr == nil {
+		firstErr = err
+	}
+
+	return segments, firstErr
+}
+
+func (p *MarkerParser) parseRange(text string, from, to int) ([]Segment, error) {
+	part := text[from:to]
+	var hits []markerHit
+	for i, pair := range p.pairs {
+		for _, pos := range allIndexes(part, pair.Start) {
+			hits = append(hits, markerHit{pos: from + pos, pair: i, start: true})
+		}
+		for _, pos := range allIndexes(part, pair.End) {
+			hits = append(hits, markerHit{pos: from + pos, pair: i})
+		}
+	}
+	sort.Slice(hits, func(i, j int) bool { return hits[i].pos < hits[j].pos })
+
+	var segments []Segment
+	var err error
+	textStart := from
+	open := -1
+	contentStart := 0
+	openPos := 0
+	for _, hit := range hits {
+		pair := p.pairs[hit.pair]
+		if hit.pos < textStart || (open >= 0 && hit.pos < contentStart) {
+			// overlaps a marker that was already consumed
+			continue
+		}
+		switch {
+		case hit.start:
+			if open >= 0 {
+				segments = appendText(segments, text, contentStart, hit.pos)
+			} else {
+				segments = appendText(segments, text, textStart, hit.pos)
+			}
+			open = hit.pair
+			openPos = hit.pos
+			contentStart = hit.pos + len(pair.Start)
+		case open == hit.pair:
+			segments = append(segments, newSegment(pair.Kind, text, contentStart, hit.pos, false))
+			open = -1
+			textStart = hit.pos + len(pair.End)
+		case open < 0:
+			// stray or repeated end marker
+			segments = appendText(segments, text, textStart, hit.pos)
+			textStart = hit.pos + len(pair.End)
+		}
+	}
+
+	if open >= 0 {
+		pair := p.pairs[open]
+		segments = append(segments, newSegment(pair.Kind, text, contentStart, to, true))
+		err = &MarkerError{Marker: pair.Start, Pos: openPos, Reason: "no " + pair.End + " after it"}
+	} else {
+		segments = appendText(segments, text, textStart, to)
+	}
+	return segments, err
+}
+
+func appendText(segments []Segment, text string, from, to int) []Segment {
+	if from >= to || strings.TrimSpace(text[from:to]) == "" {
+		return segments
+	}
+	return append(segments, newSegment(SegmentText, text, from, to, false))
+}
+
+func newSegment(kind SegmentKind, text string, from, to int, unterminated bool) Segment {
+	content := strings.TrimSpace(text[from:to])
+	if kind != SegmentText && kind != SegmentReasoning {
+		content = stripCodeFence(content)
+	}
+	return Segment{Kind: kind, Text: content, Start: from, End: to, Unterminated: unterminated}
+}
+
+// stripCodeFence removes a ```lang ... ``` wrapper around s.
+func stripCodeFence(s string) string {
+	if !strings.HasPrefix(s, "```") {
+		return s
+	}
+	body := s[3:]
+	if nl := strings.IndexByte(body, '\n'); nl >= 0 {
+		body = body[nl+1:]
+	} else {
+		body = ""
+	}
+	body = strings.TrimSpace(body)
+	body = strings.TrimSuffix(body, "```")
+	return strings.TrimSpace(body)
+}
+
+// reasoningBlocks returns sorted, non-overlapping [start, end) ranges of reasoning blocks, tags included.
+// A block without a closing tag runs to the end of text.
+func reasoningBlocks(text string) [][2]int {
+	var blocks [][2]int
+	for _, tag := range reasoningTags {
+		cursor := 0
+		for cursor < len(text) {
+			openIdx := strings.Index(text[cursor:], tag[0])
+			closeIdx := strings.Index(text[cursor:], tag[1])
+			if closeIdx >= 0 && (openIdx < 0 || openIdx > closeIdx) {
+				// closing tag without opening one: the prompt opened the block for the model,
+				// which only happens at the start of the answer; later ones are stray and skipped
+				if cursor == 0 {
+					blocks = append(blocks, [2]int{0, closeIdx + len(tag[1])})
+				}
+				cursor += closeIdx + len(tag[1])
+				continue
+			}
+			if openIdx < 0 {
+				break
+			}
+			start := cursor + openIdx
+			end := strings.Index(text[start+len(tag[0]):], tag[1])
+			if end < 0 {
+				// the answer was cut off, or the model never closed the block
+				blocks = append(blocks, [2]int{start, len(text)})
+				break
+			}
+			cursor = start + len(tag[0]) + end + len(tag[1])
+			blocks = append(blocks, [2]int{start, cursor})
+		}
+	}
+
+	sort.Slice(blocks, func(i, j int) bool { return blocks[i][0] < blocks[j][0] })
+	merged := blocks[:0]
+	for _, block := range blocks {
+		if n := len(merged); n > 0 && block[0] < merged[n-1][1] {
+			merged[n-1][1] = max(merged[n-1][1], block[1])
+			continue
+		}
+		merged = append(merged, block)
+	}
+	return merged
+}
+
+func allIndexes(s, substr string) []int {
+	if substr == "" {
+		return nil
+	}
+	var idx []int
+	for offset := 0; ; {
+		i := strings.Index(s[offset:], substr)
+		if i < 0 {
+			return idx
+		}
+		idx = append(idx, offset+i)
+		offset += i + len(substr)
+	}
+}
+
+// Segments of kind in order.
+func SegmentsOf(segments []Segment, kind SegmentKind) []Segment {
+	var out []Segment
+	for _, seg := range segments {
+		if seg.Kind == kind {
+			out = append(out, seg)
+		}
+	}
+	return out
+}
+
+// LastSegment returns the last complete segment of kind, or the last unterminated one if there is no complete one.
+func LastSegment(segments []Segment, kind SegmentKind) (Segment, bool) {
+	var found Segment
+	ok := false
+	for _, seg := range segments {
+		if seg.Kind != kind {
+			continue
+		}
+		if !seg.Unterminated || !ok || found.Unterminated {
+			found, ok = seg, true
+		}
+	}
+	return found, ok
+}
+
+// StripReasoning removes reasoning blocks from free-form model output.
+func StripReasoning(text string) string {
+	var b strings.Builder
+	cursor := 0
+	for _, block := range reasoningBlocks(text) {
+		b.WriteString(text[cursor:block[0]])
+		cursor = block[1]
+	}
+	b.WriteString(text[cursor:])
+	return strings.TrimSpace(b.String())
+}
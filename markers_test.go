@@ -0,0 +1,116 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestReasoningBlocks(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want [][2]int
+	}{
+		{name: "no reasoning", text: "plain answer"},
+		{name: "block", text: "<think>hmm</think>answer", want: [][2]int{{0, 18}}},
+		{name: "block in the middle", text: "a <think>hmm</think> b", want: [][2]int{{2, 20}}},
+		{name: "two blocks", text: "<think>a</think>x<think>b</think>", want: [][2]int{{0, 16}, {17, 33}}},
+		{name: "implicit open at the start", text: "hmm</think>answer", want: [][2]int{{0, 11}}},
+		{name: "stray close after a block", text: "<think>a</think> answer </think> rest", want: [][2]int{{0, 16}}},
+		{name: "stray close before a block", text: "x</think>y<think>a</think>", want: [][2]int{{0, 9}, {10, 26}}},
+		{name: "unterminated block", text: "answer <think>still thinking", want: [][2]int{{7, 28}}},
+		{name: "unterminated after a block", text: "<think>a</think> b <think>c", want: [][2]int{{0, 16}, {19, 27}}},
+		{name: "other tags", text: "<thinking>a</thinking> b <reasoning>c</reasoning>", want: [][2]int{{0, 22}, {25, 49}}},
+		{name: "nested tags merge", text: "<thinking>a <think>b</think> c</thinking> d", want: [][2]int{{0, 41}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := reasoningBlocks(tt.text); !reflect.DeepEqual(got, tt.want) && (len(got) != 0 || len(tt.want) != 0) {
+				t.Errorf("reasoningBlocks(%q) = %v, want %v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStripReasoning(t *testing.T) {
+	tests := []struct {
+		text, want string
+	}{
+		{text: "  answer  ", want: "answer"},
+		{text: "<think>hmm</think>\nanswer", want: "answer"},
+		{text: "hmm</think>answer", want: "answer"},
+		{text: "<think>a</think>answer</think> tail", want: "answer</think> tail"},
+		{text: "answer\n<think>cut off mid-thou", want: "answer"},
+	}
+	for _, tt := range tests {
+		if got := StripReasoning(tt.text); got != tt.want {
+			t.Errorf("StripReasoning(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestMarkerParser(t *testing.T) {
+	parser := NewMarkerParser(
+		MarkerPair{Kind: SegmentCollectData, Start: "[Q]", End: "[/Q]"},
+		MarkerPair{Kind: SegmentResponse, Start: "[R]", End: "[/R]"},
+	)
+	type seg struct {
+		kind         SegmentKind
+		text         string
+		unterminated bool
+	}
+	tests := []struct {
+		name string
+		text string
+		want []seg
+		err  bool
+	}{
+		{name: "question", text: "Hi [Q] speed? [/Q]", want: []seg{{SegmentText, "Hi", false}, {SegmentCollectData, "speed?", false}}},
+		{name: "code fence", text: "[R]\n```json\n{\"a\":1}\n```\n[/R]", want: []seg{{SegmentResponse, `{"a":1}`, false}}},
+		{name: "stray end", text: "[/R] done", want: []seg{{SegmentText, "done", false}}},
+		{name: "restarted segment", text: "[R] draft [R] final [/R]", want: []seg{{SegmentText, "draft", false}, {SegmentResponse, "final", false}}},
+		{name: "unterminated", text: "[R] {\"a\":1}", want: []seg{{SegmentResponse, `{"a":1}`, true}}, err: true},
+		{name: "markers in reasoning", text: "<think>[R] no [/R]</think>[Q] why? [/Q]", want: []seg{{SegmentReasoning, "<think>[R] no [/R]</think>", false}, {SegmentCollectData, "why?", false}}},
+		{name: "implicit reasoning", text: "[R] no [/R]</think>[R] yes [/R]", want: []seg{{SegmentReasoning, "[R] no [/R]</think>", false}, {SegmentResponse, "yes", false}}},
+		{name: "stray close keeps the response", text: "<think>a</think>[R] yes [/R]</think>", want: []seg{{SegmentReasoning, "<think>a</think>", false}, {SegmentResponse, "yes", false}, {SegmentText, "</think>", false}}},
+		{name: "unterminated reasoning", text: "[Q] why? [/Q]<think>[R] maybe", want: []seg{{SegmentCollectData, "why?", false}, {SegmentReasoning, "<think>[R] maybe", false}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			segments, err := parser.Parse(tt.text)
+			if (err != nil) != tt.err {
+				t.Fatalf("Parse error = %v, want error %t", err, tt.err)
+			}
+			var markerErr *MarkerError
+			if err != nil && !errors.As(err, &markerErr) {
+				t.Errorf("Parse error %T is not a *MarkerError", err)
+			}
+			var got []seg
+			for _, segment := range segments {
+				got = append(got, seg{segment.Kind, segment.Text, segment.Unterminated})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse(%q) = %+v, want %+v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLastSegment(t *testing.T) {
+	segments := []Segment{
+		{Kind: SegmentResponse, Text: "first"},
+		{Kind: SegmentText, Text: "between"},
+		{Kind: SegmentResponse, Text: "second"},
+		{Kind: SegmentResponse, Text: "cut", Unterminated: true},
+	}
+	if got, ok := LastSegment(segments, SegmentResponse); !ok || got.Text != "second" {
+		t.Errorf("LastSegment = %q, %t, want the last complete segment", got.Text, ok)
+	}
+	if got, ok := LastSegment(segments[3:], SegmentResponse); !ok || got.Text != "cut" {
+		t.Errorf("LastSegment = %q, %t, want the unterminated segment", got.Text, ok)
+	}
+	if _, ok := LastSegment(segments, SegmentCollectData); ok {
+		t.Error("LastSegment found a missing kind")
+	}
+}
@@ -8,12 +8,11 @@ import (
 	"os"
 	"os/exec"
 	"strconv"
-	"strings"
+	"sync"
 	"time"
 
 	mcp "github.com/metoro-io/mcp-golang"
 	"github.com/metoro-io/mcp-golang/transport/stdio"
-	"github.com/revrost/go-openrouter"
 )
 
 var timerHour, _ = strconv.ParseInt(os.Getenv("Z_HOURS"), 10, 64)
@@ -31,43 +30,58 @@ func nextRun() time.Duration {
 	return next.Sub(now)
 }
 
-func RunMCPGithubAndLlmAndTelegramScheduled() {
+// RunMCPGithubAndLlmAndTelegramScheduled runs the digest daily until ctx is done, then waits for
+// the running digests and returns ctx.Err(). A failed digest is logged, the next one runs as planned.
+func RunMCPGithubAndLlmAndTelegramScheduled(ctx context.Context, model ChatModel, prompts *Prompts) error {
+	var jobs sync.WaitGroup
+	defer jobs.Wait()
 	for {
 		wait := nextRun()
 		log.Printf("next run in %v (%s)", wait, time.Now().Add(wait).Format(time.RFC3339))
 
 		// Instead of sleep we use a timer so we can stop it cleanly if needed.
 		t := time.NewTimer(wait)
-		<-t.C
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
 
 		// Run the job in its own goroutine so scheduling stays accurate
 		// even if the job itself is slow.
-		go RunMCPGithubAndLlmAndTelegram()
+		jobs.Add(1)
+		go func() {
+			defer jobs.Done()
+			if err := RunMCPGithubAndLlmAndTelegram(ctx, model, prompts); err != nil {
+				log.Printf("digest: %v", err)
+			}
+		}()
 	}
 }
 
-func RunMCPGithubAndLlmAndTelegram() {
+func RunMCPGithubAndLlmAndTelegram(ctx context.Context, model ChatModel, prompts *Prompts) error {
 	githubToken := os.Getenv("GITHUB_PERSONAL_ACCESS_TOKEN")
 	if githubToken == "" {
-		log.Fatal("export GITHUB_PERSONAL_ACCESS_TOKEN first")
+		return fmt.Errorf("export GITHUB_PERSONAL_ACCESS_TOKEN first")
 	}
 
 	// Launch the MCP GitLab server as a subprocess using Docker
 	// Assume Docker is installed and the image is pulled: docker pull mcp/gitlab
 	// Replace 'your_gitlab_token' with your actual GitLab token
-	cmd := exec.Command("docker", "run", "--rm", "-i", "-e", "GITHUB_PERSONAL_ACCESS_TOKEN="+githubToken, "ghcr.io/github/github-mcp-server")
+	cmd := exec.CommandContext(ctx, "docker", "run", "--rm", "-i", "-e", "GITHUB_PERSONAL_ACCESS_TOKEN="+githubToken, "ghcr.io/github/github-mcp-server")
 
 	stdin, err := cmd.StdinPipe()
 	if err != nil {
-		log.Fatalf("Failed to get stdin pipe: %v", err)
+		return fmt.Errorf("failed to get stdin pipe: %w", err)
 	}
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
-		log.Fatalf("Failed to get stdout pipe: %v", err)
+		return fmt.Errorf("failed to get stdout pipe: %w", err)
 	}
 
 	if err := cmd.Start(); err != nil {
-		log.Fatalf("Failed to start docker container: %v", err)
+		return fmt.Errorf("failed to start docker container: %w", err)
 	}
 	defer func(Process *os.Process) {
 		err := Process.Kill()
@@ -83,7 +97,7 @@ func RunMCPGithubAndLlmAndTelegram() {
 	mcpClient := mcp.NewClient(transport)
 
 	// Initialize the client (may not be required for stdio, but good practice)
-	if _, err := mcpClient.Initialize(context.Background()); err != nil {
+	if _, err := mcpClient.Initialize(ctx); err != nil {
 		log.Printf("Warning: Failed to initialize client: %v", err)
 	}
 
@@ -91,9 +105,9 @@ func RunMCPGithubAndLlmAndTelegram() {
 	str := ""
 	var cursor = &str
 	for {
-		tools, err := mcpClient.ListTools(context.Background(), cursor)
+		tools, err := mcpClient.ListTools(ctx, cursor)
 		if err != nil {
-			log.Fatalf("Failed to list tools: %v", err)
+			return fmt.Errorf("failed to list tools: %w", err)
 		}
 
 		// Process tools...
@@ -106,10 +120,10 @@ func RunMCPGithubAndLlmAndTelegram() {
 
 	toolName := "list_notifications"
 	toolArgs := struct{}{}
-	mcpRsp, err := mcpClient.CallTool(context.Background(), toolName, toolArgs)
+	mcpRsp, err := mcpClient.CallTool(ctx, toolName, toolArgs)
 
 	if err != nil {
-		log.Fatalf("failed to call tool: %v", err)
+		return fmt.Errorf("failed to call tool: %w", err)
 	}
 
 	// Print the response
@@ -122,36 +136,37 @@ func RunMCPGithubAndLlmAndTelegram() {
 		fmt.Println("no tool results")
 	}
 
-	llmToken := os.Getenv("OPENROUTER_API_KEY")
-	if llmToken == "" {
-		log.Fatal("export OPENROUTER_API_KEY first")
+	if model == nil {
+		if model, err = NewChatModel(ChatModelConfigFromEnv(usageAgentDigest, defaultDigestModel)); err != nil {
+			return err
+		}
 	}
-	llmClient := openrouter.NewClient(llmToken)
 
-	llmReqStr := strings.TrimSpace("get summary of my github notifications from below") + "\n" + mspRspStr
+	if prompts == nil {
+		prompts = DefaultPrompts()
+	}
+	llmReqStr := prompts.mustRender(PromptDigest, DigestPromptData{Notifications: mspRspStr})
 
 	llmReqStrEscaped, err := json.Marshal(llmReqStr)
 	if err != nil {
-		log.Fatalf("failed to read user input: %v", err)
+		return fmt.Errorf("failed to read user input: %w", err)
 	}
 
-	resp, err := llmClient.CreateChatCompletion(
-		context.Background(),
-		openrouter.ChatCompletionRequest{
-			//Model: "deepseek/deepseek-chat-v3-0324:free",
-			Model: "qwen/qwen3-coder:free",
-			Messages: []openrouter.ChatCompletionMessage{
-				{Role: openrouter.ChatMessageRoleUser, Content: openrouter.Content{Text: string(llmReqStrEscaped)}},
+	resp, err := model.Chat(
+		ctx,
+		ChatRequest{
+			Messages: []ChatMessage{
+				{Role: ChatRoleUser, Content: string(llmReqStrEscaped)},
 			},
 		},
 	)
 
 	if err != nil {
-		log.Fatalf("llm err: %v", err)
+		return fmt.Errorf("llm err: %w", err)
 	}
 
-	respText := resp.Choices[0].Message.Content.Text
+	respText := StripReasoning(resp.Text)
 	fmt.Printf("llm rsp: %s\n", respText)
 
-	SendToTelegram(respText)
+	return SendToTelegram(respText)
 }
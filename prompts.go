@@ -0,0 +1,239 @@
+package main
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// Names of the prompt templates, each is <name>.tmpl in prompts/ or in a prompts directory.
+const (
+	PromptInterviewerSystem   = "interviewer_system"
+	PromptInterviewerProtocol = "interviewer_protocol"
+	PromptInspectorSystem     = "inspector_system"
+	PromptContextSummary      = "context_summary"
+	PromptDigest              = "digest"
+	PromptTestgenSystem       = "testgen_system"
+	PromptTestgenUser         = "testgen_user"
+)
+
+var promptNames = []string{
+	PromptInterviewerSystem,
+	PromptInterviewerProtocol,
+	PromptInspectorSystem,
+	PromptContextSummary,
+	PromptDigest,
+	PromptTestgenSystem,
+	PromptTestgenUser,
+}
+
+//go:embed prompts/*.tmpl
+var embeddedPrompts embed.FS
+
+// PromptMarkers are the marker words of the dialog protocol.
+type PromptMarkers struct {
+	ProvideDataStart string
+	ProvideDataEnd   string
+	CollectDataStart string
+	CollectDataEnd   string
+	RspStart         string
+	RspEnd           string
+}
+
+var defaultPromptMarkers = PromptMarkers{
+	ProvideDataStart: "Z_PROVIDE_DATA_START",
+	ProvideDataEnd:   "Z_PROVIDE_DATA_END",
+	CollectDataStart: "Z_COLLECT_DATA_START",
+	CollectDataEnd:   "Z_COLLECT_DATA_END",
+	RspStart:         "Z_RSP_START",
+	RspEnd:           "Z_RSP_END",
+}
+
+// InterviewerPromptData is rendered into interviewer_system and interviewer_protocol.
+type InterviewerPromptData struct {
+	// Mode is markers, tools or json.
+	Mode    string
+	Markers PromptMarkers
+	// Format is the syntax of Z_RSP, e.g. JSON.
+	Format string
+	// Template is the example payload of the response schema.
+	Template string
+}
+
+// InspectorPromptData is rendered into inspector_system.
+type InspectorPromptData struct {
+	// Template is the example verdict.
+	Template string
+	// Focus is extra instructions, e.g. of a quorum member, empty when there are none.
+	Focus string
+}
+
+// ContextSummaryPromptData is rendered into context_summary.
+type ContextSummaryPromptData struct {
+	// Transcript is the compacted turns, one "[Z_USER] ..." or "[Z_AI] ..." line each.
+	Transcript string
+}
+
+// DigestPromptData is rendered into digest.
+type DigestPromptData struct {
+	Notifications string
+}
+
+// TestgenPromptData is rendered into testgen_system and testgen_user.
+type TestgenPromptData struct {
+	Markers PromptMarkers
+	Format  string
+	Code    string
+}
+
+// Prompts is a set of parsed prompt templates: the embedded defaults, some of them overridden
+// by files of a prompts directory.
+type Prompts struct {
+	templates map[string]*template.Template
+	// sources tells where each template was loaded from, "embedded" or a file path.
+	sources map[string]string
+}
+
+var defaultPrompts = sync.OnceValue(func() *Prompts {
+	prompts, err := LoadPrompts("")
+	if err != nil {
+		log.Fatal(err)
+	}
+	return prompts
+})
+
+// DefaultPrompts returns the embedded prompts.
+func DefaultPrompts() *Prompts {
+	return defaultPrompts()
+}
+
+// PromptsDirFromEnv returns ADVENT_PROMPTS_DIR, empty uses the embedded prompts only.
+func PromptsDirFromEnv() string {
+	return os.Getenv("ADVENT_PROMPTS_DIR")
+}
+
+// LoadPrompts parses the embedded prompts, a <name>.tmpl file in dir replaces the embedded one.
+// Every template is rendered once with example data, so a typo in an override fails here and
+// not in the middle of a dialog.
+func LoadPrompts(dir string) (*Prompts, error) {
+	prompts := &Prompts{templates: map[string]*template.Template{}, sources: map[string]string{}}
+	for _, name := range promptNames {
+		file := name + ".tmpl"
+		text, err := fs.ReadFile(embeddedPrompts, "prompts/"+file)
+		if err != nil {
+			return nil, fmt.Errorf("embedded prompt %s: %w", name, err)
+		}
+		source := "embedded"
+		if dir != "" {
+			path := filepath.Join(dir, file)
+			override, err := os.ReadFile(path)
+			switch {
+			case err == nil:
+				text, source = override, path
+			case !errors.Is(err, fs.ErrNotExist):
+				return nil, fmt.Errorf("read prompt %s: %w", name, err)
+			}
+		}
+
+		tmpl, err := template.New(name).Option("missingkey=error").Parse(string(text))
+		if err != nil {
+			return nil, fmt.Errorf("parse prompt %s: %w", source, err)
+		}
+		prompts.templates[name] = tmpl
+		prompts.sources[name] = source
+	}
+
+	if dir != "" {
+		if err := checkPromptsDir(dir); err != nil {
+			return nil, err
+		}
+	}
+	for _, name := range promptNames {
+		data, err := examplePromptData(name, MustDefaultResponseSchema(), InterviewerModeMarkers)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := prompts.Render(name, data); err != nil {
+			return nil, fmt.Errorf("%w (%s)", err, prompts.sources[name])
+		}
+	}
+	return prompts, nil
+}
+
+// checkPromptsDir fails on .tmpl files that override nothing, most likely misspelled names.
+func checkPromptsDir(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("read prompts dir: %w", err)
+	}
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".tmpl")
+		if !ok || entry.IsDir() {
+			continue
+		}
+		if !slices.Contains(promptNames, name) {
+			return fmt.Errorf("prompt %s: unknown name %s, expected one of %s",
+				filepath.Join(dir, entry.Name()), name, strings.Join(promptNames, ", "))
+		}
+	}
+	return nil
+}
+
+// Render executes prompt name with data. Leading and trailing whitespace is trimmed, so template
+// files can end with a newline.
+func (prompts *Prompts) Render(name string, data any) (string, error) {
+	tmpl, ok := prompts.templates[name]
+	if !ok {
+		return "", fmt.Errorf("unknown prompt %q, expected one of %s", name, strings.Join(promptNames, ", "))
+	}
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return "", fmt.Errorf("render prompt %s: %w", name, err)
+	}
+	return strings.TrimSpace(sb.String()), nil
+}
+
+// mustRender is Render for prompts that were checked by LoadPrompts.
+func (prompts *Prompts) mustRender(name string, data any) string {
+	text, err := prompts.Render(name, data)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return text
+}
+
+// Source returns "embedded" or the file prompt name was loaded from.
+func (prompts *Prompts) Source(name string) string {
+	return prompts.sources[name]
+}
+
+// examplePromptData returns the data prompt name is rendered with for schema and mode. Inputs only
+// known at run time, like the code of testgen, are {placeholders}.
+func examplePromptData(name string, schema *ResponseSchema, mode string) (any, error) {
+	switch name {
+	case PromptInterviewerSystem, PromptInterviewerProtocol:
+		return InterviewerPromptData{Mode: mode, Markers: defaultPromptMarkers, Format: "JSON", Template: string(schema.Template)}, nil
+	case PromptInspectorSystem:
+		verdictSchema, err := newVerdictSchema()
+		if err != nil {
+			return nil, err
+		}
+		return InspectorPromptData{Template: string(verdictSchema.Template)}, nil
+	case PromptContextSummary:
+		return ContextSummaryPromptData{Transcript: "{transcript}"}, nil
+	case PromptDigest:
+		return DigestPromptData{Notifications: "{notifications}"}, nil
+	case PromptTestgenSystem, PromptTestgenUser:
+		return TestgenPromptData{Markers: defaultPromptMarkers, Format: testgenFormat, Code: "{code}"}, nil
+	default:
+		return nil, fmt.Errorf("unknown prompt %q, expected one of %s", name, strings.Join(promptNames, ", "))
+	}
+}
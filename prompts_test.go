@@ -0,0 +1,68 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writePrompts(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, text := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoadPromptsOverride(t *testing.T) {
+	dir := writePrompts(t, map[string]string{
+		PromptInterviewerSystem + ".tmpl": "You ask about cars, answer in {{.Format}} after {{.Markers.RspStart}}.\n",
+		"README.md":                       "not a prompt",
+	})
+	prompts, err := LoadPrompts(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := prompts.Source(PromptInterviewerSystem); got != filepath.Join(dir, PromptInterviewerSystem+".tmpl") {
+		t.Errorf("source %q, want the override", got)
+	}
+	if got := prompts.Source(PromptInspectorSystem); got != "embedded" {
+		t.Errorf("source of a prompt without override %q, want embedded", got)
+	}
+
+	agent := NewAgentInterviewer(unreachableChatModel{}, nil, MustDefaultResponseSchema(), WithoutInspector(), WithPrompts(prompts))
+	system := agent.messages()[0].Content
+	if !strings.HasPrefix(system, "You ask about cars, answer in JSON after Z_RSP_START.") {
+		t.Errorf("system prompt %q, want the override", system)
+	}
+	if defaultSystem := DefaultPrompts().Source(PromptInterviewerSystem); defaultSystem != "embedded" {
+		t.Errorf("the default prompts changed to %s", defaultSystem)
+	}
+}
+
+func TestLoadPromptsErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{name: "syntax", files: map[string]string{PromptDigest + ".tmpl": "{{.Notifications"}, want: "parse prompt"},
+		{name: "unknown field", files: map[string]string{PromptDigest + ".tmpl": "{{.Notes}}"}, want: "render prompt digest"},
+		{name: "misspelled name", files: map[string]string{"interviewer_sytem.tmpl": "hi"}, want: "unknown name interviewer_sytem"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadPrompts(writePrompts(t, tt.files))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("LoadPrompts = %v, want an error with %q", err, tt.want)
+			}
+		})
+	}
+	if _, err := LoadPrompts(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("LoadPrompts of a missing dir succeeded")
+	}
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	invopop "github.com/invopop/jsonschema"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+// ResponseSchema describes the structured answer (Z_RSP) an interviewer collects:
+// the JSON Schema it must satisfy, the Z_RSP_TEMPLATE shown to the model and
+// the Go value it is unmarshalled into.
+type ResponseSchema struct {
+	Name     string
+	Schema   json.RawMessage
+	Template json.RawMessage
+
+	// newValue returns a pointer to unmarshal into, nil means generic JSON values.
+	newValue  func() any
+	validator *jsonschema.Schema
+}
+
+// StructuredResponse is a finalized answer that passed ResponseSchema validation.
+type StructuredResponse struct {
+	Schema string          `json:"schema"`
+	Raw    json.RawMessage `json:"raw"`
+	// Value is a pointer to the schema Go type, or map[string]any / []any for file schemas.
+	Value any `json:"-"`
+}
+
+// NewResponseSchemaFor reflects the JSON Schema of T. example is used as Z_RSP_TEMPLATE,
+// when it is nil the template is generated from the schema.
+func NewResponseSchemaFor[T any](name string, example *T) (*ResponseSchema, error) {
+	reflector := invopop.Reflector{DoNotReference: true, ExpandedStruct: true}
+	schemaBytes, err := json.Marshal(reflector.Reflect(new(T)))
+	if err != nil {
+		return nil, fmt.Errorf("marshal %s schema: %w", name, err)
+	}
+
+	var template json.RawMessage
+	if example != nil {
+		if template, err = json.Marshal(example); err != nil {
+			return nil, fmt.Errorf("marshal %s example: %w", name, err)
+		}
+	}
+
+	return newResponseSchema(name, schemaBytes, template, func() any { return new(T) })
+}
+
+// LoadResponseSchema reads a JSON Schema file. Its answers are unmarshalled into generic JSON values.
+func LoadResponseSchema(path string) (*ResponseSchema, error) {
+	schemaBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read schema: %w", err)
+	}
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	return newResponseSchema(name, schemaBytes, nil, nil)
+}
+
+func newResponseSchema(name string, schemaBytes, template json.RawMessage, newValue func() any) (*ResponseSchema, error) {
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(schemaBytes))
+	if err != nil {
+		return nil, fmt.Errorf("parse %s schema: %w", name, err)
+	}
+
+	compiler := jsonschema.NewCompiler()
+	url := "mem:///" + name + ".schema.json"
+	if err := compiler.AddResource(url, doc); err != nil {
+		return nil, fmt.Errorf("add %s schema: %w", name, err)
+	}
+	validator, err := compiler.Compile(url)
+	if err != nil {
+		return nil, fmt.Errorf("compile %s schema: %w", name, err)
+	}
+
+	if template == nil {
+		var root map[string]any
+		if err := json.Unmarshal(schemaBytes, &root); err != nil {
+			return nil, fmt.Errorf("parse %s schema: %w", name, err)
+		}
+		if template, err = json.Marshal(exampleFromSchema(root, root, 0)); err != nil {
+			return nil, fmt.Errorf("marshal %s template: %w", name, err)
+		}
+	}
+
+	return &ResponseSchema{
+		Name:      name,
+		Schema:    schemaBytes,
+		Template:  template,
+		newValue:  newValue,
+		validator: validator,
+	}, nil
+}
+
+// Parse validates payload against the schema and unmarshals it.
+func (s *ResponseSchema) Parse(payload []byte) (StructuredResponse, error) {
+	inst, err := jsonschema.UnmarshalJSON(bytes.NewReader(payload))
+	if err != nil {
+		return StructuredResponse{}, fmt.Errorf("parse %s response: %w", s.Name, err)
+	}
+	if err := s.validator.Validate(inst); err != nil {
+		return StructuredResponse{}, fmt.Errorf("validate %s response: %w", s.Name, err)
+	}
+
+	var value any
+	if s.newValue != nil {
+		value = s.newValue()
+		if err := json.Unmarshal(payload, value); err != nil {
+			return StructuredResponse{}, fmt.Errorf("unmarshal %s response: %w", s.Name, err)
+		}
+	} else if err := json.Unmarshal(payload, &value); err != nil {
+		return StructuredResponse{}, fmt.Errorf("unmarshal %s response: %w", s.Name, err)
+	}
+
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return StructuredResponse{}, fmt.Errorf("marshal %s response: %w", s.Name, err)
+	}
+	return StructuredResponse{Schema: s.Name, Raw: raw, Value: value}, nil
+}
+
+// exampleFromSchema builds a placeholder instance of node. It prefers examples, defaults,
+// consts and enums of the schema and follows local "#/$defs/..." references.
+func exampleFromSchema(root, node map[string]any, depth int) any {
+	if depth > 16 {
+		return nil
+	}
+	if ref, ok := node["$ref"].(string); ok {
+		if target := resolveLocalRef(root, ref); target != nil {
+			return exampleFromSchema(root, target, depth+1)
+		}
+	}
+	if examples, ok := node["examples"].([]any); ok && len(examples) > 0 {
+		return examples[0]
+	}
+	for _, key := range []string{"default", "const"} {
+		if v, ok := node[key]; ok {
+			return v
+		}
+	}
+	if enum, ok := node["enum"].([]any); ok && len(enum) > 0 {
+		return enum[0]
+	}
+	for _, key := range []string{"oneOf", "anyOf", "allOf"} {
+		if variants, ok := node[key].([]any); ok && len(variants) > 0 {
+			if variant, ok := variants[0].(map[string]any); ok {
+				return exampleFromSchema(root, variant, depth+1)
+			}
+		}
+	}
+
+	typ := node["type"]
+	if types, ok := typ.([]any); ok && len(types) > 0 {
+		typ = types[0]
+	}
+	switch typ {
+	case "object":
+		props, _ := node["properties"].(map[string]any)
+		names := make([]string, 0, len(props))
+		for propName := range props {
+			names = append(names, propName)
+		}
+		sort.Strings(names)
+		obj := make(map[string]any, len(props))
+		for _, propName := range names {
+			if prop, ok := props[propName].(map[string]any); ok {
+				obj[propName] = exampleFromSchema(root, prop, depth+1)
+			}
+		}
+		return obj
+	case "array":
+		items, _ := node["items"].(map[string]any)
+		if items == nil {
+			return []any{}
+		}
+		return []any{exampleFromSchema(root, items, depth+1)}
+	case "string":
+		if desc, ok := node["description"].(string); ok && desc != "" {
+			return "{" + desc + "}"
+		}
+		return "{string}"
+	case "integer", "number":
+		return 0
+	case "boolean":
+		return false
+	default:
+		return nil
+	}
+}
+
+func resolveLocalRef(root map[string]any, ref string) map[string]any {
+	if !strings.HasPrefix(ref, "#/") {
+		return nil
+	}
+	var node any = root
+	for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		obj, ok := node.(map[string]any)
+		if !ok {
+			return nil
+		}
+		node = obj[strings.ReplaceAll(strings.ReplaceAll(part, "~1", "/"), "~0", "~")]
+	}
+	target, _ := node.(map[string]any)
+	return target
+}
+
+// defaultZRspExample is the Z_RSP_TEMPLATE of the built-in zrsp schema.
+var defaultZRspExample = ZRsp{
+	Items: []ZRspItem{
+		{ItemType: "car", ItemName: "TT-34", Value1Name: "cost", Value1Units: "byn", Value1: "1000000"},
+		{ItemType: "bullet", ItemName: "7.62x39mm", Value1Name: "speed", Value1Units: "km/h", Value1: "360"},
+		{ItemType: "action", ItemName: "deleting folder in Linux", Value1Name: "bash command", Value1Units: "bash code", Value1: "sudo rm -rf {folder_name}"},
+	},
+}
+
+// builtinResponseSchemas maps names accepted by ResolveResponseSchema to Go-typed schemas.
+// Register new use cases here instead of forking the interviewer.
+var builtinResponseSchemas = map[string]func() (*ResponseSchema, error){
+	"zrsp": func() (*ResponseSchema, error) {
+		return NewResponseSchemaFor("zrsp", &defaultZRspExample)
+	},
+}
+
+const defaultResponseSchemaName = "zrsp"
+
+// ResolveResponseSchema returns a built-in schema by name, or loads nameOrPath as a JSON Schema file.
+func ResolveResponseSchema(nameOrPath string) (*ResponseSchema, error) {
+	if nameOrPath == "" {
+		nameOrPath = defaultResponseSchemaName
+	}
+	if build, ok := builtinResponseSchemas[nameOrPath]; ok {
+		return build()
+	}
+	if _, err := os.Stat(nameOrPath); err != nil {
+		names := make([]string, 0, len(builtinResponseSchemas))
+		for name := range builtinResponseSchemas {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("schema %q is neither a built-in (%s) nor a readable file: %w", nameOrPath, strings.Join(names, ", "), err)
+	}
+	return LoadResponseSchema(nameOrPath)
+}
+
+// MustDefaultResponseSchema returns the built-in zrsp schema and exits if it can't be built.
+func MustDefaultResponseSchema() *ResponseSchema {
+	schema, err := ResolveResponseSchema(defaultResponseSchemaName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return schema
+}
@@ -0,0 +1,373 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Types of response sinks.
+const (
+	SinkJSONL    = "jsonl"
+	SinkCSV      = "csv"
+	SinkMarkdown = "markdown"
+	SinkSQLite   = "sqlite"
+)
+
+// SinkRecord is an accepted response with the session it came from.
+type SinkRecord struct {
+	Session    SessionMeta        `json:"session"`
+	AcceptedAt time.Time          `json:"acceptedAt"`
+	Response   StructuredResponse `json:"response"`
+	// Verdict is nil when the response was not inspected.
+	Verdict *Verdict `json:"verdict,omitempty"`
+}
+
+// ResponseSink receives every accepted response. Writes must be durable on return,
+// the interviewer usually ends with Ctrl-C rather than Close.
+type ResponseSink interface {
+	Write(ctx context.Context, record SinkRecord) error
+	Close() error
+}
+
+// SinkConfig is one sink of a SinksConfig. {schema} in Path is replaced with the schema name.
+type SinkConfig struct {
+	Type string `json:"type"`
+	Path string `json:"path"`
+}
+
+// SinksConfig is the -sinks file: sinks by schema name, "*" applies to schemas that are not listed.
+type SinksConfig map[string][]SinkConfig
+
+// LoadSinks opens the sinks configured for schema in the SinksConfig file at path.
+func LoadSinks(path, schema string) ([]ResponseSink, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read sinks: %w", err)
+	}
+	var cfg SinksConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parse sinks %s: %w", path, err)
+	}
+	configs, ok := cfg[schema]
+	if !ok {
+		configs = cfg["*"]
+	}
+
+	var sinks []ResponseSink
+	for _, sinkCfg := range configs {
+		sink, err := NewResponseSink(sinkCfg, schema)
+		if err != nil {
+			CloseSinks(sinks)
+			return nil, fmt.Errorf("sinks %s: %w", path, err)
+		}
+		sinks = append(sinks, sink)
+	}
+	return sinks, nil
+}
+
+func NewResponseSink(cfg SinkConfig, schema string) (ResponseSink, error) {
+	path := strings.ReplaceAll(cfg.Path, "{schema}", schema)
+	if path == "" {
+		return nil, fmt.Errorf("%s sink has no path", cfg.Type)
+	}
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("create sink dir: %w", err)
+		}
+	}
+
+	switch cfg.Type {
+	case SinkJSONL:
+		return &JSONLSink{path: path}, nil
+	case SinkCSV:
+		return &CSVSink{path: path}, nil
+	case SinkMarkdown:
+		return &MarkdownSink{path: path}, nil
+	case SinkSQLite:
+		return OpenSQLiteSink(path)
+	default:
+		return nil, fmt.Errorf("unknown sink type %q, expected %s, %s, %s or %s", cfg.Type, SinkJSONL, SinkCSV, SinkMarkdown, SinkSQLite)
+	}
+}
+
+// WriteSinks sends record to every sink. A failing sink doesn't stop the others.
+func WriteSinks(ctx context.Context, sinks []ResponseSink, record SinkRecord) error {
+	var errs []error
+	for _, sink := range sinks {
+		if err := sink.Write(ctx, record); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func CloseSinks(sinks []ResponseSink) error {
+	var errs []error
+	for _, sink := range sinks {
+		if err := sink.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// sinkMetaColumns come before the item fields in the CSV and Markdown sinks.
+var sinkMetaColumns = []string{"session", "acceptedAt", "schema", "model", "item"}
+
+// responseRows flattens a response into one row per element of its "items" array,
+// or a single row of its top-level fields when it has none. Columns keep the JSON field order.
+func responseRows(raw json.RawMessage) (columns []string, rows []map[string]string, err error) {
+	_, fields, err := orderedObject(raw)
+	if err != nil {
+		return nil, nil, err
+	}
+	objects := []json.RawMessage{raw}
+	if items, ok := fields["items"]; ok {
+		var elements []json.RawMessage
+		if json.Unmarshal(items, &elements) == nil {
+			objects = elements
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, object := range objects {
+		keys, fields, err := orderedObject(object)
+		if err != nil {
+			return nil, nil, err
+		}
+		row := map[string]string{}
+		for _, key := range keys {
+			if !seen[key] {
+				seen[key] = true
+				columns = append(columns, key)
+			}
+			row[key] = sinkValue(fields[key])
+		}
+		rows = append(rows, row)
+	}
+	return columns, rows, nil
+}
+
+// orderedObject decodes a JSON object and returns its keys in document order.
+func orderedObject(raw json.RawMessage) ([]string, map[string]json.RawMessage, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	if token, err := dec.Token(); err != nil || token != json.Delim('{') {
+		return nil, nil, fmt.Errorf("response is not a JSON object")
+	}
+	var keys []string
+	fields := map[string]json.RawMessage{}
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return nil, nil, err
+		}
+		key, _ := token.(string)
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil, nil, err
+		}
+		if _, dup := fields[key]; !dup {
+			keys = append(keys, key)
+		}
+		fields[key] = value
+	}
+	return keys, fields, nil
+}
+
+// sinkValue is a field as a cell: strings unquoted, null empty, objects and arrays as JSON.
+func sinkValue(raw json.RawMessage) string {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+	if string(raw) == "null" {
+		return ""
+	}
+	var compact bytes.Buffer
+	if json.Compact(&compact, raw) == nil {
+		return compact.String()
+	}
+	return string(raw)
+}
+
+func sinkMeta(record SinkRecord, item int) map[string]string {
+	return map[string]string{
+		"session":    record.Session.ID,
+		"acceptedAt": record.AcceptedAt.Format(time.RFC3339),
+		"schema":     record.Response.Schema,
+		"model":      record.Session.Model,
+		"item":       strconv.Itoa(item + 1),
+	}
+}
+
+func appendFile(path string, data []byte) error {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// JSONLSink appends every SinkRecord as a JSON line.
+type JSONLSink struct {
+	path string
+	mu   sync.Mutex
+}
+
+func (sink *JSONLSink) Write(ctx context.Context, record SinkRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("jsonl sink: %w", err)
+	}
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	if err := appendFile(sink.path, append(line, '\n')); err != nil {
+		return fmt.Errorf("jsonl sink %s: %w", sink.path, err)
+	}
+	return nil
+}
+
+func (sink *JSONLSink) Close() error { return nil }
+
+// CSVSink appends one row per item. The header is written with the first response; later
+// responses use the header of the existing file, fields that are not in it are dropped.
+type CSVSink struct {
+	path string
+	mu   sync.Mutex
+}
+
+func (sink *CSVSink) Write(ctx context.Context, record SinkRecord) error {
+	columns, rows, err := responseRows(record.Response.Raw)
+	if err != nil {
+		return fmt.Errorf("csv sink: %w", err)
+	}
+
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	header, err := sink.header()
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if header == nil {
+		header = append(append([]string(nil), sinkMetaColumns...), columns...)
+		w.Write(header)
+	} else {
+		for _, column := range columns {
+			if !containsFold(header, column) {
+				fmt.Printf("csv sink %s: column %s is not in the header, dropped\n", sink.path, column)
+			}
+		}
+	}
+	for i, row := range rows {
+		meta := sinkMeta(record, i)
+		cells := make([]string, len(header))
+		for j, column := range header {
+			if value, ok := meta[column]; ok && j < len(sinkMetaColumns) {
+				cells[j] = value
+			} else {
+				cells[j] = row[column]
+			}
+		}
+		w.Write(cells)
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("csv sink: %w", err)
+	}
+	if err := appendFile(sink.path, buf.Bytes()); err != nil {
+		return fmt.Errorf("csv sink %s: %w", sink.path, err)
+	}
+	return nil
+}
+
+// header reads the header of an existing file, nil when the file is new or empty.
+func (sink *CSVSink) header() ([]string, error) {
+	file, err := os.Open(sink.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("csv sink %s: %w", sink.path, err)
+	}
+	defer file.Close()
+	header, err := csv.NewReader(file).Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("csv sink %s header: %w", sink.path, err)
+	}
+	return header, nil
+}
+
+func (sink *CSVSink) Close() error { return nil }
+
+// MarkdownSink appends a heading and a table for every response, so responses of different
+// shapes can share one file.
+type MarkdownSink struct {
+	path string
+	mu   sync.Mutex
+}
+
+func (sink *MarkdownSink) Write(ctx context.Context, record SinkRecord) error {
+	columns, rows, err := responseRows(record.Response.Raw)
+	if err != nil {
+		return fmt.Errorf("markdown sink: %w", err)
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "### %s %s\n\n", record.Response.Schema, record.AcceptedAt.Format("2006-01-02 15:04:05"))
+	fmt.Fprintf(&buf, "Session `%s`, model `%s`", record.Session.ID, record.Session.Model)
+	if record.Verdict != nil {
+		fmt.Fprintf(&buf, ", approved by `%s`", record.Verdict.Inspector)
+	}
+	buf.WriteString(".\n\n")
+
+	header := append([]string{"#"}, columns...)
+	buf.WriteString("| " + strings.Join(markdownCells(header), " | ") + " |\n")
+	buf.WriteString("|" + strings.Repeat(" --- |", len(header)) + "\n")
+	for i, row := range rows {
+		cells := []string{strconv.Itoa(i + 1)}
+		for _, column := range columns {
+			cells = append(cells, row[column])
+		}
+		buf.WriteString("| " + strings.Join(markdownCells(cells), " | ") + " |\n")
+	}
+	buf.WriteString("\n")
+
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	if err := appendFile(sink.path, buf.Bytes()); err != nil {
+		return fmt.Errorf("markdown sink %s: %w", sink.path, err)
+	}
+	return nil
+}
+
+func markdownCells(cells []string) []string {
+	escaped := make([]string, len(cells))
+	replacer := strings.NewReplacer("|", `\|`, "\r\n", "<br>", "\n", "<br>")
+	for i, cell := range cells {
+		escaped[i] = replacer.Replace(cell)
+	}
+	return escaped
+}
+
+func (sink *MarkdownSink) Close() error { return nil }
@@ -0,0 +1,100 @@
+//go:build cgo
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const sqliteSinkSchema = `
+CREATE TABLE IF NOT EXISTS responses (
+	id                 INTEGER PRIMARY KEY AUTOINCREMENT,
+	session_id         TEXT NOT NULL,
+	session_created_at TEXT NOT NULL,
+	model              TEXT NOT NULL,
+	inspector_model    TEXT NOT NULL,
+	mode               TEXT NOT NULL,
+	schema             TEXT NOT NULL,
+	accepted_at        TEXT NOT NULL,
+	inspector          TEXT NOT NULL,
+	raw                TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS response_items (
+	response_id INTEGER NOT NULL REFERENCES responses(id),
+	item        INTEGER NOT NULL,
+	field       TEXT NOT NULL,
+	value       TEXT NOT NULL,
+	PRIMARY KEY (response_id, item, field)
+);
+CREATE INDEX IF NOT EXISTS responses_schema ON responses(schema, accepted_at);
+`
+
+// SQLiteSink stores responses in a SQLite database: the raw response in "responses" and
+// every item field as a row of "response_items", so any schema fits the same tables.
+type SQLiteSink struct {
+	path string
+	db   *sql.DB
+}
+
+func OpenSQLiteSink(path string) (*SQLiteSink, error) {
+	db, err := sql.Open("sqlite3", path+"?_busy_timeout=5000&_journal_mode=WAL")
+	if err != nil {
+		return nil, fmt.Errorf("open sqlite sink %s: %w", path, err)
+	}
+	if _, err := db.Exec(sqliteSinkSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("create sqlite sink tables in %s: %w", path, err)
+	}
+	return &SQLiteSink{path: path, db: db}, nil
+}
+
+func (sink *SQLiteSink) Write(ctx context.Context, record SinkRecord) error {
+	_, rows, err := responseRows(record.Response.Raw)
+	if err != nil {
+		return fmt.Errorf("sqlite sink: %w", err)
+	}
+	inspector := ""
+	if record.Verdict != nil {
+		inspector = record.Verdict.Inspector
+	}
+
+	tx, err := sink.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("sqlite sink %s: %w", sink.path, err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx,
+		`INSERT INTO responses (session_id, session_created_at, model, inspector_model, mode, schema, accepted_at, inspector, raw)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		record.Session.ID, record.Session.CreatedAt.Format(time.RFC3339), record.Session.Model, record.Session.InspectorModel,
+		record.Session.Mode, record.Response.Schema, record.AcceptedAt.Format(time.RFC3339), inspector, string(record.Response.Raw))
+	if err != nil {
+		return fmt.Errorf("sqlite sink insert response: %w", err)
+	}
+	responseID, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("sqlite sink: %w", err)
+	}
+	for i, row := range rows {
+		for field, value := range row {
+			if _, err := tx.ExecContext(ctx, `INSERT INTO response_items (response_id, item, field, value) VALUES (?, ?, ?, ?)`,
+				responseID, i+1, field, value); err != nil {
+				return fmt.Errorf("sqlite sink insert item: %w", err)
+			}
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("sqlite sink %s: %w", sink.path, err)
+	}
+	return nil
+}
+
+func (sink *SQLiteSink) Close() error {
+	return sink.db.Close()
+}
@@ -0,0 +1,12 @@
+//go:build !cgo
+
+package main
+
+import "errors"
+
+// SQLiteSink is unavailable without cgo, github.com/mattn/go-sqlite3 wraps the SQLite C library.
+type SQLiteSink struct{ ResponseSink }
+
+func OpenSQLiteSink(path string) (*SQLiteSink, error) {
+	return nil, errors.New("sqlite sink needs a build with cgo: CGO_ENABLED=1 and a C compiler")
+}
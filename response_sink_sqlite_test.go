@@ -0,0 +1,40 @@
+//go:build cgo
+
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestSQLiteSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "responses.db")
+	sink, err := OpenSQLiteSink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeSink(t, sink, `{"items":[{"itemName":"TT","value1":"250"},{"itemName":"Z4","value1":"240"}]}`)
+
+	// reopening keeps the tables and their rows
+	sink, err = OpenSQLiteSink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+	ctx := context.Background()
+	var session, inspector string
+	if err := sink.db.QueryRowContext(ctx, `SELECT session_id, inspector FROM responses`).Scan(&session, &inspector); err != nil {
+		t.Fatal(err)
+	}
+	if session != "s1" || inspector != "rules" {
+		t.Errorf("response of session %q by %q", session, inspector)
+	}
+	var value string
+	if err := sink.db.QueryRowContext(ctx, `SELECT value FROM response_items WHERE item = 2 AND field = 'value1'`).Scan(&value); err != nil {
+		t.Fatal(err)
+	}
+	if value != "240" {
+		t.Errorf("items[1].value1 = %q, want 240", value)
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func sinkRecord(raw string) SinkRecord {
+	return SinkRecord{
+		Session:    SessionMeta{ID: "s1", Model: "m"},
+		AcceptedAt: time.Date(2025, 12, 1, 9, 30, 0, 0, time.UTC),
+		Response:   StructuredResponse{Schema: "z_rsp", Raw: json.RawMessage(raw)},
+		Verdict:    &Verdict{Approved: true, Inspector: "rules"},
+	}
+}
+
+func writeSink(t *testing.T, sink ResponseSink, raws ...string) {
+	t.Helper()
+	for _, raw := range raws {
+		if err := sink.Write(context.Background(), sinkRecord(raw)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestJSONLSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.jsonl")
+	writeSink(t, &JSONLSink{path: path}, validRepairPayload, `{"name":"x"}`)
+
+	data, _ := os.ReadFile(path)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("%d lines, want 2", len(lines))
+	}
+	var record SinkRecord
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatal(err)
+	}
+	if record.Session.ID != "s1" || record.Verdict.Inspector != "rules" || string(record.Response.Raw) != validRepairPayload {
+		t.Errorf("record %+v", record)
+	}
+}
+
+func TestCSVSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.csv")
+	two := `{"items":[{"itemName":"TT","value1":"250"},{"itemName":"Z4","value1":{"min":1}}]}`
+	// the second response has a field the header doesn't know, it is dropped
+	writeSink(t, &CSVSink{path: path}, two, `{"items":[{"value1":"90","extra":"x","itemName":"A3"}]}`)
+
+	file, _ := os.Open(path)
+	defer file.Close()
+	rows, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"session", "acceptedAt", "schema", "model", "item", "itemName", "value1"},
+		{"s1", "2025-12-01T09:30:00Z", "z_rsp", "m", "1", "TT", "250"},
+		{"s1", "2025-12-01T09:30:00Z", "z_rsp", "m", "2", "Z4", `{"min":1}`},
+		{"s1", "2025-12-01T09:30:00Z", "z_rsp", "m", "1", "A3", "90"},
+	}
+	if fmt.Sprint(rows) != fmt.Sprint(want) {
+		t.Errorf("csv rows %q, want %q", rows, want)
+	}
+}
+
+func TestMarkdownSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.md")
+	writeSink(t, &MarkdownSink{path: path}, `{"name":"a|b","note":"two\nlines","size":null}`)
+
+	data, _ := os.ReadFile(path)
+	want := "### z_rsp 2025-12-01 09:30:00\n\n" +
+		"Session `s1`, model `m`, approved by `rules`.\n\n" +
+		"| # | name | note | size |\n" +
+		"| --- | --- | --- | --- |\n" +
+		"| 1 | a\\|b | two<br>lines |  |\n\n"
+	if string(data) != want {
+		t.Errorf("markdown\n%s\nwant\n%s", data, want)
+	}
+}
+
+func TestLoadSinks(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, "sinks.json")
+	os.WriteFile(config, mustJSON(t, SinksConfig{
+		"car": {{Type: SinkJSONL, Path: filepath.Join(dir, "{schema}/out.jsonl")}, {Type: SinkCSV, Path: filepath.Join(dir, "cars.csv")}},
+		"*":   {{Type: SinkMarkdown, Path: filepath.Join(dir, "{schema}.md")}},
+		"bad": {{Type: "xml", Path: "out.xml"}},
+	}), 0644)
+
+	tests := []struct {
+		schema string
+		want   []string
+	}{
+		{schema: "car", want: []string{"*main.JSONLSink", "*main.CSVSink"}},
+		{schema: "bullet", want: []string{"*main.MarkdownSink"}},
+	}
+	for _, tt := range tests {
+		sinks, err := LoadSinks(config, tt.schema)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, sink := range sinks {
+			got = append(got, fmt.Sprintf("%T", sink))
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("sinks of %s are %v, want %v", tt.schema, got, tt.want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "car")); err != nil {
+		t.Errorf("the {schema} dir was not created: %v", err)
+	}
+	if _, err := LoadSinks(config, "bad"); err == nil {
+		t.Error("unknown sink type loaded")
+	}
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	ErrSessionNotFound = errors.New("session not found")
+	ErrSessionBusy     = errors.New("session is processing a message")
+	ErrTooManySessions = errors.New("too many concurrent sessions")
+)
+
+const (
+	defaultMaxSessions = 100
+	defaultIdleTimeout = 30 * time.Minute
+)
+
+// SessionManager owns the interviewers of a frontend that serves many users, keyed by session id.
+// A session runs one Step at a time, sessions idle for longer than the idle timeout are closed,
+// and at most maxSessions are open at once.
+type SessionManager struct {
+	open        func(id string) (*AgentInterviewer, error)
+	maxSessions int
+	idleTimeout time.Duration
+
+	mu       sync.Mutex
+	sessions map[string]*ManagedSession
+	// opening holds the ids being opened, so concurrent opens of one id wait for the first
+	opening  map[string]*pendingOpen
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// ManagedSession is an interviewer owned by a SessionManager.
+type ManagedSession struct {
+	ID        string
+	CreatedAt time.Time
+	agent     *AgentInterviewer
+	// busy is held while a Step or Reset runs.
+	busy sync.Mutex
+
+	// guarded by SessionManager.mu
+	lastUsed time.Time
+	running  bool
+	closed   bool
+	last     *InterviewerEvent
+}
+
+// pendingOpen is an Open in progress, session and err are set before done is closed.
+type pendingOpen struct {
+	done    chan struct{}
+	session *ManagedSession
+	err     error
+}
+
+// SessionInfo is a snapshot of a ManagedSession.
+type SessionInfo struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	LastUsed  time.Time `json:"lastUsed"`
+	Schema    string    `json:"schema"`
+	// AwaitingInput is false while a message is processed.
+	AwaitingInput bool `json:"awaitingInput"`
+	// Response is the last accepted response of the session.
+	Response *InterviewerEvent `json:"response,omitempty"`
+}
+
+// NewSessionManager manages interviewers built by open, which creates the interviewer of a new id
+// or resumes a stored one. maxSessions and idleTimeout of 0 use the defaults (100, 30m).
+func NewSessionManager(open func(id string) (*AgentInterviewer, error), maxSessions int, idleTimeout time.Duration) *SessionManager {
+	if maxSessions <= 0 {
+		maxSessions = defaultMaxSessions
+	}
+	if idleTimeout <= 0 {
+		idleTimeout = defaultIdleTimeout
+	}
+	m := &SessionManager{
+		open:        open,
+		maxSessions: maxSessions,
+		idleTimeout: idleTimeout,
+		sessions:    map[string]*ManagedSession{},
+		opening:     map[string]*pendingOpen{},
+		stop:        make(chan struct{}),
+	}
+	go m.janitor()
+	return m
+}
+
+func (m *SessionManager) janitor() {
+	interval := min(max(m.idleTimeout/4, time.Second), time.Minute)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+			m.EvictIdle()
+		}
+	}
+}
+
+// Open returns the open session id, or opens it. An empty id opens a new session with a generated id.
+// Concurrent opens of one id share the session opened by the first of them.
+func (m *SessionManager) Open(id string) (*ManagedSession, error) {
+	if id == "" {
+		id = NewSessionID(time.Now())
+	}
+
+	m.mu.Lock()
+	if session, ok := m.sessions[id]; ok {
+		session.lastUsed = time.Now()
+		m.mu.Unlock()
+		return session, nil
+	}
+	if pending, ok := m.opening[id]; ok {
+		m.mu.Unlock()
+		<-pending.done
+		return pending.session, pending.err
+	}
+	if err := m.makeRoomLocked(); err != nil {
+		m.mu.Unlock()
+		return nil, err
+	}
+	pending := &pendingOpen{done: make(chan struct{})}
+	m.opening[id] = pending
+	m.mu.Unlock()
+
+	// opening reads or creates the session log, the other sessions must not wait for it
+	agent, err := m.open(id)
+
+	m.mu.Lock()
+	delete(m.opening, id)
+	if err == nil {
+		now := time.Now()
+		pending.session = &ManagedSession{ID: id, CreatedAt: now, agent: agent, lastUsed: now}
+		m.sessions[id] = pending.session
+		fmt.Printf("session manager: opened %s, %d open\n", id, len(m.sessions))
+	}
+	pending.err = err
+	m.mu.Unlock()
+	close(pending.done)
+	return pending.session, pending.err
+}
+
+// makeRoomLocked evicts idle sessions when maxSessions are open or being opened, and fails if that
+// doesn't help.
+func (m *SessionManager) makeRoomLocked() error {
+	if len(m.sessions)+len(m.opening) >= m.maxSessions {
+		m.evictIdleLocked()
+	}
+	if len(m.sessions)+len(m.opening) >= m.maxSessions {
+		return fmt.Errorf("%w (%d)", ErrTooManySessions, m.maxSessions)
+	}
+	return nil
+}
+
+// Get returns an open session.
+func (m *SessionManager) Get(id string) (*ManagedSession, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	session, ok := m.sessions[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrSessionNotFound, id)
+	}
+	return session, nil
+}
+
+// Step runs one interviewer Step of session, see AgentInterviewer.Step. It fails with ErrSessionBusy
+// instead of waiting when the session is already processing a message.
+func (m *SessionManager) Step(ctx context.Context, session *ManagedSession, userInput string, emit func(InterviewerEvent)) error {
+	if err := m.acquire(session); err != nil {
+		return err
+	}
+	defer m.release(session)
+
+	return session.agent.Step(ctx, userInput, func(event InterviewerEvent) {
+		if event.Type == EventResponse && event.Accepted {
+			m.mu.Lock()
+			session.last = &event
+			m.mu.Unlock()
+		}
+		if emit != nil {
+			emit(event)
+		}
+	})
+}
+
+// Reset drops the dialog of session, the session stays open.
+func (m *SessionManager) Reset(session *ManagedSession, reason string) error {
+	if err := m.acquire(session); err != nil {
+		return err
+	}
+	defer m.release(session)
+	session.agent.resetDialog(reason)
+	return nil
+}
+
+func (m *SessionManager) acquire(session *ManagedSession) error {
+	if !session.busy.TryLock() {
+		return ErrSessionBusy
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if session.closed {
+		session.busy.Unlock()
+		return fmt.Errorf("%w: %s", ErrSessionNotFound, session.ID)
+	}
+	session.running = true
+	session.lastUsed = time.Now()
+	return nil
+}
+
+func (m *SessionManager) release(session *ManagedSession) {
+	m.mu.Lock()
+	session.running = false
+	session.lastUsed = time.Now()
+	m.mu.Unlock()
+	session.busy.Unlock()
+}
+
+// Info returns a snapshot of session.
+func (m *SessionManager) Info(session *ManagedSession) SessionInfo {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return SessionInfo{
+		ID:            session.ID,
+		CreatedAt:     session.CreatedAt,
+		LastUsed:      session.lastUsed,
+		Schema:        session.agent.schema.Name,
+		AwaitingInput: !session.running,
+		Response:      session.last,
+	}
+}
+
+// List returns snapshots of the open sessions, most recently used first.
+func (m *SessionManager) List() []SessionInfo {
+	m.mu.Lock()
+	sessions := make([]*ManagedSession, 0, len(m.sessions))
+	for _, session := range m.sessions {
+		sessions = append(sessions, session)
+	}
+	m.mu.Unlock()
+
+	infos := make([]SessionInfo, 0, len(sessions))
+	for _, session := range sessions {
+		infos = append(infos, m.Info(session))
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].LastUsed.After(infos[j].LastUsed) })
+	return infos
+}
+
+// Close closes session id, waiting for a running Step to finish.
+func (m *SessionManager) Close(id string) error {
+	m.mu.Lock()
+	session, ok := m.sessions[id]
+	if ok {
+		delete(m.sessions, id)
+		session.closed = true
+	}
+	m.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrSessionNotFound, id)
+	}
+
+	session.busy.Lock()
+	defer session.busy.Unlock()
+	return session.agent.Close()
+}
+
+// EvictIdle closes the sessions that were not used for the idle timeout and returns how many.
+func (m *SessionManager) EvictIdle() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.evictIdleLocked()
+}
+
+func (m *SessionManager) evictIdleLocked() int {
+	now := time.Now()
+	evicted := 0
+	for id, session := range m.sessions {
+		if session.running || now.Sub(session.lastUsed) < m.idleTimeout {
+			continue
+		}
+		// a Step may be about to start, it then finds the session closed
+		if !session.busy.TryLock() {
+			continue
+		}
+		delete(m.sessions, id)
+		session.closed = true
+		session.busy.Unlock()
+		if err := session.agent.Close(); err != nil {
+			fmt.Printf("session manager: close %s: %v\n", id, err)
+		}
+		evicted++
+		fmt.Printf("session manager: evicted %s, idle since %s\n", id, session.lastUsed.Format(time.RFC3339))
+	}
+	return evicted
+}
+
+// Shutdown stops eviction and closes every session, waiting for running Steps.
+func (m *SessionManager) Shutdown() {
+	m.stopOnce.Do(func() { close(m.stop) })
+	m.mu.Lock()
+	ids := make([]string, 0, len(m.sessions))
+	for id := range m.sessions {
+		ids = append(ids, id)
+	}
+	m.mu.Unlock()
+	for _, id := range ids {
+		m.Close(id)
+	}
+}
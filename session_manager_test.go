@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// unreachableChatModel fails every call, the manager tests never talk to a model.
+type unreachableChatModel struct{}
+
+func (unreachableChatModel) Chat(context.Context, ChatRequest) (ChatResponse, error) {
+	return ChatResponse{}, errors.New("no model in this test")
+}
+
+func newTestSessionAgent() *AgentInterviewer {
+	return NewAgentInterviewer(unreachableChatModel{}, nil, MustDefaultResponseSchema())
+}
+
+func TestSessionManagerOpenDoesNotBlockOtherSessions(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	manager := NewSessionManager(func(id string) (*AgentInterviewer, error) {
+		if id == "slow" {
+			close(started)
+			<-release
+		}
+		return newTestSessionAgent(), nil
+	}, 10, time.Hour)
+	defer manager.Shutdown()
+
+	slow := make(chan error, 1)
+	go func() {
+		_, err := manager.Open("slow")
+		slow <- err
+	}()
+	<-started
+
+	opened := make(chan error, 1)
+	go func() {
+		_, err := manager.Open("fast")
+		opened <- err
+	}()
+	select {
+	case err := <-opened:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Open of another id waited for a slow open")
+	}
+	if _, err := manager.Get("slow"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("Get of a session being opened = %v, want ErrSessionNotFound", err)
+	}
+
+	close(release)
+	if err := <-slow; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := manager.Get("slow"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSessionManagerConcurrentOpenOfOneID(t *testing.T) {
+	var mu sync.Mutex
+	opens := 0
+	manager := NewSessionManager(func(id string) (*AgentInterviewer, error) {
+		mu.Lock()
+		opens++
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		return newTestSessionAgent(), nil
+	}, 10, time.Hour)
+	defer manager.Shutdown()
+
+	const callers = 8
+	sessions := make([]*ManagedSession, callers)
+	var wg sync.WaitGroup
+	for i := range sessions {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			session, err := manager.Open("shared")
+			if err != nil {
+				t.Error(err)
+			}
+			sessions[i] = session
+		}()
+	}
+	wg.Wait()
+
+	for _, session := range sessions[1:] {
+		if session != sessions[0] {
+			t.Fatal("concurrent opens of one id returned different sessions")
+		}
+	}
+	if got := len(manager.List()); got != 1 {
+		t.Errorf("%d sessions open, want 1", got)
+	}
+	if opens != 1 {
+		t.Errorf("open called %d times, want 1", opens)
+	}
+}
+
+func TestSessionManagerConcurrentOpenOfNewStoredSession(t *testing.T) {
+	store := NewSessionStore(t.TempDir())
+	manager := NewSessionManager(func(id string) (*AgentInterviewer, error) {
+		var session *Session
+		var err error
+		if store.Exists(id) {
+			session, err = store.Open(id)
+		} else {
+			// widen the window between the check and the create that an unserialized open races in
+			time.Sleep(20 * time.Millisecond)
+			session, err = store.Create(SessionMeta{ID: id})
+		}
+		if err != nil {
+			return nil, err
+		}
+		return NewAgentInterviewer(unreachableChatModel{}, nil, MustDefaultResponseSchema(), WithSession(session)), nil
+	}, 10, time.Hour)
+	defer manager.Shutdown()
+
+	const callers = 8
+	sessions := make([]*ManagedSession, callers)
+	var wg sync.WaitGroup
+	for i := range sessions {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			session, err := manager.Open("tg42")
+			if err != nil {
+				t.Errorf("concurrent Open of a new id: %v", err)
+			}
+			sessions[i] = session
+		}()
+	}
+	wg.Wait()
+
+	for _, session := range sessions[1:] {
+		if session != sessions[0] {
+			t.Fatal("concurrent opens of one id returned different sessions")
+		}
+	}
+	if !store.Exists("tg42") {
+		t.Error("the session log was not created")
+	}
+}
+
+func TestSessionManagerMaxSessions(t *testing.T) {
+	manager := NewSessionManager(func(id string) (*AgentInterviewer, error) {
+		return newTestSessionAgent(), nil
+	}, 1, time.Hour)
+	defer manager.Shutdown()
+
+	if _, err := manager.Open("a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := manager.Open("a"); err != nil {
+		t.Errorf("reopening an open session: %v", err)
+	}
+	if _, err := manager.Open("b"); !errors.Is(err, ErrTooManySessions) {
+		t.Errorf("Open past maxSessions = %v, want ErrTooManySessions", err)
+	}
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultSessionDir is used when neither -sessions-dir nor ADVENT_SESSIONS_DIR is set.
+const defaultSessionDir = "sessions"
+
+// Types of session log records.
+const (
+	sessionRecordMeta     = "meta"
+	sessionRecordTurn     = "turn"
+	sessionRecordReset    = "reset"
+	sessionRecordResponse = "response"
+	sessionRecordCompact  = "compact"
+	sessionRecordRollback = "rollback"
+)
+
+// SessionMeta is the first record of a session log.
+type SessionMeta struct {
+	ID             string    `json:"id"`
+	CreatedAt      time.Time `json:"createdAt"`
+	Model          string    `json:"model"`
+	InspectorModel string    `json:"inspectorModel,omitempty"`
+	Schema         string    `json:"schema"`
+	Mode           string    `json:"mode"`
+}
+
+// sessionRecord is one line of a session log.
+type sessionRecord struct {
+	Type     string              `json:"type"`
+	Time     time.Time           `json:"time"`
+	Meta     *SessionMeta        `json:"meta,omitempty"`
+	Turn     *dialogTurn         `json:"turn,omitempty"`
+	Reason   string              `json:"reason,omitempty"`
+	Response *StructuredResponse `json:"response,omitempty"`
+	// Verdict of the inspector for a response record, nil when it was not inspected.
+	Verdict *Verdict `json:"verdict,omitempty"`
+	// Model is the model that gave the response of a response record.
+	Model string `json:"model,omitempty"`
+	// Compact is how the dialog was compacted to fit the context window, or the turns a rollback dropped.
+	Compact *dialogCompaction `json:"compact,omitempty"`
+}
+
+// SessionSummary describes a stored session for listing.
+type SessionSummary struct {
+	Meta      SessionMeta
+	UpdatedAt time.Time
+	Turns     int
+	Responses int
+	// FirstInput is the first Z_PROVIDE_DATA of the session, it usually names the topic.
+	FirstInput string
+}
+
+// SessionStore keeps interviewer sessions as append-only JSONL files, one per session.
+type SessionStore struct {
+	dir string
+}
+
+func NewSessionStore(dir string) *SessionStore {
+	return &SessionStore{dir: dir}
+}
+
+// SessionDirFromEnv returns ADVENT_SESSIONS_DIR, or defaultSessionDir.
+func SessionDirFromEnv() string {
+	if dir := os.Getenv("ADVENT_SESSIONS_DIR"); dir != "" {
+		return dir
+	}
+	return defaultSessionDir
+}
+
+// Session is an open session log. Every turn is written as soon as it is added to the dialog,
+// so a crash or Ctrl-C loses nothing but the answer being generated.
+type Session struct {
+	Meta SessionMeta
+
+	mu     sync.Mutex
+	file   *os.File
+	dialog []dialogTurn
+}
+
+// NewSessionID returns a sortable, random session id like 20251201-093000-1a2b3c.
+func NewSessionID(t time.Time) string {
+	suffix := make([]byte, 3)
+	rand.Read(suffix)
+	return t.Format("20060102-150405") + "-" + hex.EncodeToString(suffix)
+}
+
+// validSessionID rejects ids that would escape the sessions dir.
+func validSessionID(id string) bool {
+	return id != "" && !strings.ContainsAny(id, `/\`) && id != "." && id != ".."
+}
+
+func (s *SessionStore) path(id string) string {
+	return filepath.Join(s.dir, id+".jsonl")
+}
+
+// Create starts a new session log, meta.ID and meta.CreatedAt are filled in when empty.
+func (s *SessionStore) Create(meta SessionMeta) (*Session, error) {
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return nil, fmt.Errorf("create sessions dir: %w", err)
+	}
+	if meta.CreatedAt.IsZero() {
+		meta.CreatedAt = time.Now()
+	}
+	if meta.ID == "" {
+		meta.ID = NewSessionID(meta.CreatedAt)
+	}
+	if !validSessionID(meta.ID) {
+		return nil, fmt.Errorf("invalid session id %q", meta.ID)
+	}
+
+	file, err := os.OpenFile(s.path(meta.ID), os.O_APPEND|os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("create session %s: %w", meta.ID, err)
+	}
+	session := &Session{Meta: meta, file: file}
+	if err := session.append(sessionRecord{Type: sessionRecordMeta, Time: meta.CreatedAt, Meta: &meta}); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return session, nil
+}
+
+// Exists reports whether a session log with id is stored.
+func (s *SessionStore) Exists(id string) bool {
+	if !validSessionID(id) {
+		return false
+	}
+	_, err := os.Stat(s.path(id))
+	return err == nil
+}
+
+// Open loads a session log and reopens it for appending. The dialog is restored up to the last reset.
+func (s *SessionStore) Open(id string) (*Session, error) {
+	records, err := s.read(id)
+	if err != nil {
+		return nil, err
+	}
+
+	session := &Session{}
+	for _, record := range records {
+		switch record.Type {
+		case sessionRecordMeta:
+			session.Meta = *record.Meta
+		case sessionRecordTurn:
+			session.dialog = append(session.dialog, *record.Turn)
+		case sessionRecordReset:
+			session.dialog = nil
+		case sessionRecordCompact, sessionRecordRollback:
+			if record.Compact != nil {
+				session.dialog = record.Compact.apply(session.dialog)
+			}
+		}
+	}
+	if session.Meta.ID == "" {
+		return nil, fmt.Errorf("session %s has no meta record", id)
+	}
+
+	if session.file, err = os.OpenFile(s.path(id), os.O_APPEND|os.O_WRONLY, 0644); err != nil {
+		return nil, fmt.Errorf("open session %s: %w", id, err)
+	}
+	return session, nil
+}
+
+// List returns the stored sessions, most recently updated first.
+func (s *SessionStore) List() ([]SessionSummary, error) {
+	paths, err := filepath.Glob(filepath.Join(s.dir, "*.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+
+	var summaries []SessionSummary
+	for _, path := range paths {
+		id := strings.TrimSuffix(filepath.Base(path), ".jsonl")
+		records, err := s.read(id)
+		if err != nil {
+			fmt.Printf("skip session %s: %v\n", id, err)
+			continue
+		}
+
+		var summary SessionSummary
+		for _, record := range records {
+			summary.UpdatedAt = record.Time
+			switch record.Type {
+			case sessionRecordMeta:
+				summary.Meta = *record.Meta
+			case sessionRecordTurn:
+				summary.Turns++
+				if summary.FirstInput == "" && record.Turn.Kind == dialogKindProvideData {
+					summary.FirstInput = record.Turn.Message.Content
+				}
+			case sessionRecordResponse:
+				summary.Responses++
+			case sessionRecordRollback:
+				if record.Compact != nil {
+					summary.Turns -= record.Compact.Count
+				}
+			}
+		}
+		if summary.Meta.ID == "" {
+			continue
+		}
+		summaries = append(summaries, summary)
+	}
+
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].UpdatedAt.After(summaries[j].UpdatedAt) })
+	return summaries, nil
+}
+
+// read parses a session log. A torn last line, left by a crash in the middle of a write, is skipped.
+func (s *SessionStore) read(id string) ([]sessionRecord, error) {
+	if !validSessionID(id) {
+		return nil, fmt.Errorf("invalid session id %q", id)
+	}
+	data, err := os.ReadFile(s.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("session %s not found in %s", id, s.dir)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read session %s: %w", id, err)
+	}
+
+	var records []sessionRecord
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var record sessionRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			fmt.Printf("session %s line %d: %v, skipped\n", id, line, err)
+			continue
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
+
+// Dialog returns the dialog restored by Open.
+func (s *Session) Dialog() []dialogTurn {
+	return s.dialog
+}
+
+func (s *Session) AppendTurn(turn dialogTurn) error {
+	return s.append(sessionRecord{Type: sessionRecordTurn, Time: time.Now(), Turn: &turn})
+}
+
+// Reset marks the end of a dialog, turns before it are not restored.
+func (s *Session) Reset(reason string) error {
+	return s.append(sessionRecord{Type: sessionRecordReset, Time: time.Now(), Reason: reason})
+}
+
+// AppendResponse records a finalized response, model is the model that answered.
+func (s *Session) AppendResponse(resp StructuredResponse, verdict *Verdict, model string) error {
+	return s.append(sessionRecord{Type: sessionRecordResponse, Time: time.Now(), Response: &resp, Verdict: verdict, Model: model})
+}
+
+// Compact records that the dialog was compacted, the turns it replaced are not restored.
+func (s *Session) Compact(compaction dialogCompaction) error {
+	return s.append(sessionRecord{Type: sessionRecordCompact, Time: time.Now(), Compact: &compaction})
+}
+
+// Rollback records that the turns of a failed step were dropped, reason is the failure.
+func (s *Session) Rollback(rollback dialogCompaction, reason string) error {
+	return s.append(sessionRecord{Type: sessionRecordRollback, Time: time.Now(), Compact: &rollback, Reason: reason})
+}
+
+func (s *Session) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
+
+func (s *Session) append(record sessionRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("marshal session record: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("write session %s: %w", s.Meta.ID, err)
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func userTurn(text string) dialogTurn {
+	return dialogTurn{Kind: dialogKindProvideData, Message: ChatMessage{Role: ChatRoleUser, Content: text}}
+}
+
+func TestSessionStoreReplay(t *testing.T) {
+	store := NewSessionStore(t.TempDir())
+	session, err := store.Create(SessionMeta{ID: "s1", Model: "m", Mode: InterviewerModeMarkers})
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendTurns := func(texts ...string) {
+		for _, text := range texts {
+			if err := session.AppendTurn(userTurn(text)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	summary := dialogTurn{Kind: dialogKindSummary, Message: ChatMessage{Role: ChatRoleUser, Content: "a car"}}
+
+	appendTurns("old dialog")
+	session.Reset("restart")
+	appendTurns("I have a car", "Audi TT", "250", "km/h")
+	session.Compact(dialogCompaction{From: 1, Count: 2, Summary: &summary})
+	appendTurns("failed step")
+	session.Rollback(dialogCompaction{From: 3, Count: 1}, "model gone")
+	session.AppendResponse(StructuredResponse{}, nil, "m")
+	session.Close()
+
+	if _, err := store.Create(SessionMeta{ID: "s1"}); err == nil {
+		t.Error("Create of an existing session succeeded")
+	}
+
+	resumed, err := store.Open("s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := dialogContents(resumed.Dialog())
+	want := []string{"provide_data:I have a car", "summary:a car", "provide_data:km/h"}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("replayed dialog %q, want %q", got, want)
+	}
+	if resumed.Meta.Model != "m" || resumed.Meta.CreatedAt.IsZero() {
+		t.Errorf("meta %+v", resumed.Meta)
+	}
+
+	// the reopened log is appended to
+	if err := resumed.AppendTurn(userTurn("more")); err != nil {
+		t.Fatal(err)
+	}
+	resumed.Close()
+	again, err := store.Open("s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer again.Close()
+	if n := len(again.Dialog()); n != 4 {
+		t.Errorf("dialog has %d turns after reopening, want 4", n)
+	}
+}
+
+func TestSessionStoreOpenSkipsTornLastLine(t *testing.T) {
+	store := NewSessionStore(t.TempDir())
+	session, err := store.Create(SessionMeta{ID: "s1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	session.AppendTurn(userTurn("Audi TT"))
+	session.file.WriteString(`{"type":"turn","turn":{"kind":"provide_da`)
+	session.Close()
+
+	resumed, err := store.Open("s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resumed.Close()
+	if got := dialogContents(resumed.Dialog()); len(got) != 1 || got[0] != "provide_data:Audi TT" {
+		t.Errorf("dialog %q, want the turn before the torn line", got)
+	}
+}
+
+func TestSessionStoreList(t *testing.T) {
+	dir := t.TempDir()
+	store := NewSessionStore(dir)
+	for _, id := range []string{"a", "b"} {
+		session, err := store.Create(SessionMeta{ID: id})
+		if err != nil {
+			t.Fatal(err)
+		}
+		session.AppendTurn(userTurn("topic " + id))
+		session.AppendTurn(userTurn("dropped"))
+		session.Rollback(dialogCompaction{From: 1, Count: 1}, "failed")
+		if id == "b" {
+			session.AppendResponse(StructuredResponse{}, nil, "m")
+		}
+		session.Close()
+	}
+	os.WriteFile(dir+"/empty.jsonl", nil, 0644)
+
+	summaries, err := store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summaries) != 2 || summaries[0].Meta.ID != "b" {
+		t.Fatalf("summaries %+v, want b then a", summaries)
+	}
+	if s := summaries[0]; s.Turns != 1 || s.Responses != 1 || s.FirstInput != "topic b" {
+		t.Errorf("summary %+v", s)
+	}
+
+	for _, id := range []string{"../a", "", "missing"} {
+		if _, err := store.Open(id); err == nil {
+			t.Errorf("Open(%q) succeeded", id)
+		}
+	}
+}
@@ -0,0 +1,555 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// ShellSeverity ranks shell findings, a ShellSafetyInspector rejects findings at or above its block level.
+type ShellSeverity int
+
+const (
+	ShellSeverityLow ShellSeverity = iota + 1
+	ShellSeverityMedium
+	ShellSeverityHigh
+	ShellSeverityCritical
+)
+
+var shellSeverityNames = map[ShellSeverity]string{
+	ShellSeverityLow:      "low",
+	ShellSeverityMedium:   "medium",
+	ShellSeverityHigh:     "high",
+	ShellSeverityCritical: "critical",
+}
+
+func (s ShellSeverity) String() string {
+	if name, ok := shellSeverityNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("severity(%d)", int(s))
+}
+
+func ParseShellSeverity(name string) (ShellSeverity, error) {
+	for severity, n := range shellSeverityNames {
+		if strings.EqualFold(n, name) {
+			return severity, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown shell severity %q, expected low, medium, high or critical", name)
+}
+
+// Shell finding rules.
+const (
+	ShellRuleUnparsable      = "unparsable"
+	ShellRuleSudo            = "sudo"
+	ShellRuleRecursiveDelete = "recursive-delete"
+	ShellRuleRemoteScript    = "remote-script"
+	ShellRuleEtcWrite        = "etc-write"
+	ShellRuleRecursivePerms  = "recursive-permissions"
+	ShellRuleDiskWrite       = "disk-write"
+)
+
+// ShellFinding is one destructive pattern found in a command.
+type ShellFinding struct {
+	Rule     string        `json:"rule"`
+	Severity ShellSeverity `json:"severity"`
+	Message  string        `json:"message"`
+	// Command is the offending command as printed back from the syntax tree.
+	Command string `json:"command"`
+}
+
+func (f ShellFinding) String() string {
+	return fmt.Sprintf("%s [%s] %s: %s", f.Severity, f.Rule, f.Command, f.Message)
+}
+
+var (
+	// shellWrappers run the command given in their arguments.
+	shellWrappers = []string{"sudo", "doas", "env", "nohup", "nice", "time", "command", "exec", "xargs"}
+	// sudoArgFlags are the sudo and doas flags that take a value.
+	sudoArgFlags      = []string{"-u", "-g", "-C", "-D", "-h", "-p", "-r", "-t", "-U"}
+	shellDownloaders  = []string{"curl", "wget", "fetch"}
+	shellInterpreters = []string{"sh", "bash", "zsh", "dash", "ksh", "fish", "eval", "source", ".",
+		"python", "python3", "perl", "ruby", "node"}
+	// xargsArgFlags are the xargs flags that take a value.
+	xargsArgFlags = []string{"-a", "-d", "-E", "-I", "-L", "-n", "-P", "-s"}
+	// shellScriptRunners run the script given with -c, which is analyzed like the command itself.
+	shellScriptRunners = []string{"sh", "bash", "zsh", "dash", "ksh"}
+	// shellPlaceholder matches template holes like {folder_name} or <folder>, which the model
+	// copies from examples instead of a real value.
+	shellPlaceholder = regexp.MustCompile(`\{[A-Za-z_][A-Za-z0-9_ -]*\}|<[A-Za-z_][A-Za-z0-9_ -]*>`)
+)
+
+// AnalyzeShell parses command as bash and reports destructive patterns: recursive deletes of broad
+// or unresolved paths, sudo, scripts piped from the network into a shell and writes to /etc.
+// Nothing is executed.
+func AnalyzeShell(command string) ([]ShellFinding, error) {
+	file, err := syntax.NewParser(syntax.Variant(syntax.LangBash)).Parse(strings.NewReader(command), "")
+	if err != nil {
+		return nil, fmt.Errorf("parse shell: %w", err)
+	}
+
+	var findings []ShellFinding
+	add := func(node syntax.Node, rule string, severity ShellSeverity, format string, args ...any) {
+		finding := ShellFinding{Rule: rule, Severity: severity, Message: fmt.Sprintf(format, args...), Command: printShell(node)}
+		for _, seen := range findings {
+			if seen.Rule == finding.Rule && seen.Command == finding.Command {
+				return
+			}
+		}
+		findings = append(findings, finding)
+	}
+
+	syntax.Walk(file, func(node syntax.Node) bool {
+		switch node := node.(type) {
+		case *syntax.CallExpr:
+			analyzeShellCall(node, add)
+		case *syntax.BinaryCmd:
+			if (node.Op == syntax.Pipe || node.Op == syntax.PipeAll) &&
+				shellCallsAny(node.X, shellDownloaders) && shellCallsAny(node.Y, shellInterpreters) {
+				add(node, ShellRuleRemoteScript, ShellSeverityCritical, "runs a script downloaded from the network")
+			}
+		case *syntax.Stmt:
+			for _, redirect := range node.Redirs {
+				switch redirect.Op {
+				case syntax.RdrOut, syntax.AppOut, syntax.ClbOut, syntax.RdrAll, syntax.AppAll:
+					if target, _ := shellWordText(redirect.Word); isEtcPath(target) {
+						add(node, ShellRuleEtcWrite, ShellSeverityHigh, "redirects output into %s", target)
+					}
+				}
+			}
+		}
+		return true
+	})
+	return findings, nil
+}
+
+// shellFindingFunc adds a finding about node.
+type shellFindingFunc func(node syntax.Node, rule string, severity ShellSeverity, format string, args ...any)
+
+func analyzeShellCall(call *syntax.CallExpr, add shellFindingFunc) {
+	analyzeShellArgs(call, call.Args, add)
+}
+
+// analyzeShellArgs analyzes the command made of args, findings are reported on call. Commands run by
+// wrappers, find -exec and sh -c are analyzed as well.
+func analyzeShellArgs(call *syntax.CallExpr, args []*syntax.Word, add shellFindingFunc) {
+	for len(args) > 0 {
+		name := shellCommandName(args[0])
+		if !containsFold(shellWrappers, name) {
+			break
+		}
+		if name == "sudo" || name == "doas" {
+			add(call, ShellRuleSudo, ShellSeverityMedium, "runs with root privileges")
+		}
+		args = skipShellWrapperArgs(name, args[1:])
+	}
+	if len(args) == 0 {
+		return
+	}
+	name, operands, flags := shellCommandName(args[0]), shellOperands(args[1:]), shellFlags(args[1:])
+
+	switch name {
+	case "rm":
+		recursive := flags["r"] || flags["R"] || flags["recursive"]
+		for _, operand := range operands {
+			text, resolved := shellWordText(operand)
+			switch {
+			case recursive && !resolved:
+				add(call, ShellRuleRecursiveDelete, ShellSeverityHigh, "recursively deletes %s, which is not a concrete path", printShell(operand))
+			case recursive && isBroadPath(text):
+				add(call, ShellRuleRecursiveDelete, ShellSeverityCritical, "recursively deletes %s", text)
+			case isEtcPath(text):
+				add(call, ShellRuleEtcWrite, ShellSeverityHigh, "deletes %s", text)
+			case recursive:
+				add(call, ShellRuleRecursiveDelete, ShellSeverityLow, "recursively deletes %s", text)
+			}
+		}
+	case "chmod", "chown", "chgrp":
+		if !flags["R"] && !flags["recursive"] {
+			break
+		}
+		for _, operand := range operands {
+			if text, resolved := shellWordText(operand); !resolved || isBroadPath(text) {
+				add(call, ShellRuleRecursivePerms, ShellSeverityHigh, "recursively changes permissions of %s", printShell(operand))
+			}
+		}
+	case "tee":
+		for _, operand := range operands {
+			if text, _ := shellWordText(operand); isEtcPath(text) {
+				add(call, ShellRuleEtcWrite, ShellSeverityHigh, "writes %s", text)
+			}
+		}
+	case "cp", "mv", "install", "ln", "rsync":
+		if len(operands) > 1 {
+			if text, _ := shellWordText(operands[len(operands)-1]); isEtcPath(text) {
+				add(call, ShellRuleEtcWrite, ShellSeverityHigh, "writes into %s", text)
+			}
+		}
+	case "sed":
+		if !flags["i"] && !flags["in-place"] {
+			break
+		}
+		for _, operand := range operands {
+			if text, _ := shellWordText(operand); isEtcPath(text) {
+				add(call, ShellRuleEtcWrite, ShellSeverityHigh, "edits %s in place", text)
+			}
+		}
+	case "dd":
+		for _, operand := range operands {
+			if text, _ := shellWordText(operand); strings.HasPrefix(text, "of=/dev/") && text != "of=/dev/null" {
+				add(call, ShellRuleDiskWrite, ShellSeverityCritical, "overwrites device %s", strings.TrimPrefix(text, "of="))
+			}
+		}
+	case "find":
+		analyzeShellFind(call, args[1:], add)
+	case "mkfs", "fdisk", "parted", "wipefs", "shred":
+		add(call, ShellRuleDiskWrite, ShellSeverityCritical, "%s destroys data on a device", name)
+	default:
+		if strings.HasPrefix(name, "mkfs.") {
+			add(call, ShellRuleDiskWrite, ShellSeverityCritical, "%s formats a device", name)
+		}
+	}
+
+	// sh -c "$(curl ...)", bash <(curl ...), eval "$(wget -O- ...)"
+	if containsFold(shellInterpreters, name) {
+		for _, arg := range args[1:] {
+			if shellCallsAny(arg, shellDownloaders) {
+				add(call, ShellRuleRemoteScript, ShellSeverityCritical, "runs a script downloaded from the network")
+			}
+		}
+	}
+
+	// bash -c 'rm -rf /', sudo sh -c "rm -rf ~"
+	if containsFold(shellScriptRunners, name) {
+		if script := shellScriptArg(args[1:]); script != nil {
+			// expansions are kept as text, so the script judges them like a command of its own
+			text, _ := shellWordText(script)
+			findings, err := AnalyzeShell(text)
+			if err != nil {
+				add(call, ShellRuleUnparsable, ShellSeverityMedium, "runs a script that can't be analyzed: %v", err)
+			}
+			for _, finding := range findings {
+				add(call, finding.Rule, finding.Severity, "runs %s, which %s", finding.Command, finding.Message)
+			}
+		}
+	}
+}
+
+// shellFindNameTests narrow what find acts on to files with matching names.
+var shellFindNameTests = []string{"-name", "-iname", "-path", "-ipath", "-wholename", "-iwholename", "-regex", "-iregex"}
+
+// analyzeShellFind reports find -delete and the commands run by find -exec, with {} standing for the
+// starting points. Deleting what a name test matches is at most a medium finding.
+func analyzeShellFind(call *syntax.CallExpr, args []*syntax.Word, add shellFindingFunc) {
+	// find [-H] [-L] [-P] [-D debugopts] [-Olevel] [starting-point...] [expression]
+	for len(args) > 0 {
+		arg := args[0].Lit()
+		if arg == "-D" {
+			args = args[min(2, len(args)):]
+		} else if arg == "-H" || arg == "-L" || arg == "-P" || strings.HasPrefix(arg, "-O") {
+			args = args[1:]
+		} else {
+			break
+		}
+	}
+	var paths []*syntax.Word
+	for len(args) > 0 {
+		if arg := args[0].Lit(); strings.HasPrefix(arg, "-") || arg == "(" || arg == "!" {
+			break
+		}
+		paths = append(paths, args[0])
+		args = args[1:]
+	}
+	if len(paths) == 0 {
+		paths = []*syntax.Word{{Parts: []syntax.WordPart{&syntax.Lit{Value: "."}}}}
+	}
+
+	var narrowed, deletes bool
+	var commands [][]*syntax.Word
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i].Lit(); {
+		case slices.Contains(shellFindNameTests, arg):
+			narrowed = true
+		case arg == "-delete":
+			deletes = true
+		case arg == "-exec" || arg == "-execdir" || arg == "-ok" || arg == "-okdir":
+			var command []*syntax.Word
+			for i++; i < len(args); i++ {
+				if text, _ := shellWordText(args[i]); text == ";" || text == `\;` || text == "+" {
+					break
+				}
+				if text, resolved := shellWordText(args[i]); resolved && text == "{}" {
+					command = append(command, paths...)
+				} else {
+					command = append(command, args[i])
+				}
+			}
+			commands = append(commands, command)
+			deletes = deletes || shellCallsAny(&syntax.CallExpr{Args: command}, []string{"rm"})
+		}
+	}
+
+	if deletes {
+		severity := func(severity ShellSeverity) ShellSeverity {
+			if narrowed {
+				return min(severity, ShellSeverityMedium)
+			}
+			return severity
+		}
+		for _, p := range paths {
+			text, resolved := shellWordText(p)
+			switch {
+			case !resolved:
+				add(call, ShellRuleRecursiveDelete, severity(ShellSeverityHigh), "deletes the files it finds under %s, which is not a concrete path", printShell(p))
+			case isBroadPath(text):
+				add(call, ShellRuleRecursiveDelete, severity(ShellSeverityCritical), "deletes the files it finds under %s", text)
+			case isEtcPath(text):
+				add(call, ShellRuleEtcWrite, ShellSeverityHigh, "deletes the files it finds under %s", text)
+			default:
+				add(call, ShellRuleRecursiveDelete, ShellSeverityLow, "deletes the files it finds under %s", text)
+			}
+		}
+	}
+	// reported after the delete above, so a narrowed find -exec rm -rf {} stays at its severity
+	for _, command := range commands {
+		analyzeShellArgs(call, command, add)
+	}
+}
+
+// shellScriptArg returns the script given to a shell with -c, or nil.
+func shellScriptArg(args []*syntax.Word) *syntax.Word {
+	for i := 0; i < len(args); i++ {
+		arg := args[i].Lit()
+		switch {
+		case arg == "-o" || arg == "-O" || arg == "+o" || arg == "+O":
+			i++
+		case arg == "--":
+			return nil
+		case strings.HasPrefix(arg, "--"):
+		case strings.HasPrefix(arg, "-") && strings.Contains(arg, "c"):
+			if i+1 < len(args) {
+				return args[i+1]
+			}
+			return nil
+		case strings.HasPrefix(arg, "-") || strings.HasPrefix(arg, "+"):
+		default:
+			// the first operand is a script file
+			return nil
+		}
+	}
+	return nil
+}
+
+// skipShellWrapperArgs drops the options and assignments of a wrapper command.
+func skipShellWrapperArgs(wrapper string, args []*syntax.Word) []*syntax.Word {
+	for len(args) > 0 {
+		arg := args[0].Lit()
+		switch {
+		case arg == "--":
+			return args[1:]
+		case (wrapper == "sudo" || wrapper == "doas") && slices.Contains(sudoArgFlags, arg),
+			wrapper == "xargs" && slices.Contains(xargsArgFlags, arg):
+			args = args[min(2, len(args)):]
+		case strings.HasPrefix(arg, "-"), wrapper == "env" && strings.Contains(arg, "="):
+			args = args[1:]
+		case wrapper == "nice" && arg != "" && strings.Trim(arg, "0123456789") == "":
+			args = args[1:]
+		default:
+			return args
+		}
+	}
+	return args
+}
+
+// shellFlags returns the short (split into letters) and long flags of a command.
+func shellFlags(args []*syntax.Word) map[string]bool {
+	flags := map[string]bool{}
+	for _, word := range args {
+		arg := word.Lit()
+		switch {
+		case arg == "--":
+			return flags
+		case strings.HasPrefix(arg, "--"):
+			flags[strings.SplitN(arg[2:], "=", 2)[0]] = true
+		case strings.HasPrefix(arg, "-"):
+			for _, letter := range arg[1:] {
+				flags[string(letter)] = true
+			}
+		}
+	}
+	return flags
+}
+
+// shellOperands returns the arguments that are not flags.
+func shellOperands(args []*syntax.Word) []*syntax.Word {
+	var operands []*syntax.Word
+	for i, word := range args {
+		arg := word.Lit()
+		if arg == "--" {
+			return append(operands, args[i+1:]...)
+		}
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			operands = append(operands, word)
+		}
+	}
+	return operands
+}
+
+func shellCommandName(word *syntax.Word) string {
+	text, _ := shellWordText(word)
+	return path.Base(text)
+}
+
+// shellWordText returns the value of a word after quote removal. $HOME and $PWD are written as
+// ~ and . so they can be judged as paths; resolved is false when the word has other expansions
+// or template placeholders.
+func shellWordText(word *syntax.Word) (text string, resolved bool) {
+	if word == nil {
+		return "", false
+	}
+	var sb strings.Builder
+	resolved = shellWordParts(&sb, word.Parts)
+	text = sb.String()
+	if shellPlaceholder.MatchString(text) {
+		resolved = false
+	}
+	return text, resolved
+}
+
+func shellWordParts(sb *strings.Builder, parts []syntax.WordPart) bool {
+	resolved := true
+	for _, part := range parts {
+		switch part := part.(type) {
+		case *syntax.Lit:
+			sb.WriteString(part.Value)
+		case *syntax.SglQuoted:
+			sb.WriteString(part.Value)
+		case *syntax.DblQuoted:
+			resolved = shellWordParts(sb, part.Parts) && resolved
+		case *syntax.ParamExp:
+			switch {
+			case part.Param == nil:
+				resolved = false
+			case part.Param.Value == "HOME":
+				sb.WriteString("~")
+			case part.Param.Value == "PWD":
+				sb.WriteString(".")
+			default:
+				sb.WriteString("$" + part.Param.Value)
+				resolved = false
+			}
+		default:
+			resolved = false
+		}
+	}
+	return resolved
+}
+
+// isBroadPath reports paths whose recursive delete wipes a system, a home or the working directory:
+// /, top-level directories, ~, ., .. and bare globs.
+func isBroadPath(p string) bool {
+	p = strings.TrimSuffix(strings.TrimSuffix(p, "*"), "/")
+	switch {
+	case p == "" || p == "~" || p == "." || p == ".." || p == "*" || p == ".*":
+		return true
+	case strings.HasPrefix(p, "~/"):
+		return !strings.Contains(path.Clean(p[2:]), "/")
+	case strings.HasPrefix(p, "/"):
+		clean := path.Clean(p)
+		return clean == "/" || strings.Count(clean, "/") == 1
+	}
+	return false
+}
+
+func isEtcPath(p string) bool {
+	return p != "" && strings.HasPrefix(path.Clean(p)+"/", "/etc/")
+}
+
+// shellCallsAny reports whether node runs any of the named commands, including through wrappers,
+// command substitutions and process substitutions.
+func shellCallsAny(node syntax.Node, names []string) bool {
+	found := false
+	syntax.Walk(node, func(n syntax.Node) bool {
+		call, ok := n.(*syntax.CallExpr)
+		if !ok || found {
+			return !found
+		}
+		for i, arg := range call.Args {
+			name := shellCommandName(arg)
+			if containsFold(names, name) {
+				found = true
+				return false
+			}
+			if i > 0 || !containsFold(shellWrappers, name) {
+				break
+			}
+			if rest := skipShellWrapperArgs(name, call.Args[1:]); len(rest) > 0 && containsFold(names, shellCommandName(rest[0])) {
+				found = true
+				return false
+			}
+		}
+		return true
+	})
+	return found
+}
+
+func printShell(node syntax.Node) string {
+	var sb strings.Builder
+	if err := syntax.NewPrinter(syntax.SingleLine(true)).Print(&sb, node); err != nil {
+		return fmt.Sprintf("%v", node)
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+// ShellSafetyInspector runs AnalyzeShell on the value1 of every item whose value1Units is "bash code" and
+// rejects the response when a finding reaches the block severity. Lower findings are printed only.
+type ShellSafetyInspector struct {
+	block ShellSeverity
+}
+
+func NewShellSafetyInspector(block ShellSeverity) *ShellSafetyInspector {
+	return &ShellSafetyInspector{block: block}
+}
+
+func (inspector *ShellSafetyInspector) Inspect(ctx context.Context, resp StructuredResponse) (Verdict, error) {
+	verdict := Verdict{Inspector: "shell:" + inspector.block.String()}
+
+	var doc map[string]any
+	if err := json.Unmarshal(resp.Raw, &doc); err != nil {
+		return Verdict{}, fmt.Errorf("shell inspector: %w", err)
+	}
+	items, _ := doc["items"].([]any)
+	for i, raw := range items {
+		item, _ := raw.(map[string]any)
+		if !strings.EqualFold(strings.TrimSpace(fieldString(item, "value1Units")), "bash code") {
+			continue
+		}
+		command := fieldString(item, "value1")
+		findings, err := AnalyzeShell(command)
+		if err != nil {
+			findings = []ShellFinding{{Rule: ShellRuleUnparsable, Severity: ShellSeverityHigh, Message: err.Error(), Command: command}}
+		}
+		for _, finding := range findings {
+			fmt.Printf("shell finding item=%d %s\n", i, finding)
+			if finding.Severity < inspector.block {
+				continue
+			}
+			verdict.Issues = append(verdict.Issues, InspectionIssue{
+				Item:    i,
+				Field:   "value1",
+				Message: fmt.Sprintf("%s shell command %q: %s", finding.Severity, finding.Command, finding.Message),
+				Question: fmt.Sprintf("The command for %s %s. What exact, non-destructive command should be used instead, "+
+					"with concrete paths and without root privileges unless they are really needed?", fieldString(item, "itemName"), finding.Message),
+			})
+		}
+	}
+	verdict.Approved = len(verdict.Issues) == 0
+	return verdict, nil
+}
@@ -1,17 +1,18 @@
 package main
 
 import (
+	"fmt"
 	"log"
 	"os"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
-func SendToTelegram(message string) {
+func SendToTelegram(message string) error {
 	// Replace with your bot token from BotFather
 	botToken := os.Getenv("TELEGRAM_BOT_TOKEN")
 	if botToken == "" {
-		log.Fatal("TELEGRAM_BOT_TOKEN environment variable not set")
+		return fmt.Errorf("TELEGRAM_BOT_TOKEN environment variable not set")
 	}
 
 	// Replace with your own Telegram user ID
@@ -19,15 +20,16 @@ func SendToTelegram(message string) {
 
 	bot, err := tgbotapi.NewBotAPI(botToken)
 	if err != nil {
-		log.Fatalf("Error creating bot: %v", err)
+		return fmt.Errorf("error creating bot: %w", err)
 	}
 
 	msg := tgbotapi.NewMessage(userID, message)
 
 	_, err = bot.Send(msg)
 	if err != nil {
-		log.Fatalf("Error sending message: %v", err)
+		return fmt.Errorf("error sending message: %w", err)
 	}
 
 	log.Println("Message sent to Saved Messages successfully!")
+	return nil
 }
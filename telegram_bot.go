@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"strings"
+	"sync"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// telegramMessageLimit is the longest text Telegram accepts in one message.
+const telegramMessageLimit = 4096
+
+// TelegramInterviewBot runs interviews over Telegram long polling, every chat has its own
+// interviewer. Replies of the user become Z_PROVIDE_DATA, clarifying questions and the
+// finalized response are sent back as bot messages.
+type TelegramInterviewBot struct {
+	bot      *tgbotapi.BotAPI
+	sessions *SessionManager
+	// allowed limits the bot to these chats, empty allows every chat.
+	allowed map[int64]bool
+
+	// queues holds the pending messages of every chat that has a worker, a chat has at most one.
+	mu      sync.Mutex
+	queues  map[int64][]*tgbotapi.Message
+	workers sync.WaitGroup
+}
+
+// NewTelegramInterviewBot serves chats from manager, the session of a chat is "tg<chat id>",
+// so a chat continues its dialog after the session was evicted or the bot restarted.
+func NewTelegramInterviewBot(bot *tgbotapi.BotAPI, manager *SessionManager, allowedChats []int64) *TelegramInterviewBot {
+	allowed := map[int64]bool{}
+	for _, id := range allowedChats {
+		allowed[id] = true
+	}
+	return &TelegramInterviewBot{bot: bot, sessions: manager, allowed: allowed, queues: map[int64][]*tgbotapi.Message{}}
+}
+
+// Run polls updates until ctx is cancelled. Chats are served concurrently, messages of one chat in order.
+// It returns once the messages being handled are done.
+func (b *TelegramInterviewBot) Run(ctx context.Context) error {
+	config := tgbotapi.NewUpdate(0)
+	config.Timeout = 60
+	updates := b.bot.GetUpdatesChan(config)
+	defer b.workers.Wait()
+	defer b.bot.StopReceivingUpdates()
+	fmt.Printf("telegram bot @%s is polling\n", b.bot.Self.UserName)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case update, ok := <-updates:
+			if !ok {
+				return nil
+			}
+			if update.Message == nil || update.Message.Text == "" {
+				continue
+			}
+			b.enqueue(ctx, update.Message)
+		}
+	}
+}
+
+// enqueue queues msg for its chat and starts the worker of the chat unless it is running.
+func (b *TelegramInterviewBot) enqueue(ctx context.Context, msg *tgbotapi.Message) {
+	chatID := msg.Chat.ID
+	b.mu.Lock()
+	queue, running := b.queues[chatID]
+	b.queues[chatID] = append(queue, msg)
+	b.mu.Unlock()
+	if !running {
+		b.workers.Add(1)
+		go b.serveChat(ctx, chatID)
+	}
+}
+
+// serveChat handles the queued messages of a chat one at a time, and exits when the queue is empty.
+func (b *TelegramInterviewBot) serveChat(ctx context.Context, chatID int64) {
+	defer b.workers.Done()
+	for {
+		b.mu.Lock()
+		queue := b.queues[chatID]
+		if len(queue) == 0 {
+			delete(b.queues, chatID)
+			b.mu.Unlock()
+			return
+		}
+		b.queues[chatID] = queue[1:]
+		b.mu.Unlock()
+		b.handle(ctx, queue[0])
+	}
+}
+
+func (b *TelegramInterviewBot) handle(ctx context.Context, msg *tgbotapi.Message) {
+	chatID := msg.Chat.ID
+	if len(b.allowed) > 0 && !b.allowed[chatID] {
+		fmt.Printf("telegram chat=%d is not allowed, ignored\n", chatID)
+		b.send(chatID, "This bot is not enabled for this chat.")
+		return
+	}
+
+	session, err := b.sessions.Open(fmt.Sprintf("tg%d", chatID))
+	if err != nil {
+		fmt.Printf("telegram chat=%d: %v\n", chatID, err)
+		b.send(chatID, "Can't start an interview right now, please try again later.")
+		return
+	}
+
+	switch msg.Command() {
+	case "start":
+		b.send(chatID, "Describe what you want to record, I will ask for anything that is missing. /reset starts over.")
+		return
+	case "reset":
+		if err := b.sessions.Reset(session, "reset by user"); errors.Is(err, ErrSessionBusy) {
+			b.send(chatID, "Still working on your previous message, reset after the answer.")
+			return
+		}
+		b.send(chatID, "Dialog reset. Send new data to start over.")
+		return
+	}
+
+	b.bot.Request(tgbotapi.NewChatAction(chatID, tgbotapi.ChatTyping))
+	err = b.sessions.Step(ctx, session, msg.Text, func(event InterviewerEvent) {
+		b.sendEvent(chatID, event)
+	})
+	switch {
+	case errors.Is(err, ErrSessionBusy):
+		b.send(chatID, "Still working on your previous message, please wait for the answer.")
+	case err != nil:
+		fmt.Printf("telegram chat=%d step: %v\n", chatID, err)
+		b.send(chatID, "Something went wrong, please send your message again. ("+err.Error()+")")
+	}
+}
+
+func (b *TelegramInterviewBot) sendEvent(chatID int64, event InterviewerEvent) {
+	switch event.Type {
+	case EventQuestion:
+		b.send(chatID, event.Text)
+	case EventResponse:
+		if event.Accepted {
+			b.sendHTML(chatID, formatTelegramResponse(event))
+			return
+		}
+		var sb strings.Builder
+		sb.WriteString("Some data needs checking:")
+		for _, issue := range event.Verdict.Issues {
+			sb.WriteString("\n- " + issue.Message)
+		}
+		b.send(chatID, sb.String())
+	case EventReset:
+		b.send(chatID, "Dialog reset ("+event.Text+"). Send new data to start over.")
+	}
+}
+
+// formatTelegramResponse renders an accepted response as an HTML list, one entry per item.
+func formatTelegramResponse(event InterviewerEvent) string {
+	var sb strings.Builder
+	sb.WriteString("<b>Recorded</b>")
+	if event.Verdict != nil {
+		sb.WriteString(" (checked by " + html.EscapeString(event.Verdict.Inspector) + ")")
+	}
+	sb.WriteString("\n")
+
+	if zrsp, ok := event.Response.Value.(*ZRsp); ok {
+		for i, item := range zrsp.Items {
+			fmt.Fprintf(&sb, "\n%d. <b>%s</b> (%s)\n%s: <code>%s</code> %s\n", i+1,
+				html.EscapeString(item.ItemName), html.EscapeString(item.ItemType),
+				html.EscapeString(item.Value1Name), html.EscapeString(item.Value1), html.EscapeString(item.Value1Units))
+		}
+		return sb.String()
+	}
+
+	columns, rows, err := responseRows(event.Response.Raw)
+	if err != nil {
+		var pretty bytes.Buffer
+		json.Indent(&pretty, event.Response.Raw, "", "  ")
+		return sb.String() + "<pre>" + html.EscapeString(pretty.String()) + "</pre>"
+	}
+	for i, row := range rows {
+		fmt.Fprintf(&sb, "\n%d.\n", i+1)
+		for _, column := range columns {
+			if value := row[column]; value != "" {
+				fmt.Fprintf(&sb, "%s: <code>%s</code>\n", html.EscapeString(column), html.EscapeString(value))
+			}
+		}
+	}
+	return sb.String()
+}
+
+func (b *TelegramInterviewBot) send(chatID int64, text string) {
+	b.sendMessage(tgbotapi.NewMessage(chatID, truncateRunes(text, telegramMessageLimit)))
+}
+
+func (b *TelegramInterviewBot) sendHTML(chatID int64, text string) {
+	if len([]rune(text)) > telegramMessageLimit {
+		// cutting HTML may break a tag, send it as plain text instead
+		b.send(chatID, text)
+		return
+	}
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ParseMode = tgbotapi.ModeHTML
+	b.sendMessage(msg)
+}
+
+func (b *TelegramInterviewBot) sendMessage(msg tgbotapi.MessageConfig) {
+	if _, err := b.bot.Send(msg); err != nil {
+		fmt.Printf("telegram send chat=%d: %v\n", msg.ChatID, err)
+	}
+}
+
+func truncateRunes(s string, limit int) string {
+	runes := []rune(s)
+	if len(runes) <= limit {
+		return s
+	}
+	return string(runes[:limit-1]) + "…"
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// fakeTelegramAPI is a Bot API server that serves updates once and records the sent messages.
+type fakeTelegramAPI struct {
+	mu      sync.Mutex
+	updates []tgbotapi.Update
+	sent    map[int64][]string
+}
+
+func (api *fakeTelegramAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	var result any = true
+	api.mu.Lock()
+	switch method := r.URL.Path[len("/bottoken/"):]; method {
+	case "getMe":
+		result = tgbotapi.User{ID: 1, IsBot: true, UserName: "advent_test_bot"}
+	case "getUpdates":
+		result, api.updates = api.updates, []tgbotapi.Update{}
+	case "sendMessage":
+		chatID, _ := strconv.ParseInt(r.Form.Get("chat_id"), 10, 64)
+		api.sent[chatID] = append(api.sent[chatID], r.Form.Get("text"))
+		result = tgbotapi.Message{MessageID: len(api.sent[chatID]), Chat: &tgbotapi.Chat{ID: chatID}}
+	}
+	api.mu.Unlock()
+	data, _ := json.Marshal(result)
+	json.NewEncoder(w).Encode(tgbotapi.APIResponse{Ok: true, Result: data})
+}
+
+// messages returns the texts sent to chatID once there are n of them.
+func (api *fakeTelegramAPI) messages(t *testing.T, chatID int64, n int) []string {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		api.mu.Lock()
+		sent := append([]string(nil), api.sent[chatID]...)
+		api.mu.Unlock()
+		if len(sent) >= n || time.Now().After(deadline) {
+			return sent
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func newTestTelegramBot(t *testing.T, api *fakeTelegramAPI, model ChatModel, allowedChats ...int64) *TelegramInterviewBot {
+	t.Helper()
+	server := httptest.NewServer(api)
+	t.Cleanup(server.Close)
+	bot, err := tgbotapi.NewBotAPIWithAPIEndpoint("token", server.URL+"/bot%s/%s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	manager := NewSessionManager(func(id string) (*AgentInterviewer, error) {
+		return NewAgentInterviewer(model, nil, MustDefaultResponseSchema()), nil
+	}, 10, time.Hour)
+	t.Cleanup(manager.Shutdown)
+	return NewTelegramInterviewBot(bot, manager, allowedChats)
+}
+
+// echoQuestionChatModel asks back about the last reply of the user after delay.
+type echoQuestionChatModel struct{ delay time.Duration }
+
+func (m echoQuestionChatModel) Chat(ctx context.Context, req ChatRequest) (ChatResponse, error) {
+	time.Sleep(m.delay)
+	reply := stripStubMarkers(req.Messages[len(req.Messages)-1].Content)
+	markers := defaultPromptMarkers
+	return ChatResponse{Text: fmt.Sprintf("%s\nWhat about %s?\n%s", markers.CollectDataStart, reply, markers.CollectDataEnd)}, nil
+}
+
+func telegramUpdate(id int, chatID int64, text string) tgbotapi.Update {
+	msg := &tgbotapi.Message{MessageID: id, Chat: &tgbotapi.Chat{ID: chatID}, Text: text}
+	if text[0] == '/' {
+		msg.Entities = []tgbotapi.MessageEntity{{Type: "bot_command", Length: len(text)}}
+	}
+	return tgbotapi.Update{UpdateID: id, Message: msg}
+}
+
+func TestTelegramBotRun(t *testing.T) {
+	api := &fakeTelegramAPI{sent: map[int64][]string{}, updates: []tgbotapi.Update{
+		telegramUpdate(1, 7, "/start"),
+		telegramUpdate(2, 8, "hi"),
+		telegramUpdate(3, 7, "Audi TT"),
+	}}
+	b := newTestTelegramBot(t, api, echoQuestionChatModel{}, 7)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- b.Run(ctx) }()
+
+	got := api.messages(t, 7, 2)
+	if len(got) != 2 || got[1] != "What about Audi TT?" {
+		t.Errorf("chat 7 got %q, want the start text and a question about Audi TT", got)
+	}
+	if got := api.messages(t, 8, 1); len(got) != 1 || got[0] != "This bot is not enabled for this chat." {
+		t.Errorf("chat 8 got %q, want it to be turned away", got)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Run = %v, want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after ctx was cancelled")
+	}
+}
+
+func TestTelegramBotHandlesMessagesOfAChatInOrder(t *testing.T) {
+	api := &fakeTelegramAPI{sent: map[int64][]string{}}
+	b := newTestTelegramBot(t, api, echoQuestionChatModel{delay: 20 * time.Millisecond})
+
+	ctx := context.Background()
+	for i, text := range []string{"first", "second", "third"} {
+		b.enqueue(ctx, telegramUpdate(i+1, 7, text).Message)
+	}
+	b.workers.Wait()
+
+	want := []string{"What about first?", "What about second?", "What about third?"}
+	got := api.messages(t, 7, len(want))
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("chat got %q, want %q", got, want)
+	}
+}
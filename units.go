@@ -0,0 +1,300 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type Dimension string
+
+const (
+	DimensionLength   Dimension = "length"
+	DimensionSpeed    Dimension = "speed"
+	DimensionMass     Dimension = "mass"
+	DimensionTime     Dimension = "time"
+	DimensionData     Dimension = "data"
+	DimensionCurrency Dimension = "currency"
+)
+
+// Unit is a unit of a dimension. Factor converts one Unit into the canonical unit of the dimension.
+type Unit struct {
+	Symbol    string
+	Dimension Dimension
+	Factor    float64
+	Aliases   []string
+}
+
+// Quantity is a parsed Value1 with its unit.
+type Quantity struct {
+	Value     float64   `json:"value"`
+	Unit      string    `json:"unit"`
+	Dimension Dimension `json:"dimension"`
+}
+
+func (q Quantity) String() string {
+	return strconv.FormatFloat(q.Value, 'f', -1, 64) + " " + q.Unit
+}
+
+// CurrencyRates is a static exchange rate table: Rates[code] is the price of one code in Base.
+type CurrencyRates struct {
+	Base  string             `json:"base"`
+	Rates map[string]float64 `json:"rates"`
+}
+
+// defaultCurrencyRates is an offline approximation, pass a -rates file for real numbers.
+var defaultCurrencyRates = CurrencyRates{
+	Base: "usd",
+	Rates: map[string]float64{
+		"usd": 1,
+		"eur": 1.08,
+		"gbp": 1.27,
+		"pln": 0.25,
+		"byn": 0.31,
+		"rub": 0.011,
+	},
+}
+
+// LoadCurrencyRates reads a CurrencyRates JSON file.
+func LoadCurrencyRates(path string) (CurrencyRates, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return CurrencyRates{}, fmt.Errorf("read rates: %w", err)
+	}
+	var rates CurrencyRates
+	if err := json.Unmarshal(data, &rates); err != nil {
+		return CurrencyRates{}, fmt.Errorf("parse rates %s: %w", path, err)
+	}
+	if rates.Base == "" {
+		return CurrencyRates{}, fmt.Errorf("rates %s: base currency is empty", path)
+	}
+	for code, rate := range rates.Rates {
+		if !(rate > 0) || math.IsInf(rate, 0) {
+			return CurrencyRates{}, fmt.Errorf("rates %s: rate of %s must be a positive number, got %v", path, code, rate)
+		}
+	}
+	return rates, nil
+}
+
+// physicalUnitTable lists the non-currency units, the first unit of every dimension is canonical.
+var physicalUnitTable = []Unit{
+	{Symbol: "m", Dimension: DimensionLength, Factor: 1, Aliases: []string{"meter", "meters", "metre", "м"}},
+	{Symbol: "mm", Dimension: DimensionLength, Factor: 0.001, Aliases: []string{"мм"}},
+	{Symbol: "cm", Dimension: DimensionLength, Factor: 0.01, Aliases: []string{"см"}},
+	{Symbol: "km", Dimension: DimensionLength, Factor: 1000, Aliases: []string{"км"}},
+	{Symbol: "in", Dimension: DimensionLength, Factor: 0.0254, Aliases: []string{"inch", "inches"}},
+	{Symbol: "ft", Dimension: DimensionLength, Factor: 0.3048, Aliases: []string{"foot", "feet"}},
+	{Symbol: "mi", Dimension: DimensionLength, Factor: 1609.344, Aliases: []string{"mile", "miles"}},
+
+	{Symbol: "m/s", Dimension: DimensionSpeed, Factor: 1, Aliases: []string{"mps", "м/с"}},
+	{Symbol: "km/h", Dimension: DimensionSpeed, Factor: 1000.0 / 3600, Aliases: []string{"kmh", "kph", "км/ч"}},
+	{Symbol: "mph", Dimension: DimensionSpeed, Factor: 0.44704},
+	{Symbol: "kn", Dimension: DimensionSpeed, Factor: 1852.0 / 3600, Aliases: []string{"knot", "knots"}},
+
+	{Symbol: "kg", Dimension: DimensionMass, Factor: 1, Aliases: []string{"кг"}},
+	{Symbol: "mg", Dimension: DimensionMass, Factor: 1e-6, Aliases: []string{"мг"}},
+	{Symbol: "g", Dimension: DimensionMass, Factor: 0.001, Aliases: []string{"г"}},
+	{Symbol: "t", Dimension: DimensionMass, Factor: 1000, Aliases: []string{"ton", "tons", "т"}},
+	{Symbol: "lb", Dimension: DimensionMass, Factor: 0.45359237, Aliases: []string{"lbs", "pound", "pounds"}},
+
+	{Symbol: "s", Dimension: DimensionTime, Factor: 1, Aliases: []string{"sec", "second", "seconds", "с"}},
+	{Symbol: "ms", Dimension: DimensionTime, Factor: 0.001, Aliases: []string{"мс"}},
+	{Symbol: "min", Dimension: DimensionTime, Factor: 60, Aliases: []string{"minute", "minutes", "мин"}},
+	{Symbol: "h", Dimension: DimensionTime, Factor: 3600, Aliases: []string{"hour", "hours", "ч"}},
+	{Symbol: "d", Dimension: DimensionTime, Factor: 86400, Aliases: []string{"day", "days"}},
+
+	{Symbol: "B", Dimension: DimensionData, Factor: 1, Aliases: []string{"byte", "bytes"}},
+	{Symbol: "bit", Dimension: DimensionData, Factor: 0.125, Aliases: []string{"bits"}},
+	{Symbol: "KB", Dimension: DimensionData, Factor: 1e3},
+	{Symbol: "MB", Dimension: DimensionData, Factor: 1e6},
+	{Symbol: "GB", Dimension: DimensionData, Factor: 1e9},
+	{Symbol: "TB", Dimension: DimensionData, Factor: 1e12},
+	{Symbol: "KiB", Dimension: DimensionData, Factor: 1 << 10},
+	{Symbol: "MiB", Dimension: DimensionData, Factor: 1 << 20},
+	{Symbol: "GiB", Dimension: DimensionData, Factor: 1 << 30},
+	{Symbol: "TiB", Dimension: DimensionData, Factor: 1 << 40},
+}
+
+// UnitRegistry resolves unit names and converts quantities, offline.
+type UnitRegistry struct {
+	units     map[string]Unit
+	canonical map[Dimension]Unit
+}
+
+func NewUnitRegistry(rates CurrencyRates) *UnitRegistry {
+	registry := &UnitRegistry{units: map[string]Unit{}, canonical: map[Dimension]Unit{}}
+	for _, unit := range physicalUnitTable {
+		registry.add(unit)
+	}
+
+	base := strings.ToLower(rates.Base)
+	registry.add(Unit{Symbol: base, Dimension: DimensionCurrency, Factor: 1})
+	codes := make([]string, 0, len(rates.Rates))
+	for code := range rates.Rates {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	for _, code := range codes {
+		// the factor is read with the code as written in the rates, USD and usd alike
+		if symbol := strings.ToLower(code); symbol != base {
+			registry.add(Unit{Symbol: symbol, Dimension: DimensionCurrency, Factor: rates.Rates[code]})
+		}
+	}
+	return registry
+}
+
+// DefaultUnitRegistry uses defaultCurrencyRates.
+func DefaultUnitRegistry() *UnitRegistry {
+	return NewUnitRegistry(defaultCurrencyRates)
+}
+
+func (r *UnitRegistry) add(unit Unit) {
+	if _, ok := r.canonical[unit.Dimension]; !ok {
+		r.canonical[unit.Dimension] = unit
+	}
+	r.units[unit.Symbol] = unit
+	for _, alias := range append([]string{strings.ToLower(unit.Symbol)}, unit.Aliases...) {
+		if _, taken := r.units[alias]; !taken {
+			r.units[alias] = unit
+		}
+	}
+}
+
+// Lookup finds a unit by symbol or alias, exactly first and then case-insensitively.
+func (r *UnitRegistry) Lookup(name string) (Unit, bool) {
+	name = strings.TrimSpace(name)
+	if unit, ok := r.units[name]; ok {
+		return unit, true
+	}
+	unit, ok := r.units[strings.ToLower(name)]
+	return unit, ok
+}
+
+// Names returns every symbol and alias the registry knows.
+func (r *UnitRegistry) Names() []string {
+	names := make([]string, 0, len(r.units))
+	for name := range r.units {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Parse reads value in units. value may carry the unit itself ("360 km/h") when units is empty,
+// and may use spaces as thousands separators or a decimal comma.
+func (r *UnitRegistry) Parse(value, units string) (Quantity, error) {
+	value = strings.TrimSpace(value)
+	if units == "" {
+		if i := strings.LastIndexAny(value, "0123456789"); i >= 0 && i < len(value)-1 {
+			value, units = value[:i+1], value[i+1:]
+		}
+	}
+	unit, ok := r.Lookup(units)
+	if !ok {
+		return Quantity{}, fmt.Errorf("unknown unit %q", units)
+	}
+	number, err := parseNumber(value)
+	if err != nil {
+		return Quantity{}, err
+	}
+	return Quantity{Value: number, Unit: unit.Symbol, Dimension: unit.Dimension}, nil
+}
+
+// Convert expresses q in the unit named to, which must have the same dimension.
+func (r *UnitRegistry) Convert(q Quantity, to string) (Quantity, error) {
+	from, ok := r.Lookup(q.Unit)
+	if !ok {
+		return Quantity{}, fmt.Errorf("unknown unit %q", q.Unit)
+	}
+	target, ok := r.Lookup(to)
+	if !ok {
+		return Quantity{}, fmt.Errorf("unknown unit %q", to)
+	}
+	if from.Dimension != target.Dimension {
+		return Quantity{}, fmt.Errorf("can't convert %s (%s) to %s (%s)", from.Symbol, from.Dimension, target.Symbol, target.Dimension)
+	}
+	return Quantity{Value: q.Value * from.Factor / target.Factor, Unit: target.Symbol, Dimension: target.Dimension}, nil
+}
+
+// Normalize converts q into the canonical unit of its dimension.
+func (r *UnitRegistry) Normalize(q Quantity) (Quantity, error) {
+	canonical, ok := r.canonical[q.Dimension]
+	if !ok {
+		return Quantity{}, fmt.Errorf("unknown dimension %q", q.Dimension)
+	}
+	return r.Convert(q, canonical.Symbol)
+}
+
+// NormalizeResponse rewrites value1 and value1Units of every item into canonical units and
+// validates the result against schema again. Items with unknown units (e.g. "bash code") or
+// non-numeric values are left as they are.
+func (r *UnitRegistry) NormalizeResponse(schema *ResponseSchema, resp StructuredResponse) (StructuredResponse, error) {
+	var doc map[string]any
+	if err := json.Unmarshal(resp.Raw, &doc); err != nil {
+		return resp, fmt.Errorf("normalize units: %w", err)
+	}
+	items, _ := doc["items"].([]any)
+
+	changed := false
+	for _, raw := range items {
+		item, ok := raw.(map[string]any)
+		if !ok {
+			continue
+		}
+		q, err := r.Parse(fieldString(item, "value1"), fieldString(item, "value1Units"))
+		if err != nil {
+			continue
+		}
+		normalized, err := r.Normalize(q)
+		if err != nil {
+			continue
+		}
+		value := strconv.FormatFloat(normalized.Value, 'f', -1, 64)
+		if _, isNumber := item["value1"].(float64); isNumber {
+			item["value1"] = normalized.Value
+		} else {
+			item["value1"] = value
+		}
+		item["value1Units"] = normalized.Unit
+		changed = true
+	}
+	if !changed {
+		return resp, nil
+	}
+
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		return resp, fmt.Errorf("normalize units: %w", err)
+	}
+	normalized, err := schema.Parse(raw)
+	if err != nil {
+		return resp, fmt.Errorf("normalize units: %w", err)
+	}
+	return normalized, nil
+}
+
+// Quantity parses Value1 in Value1Units.
+func (item ZRspItem) Quantity(r *UnitRegistry) (Quantity, error) {
+	return r.Parse(item.Value1, item.Value1Units)
+}
+
+// parseNumber accepts "1000", "1 000", "1_000", "7.62" and "7,62". A comma followed by three digits,
+// like "1,000", may be a thousands separator or a decimal comma and is rejected, as are NaN and Inf.
+func parseNumber(s string) (float64, error) {
+	s = strings.NewReplacer(" ", "", "_", "", "\u00a0", "").Replace(strings.TrimSpace(s))
+	if !strings.Contains(s, ".") && strings.Count(s, ",") == 1 {
+		if _, decimals, _ := strings.Cut(s, ","); len(decimals) == 3 {
+			return 0, fmt.Errorf("%q is ambiguous, write it without the comma or with a decimal point", s)
+		}
+		s = strings.Replace(s, ",", ".", 1)
+	}
+	number, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(number) || math.IsInf(number, 0) {
+		return 0, fmt.Errorf("%q is not a number", s)
+	}
+	return number, nil
+}
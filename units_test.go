@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseNumber(t *testing.T) {
+	tests := []struct {
+		in   string
+		want float64
+		err  bool
+	}{
+		{in: "1000", want: 1000},
+		{in: " 1 000 ", want: 1000},
+		{in: "1_000_000", want: 1e6},
+		{in: "1 000", want: 1000},
+		{in: "7.62", want: 7.62},
+		{in: "7,62", want: 7.62},
+		{in: "-3", want: -3},
+		{in: "1,000.5", err: true},
+		{in: "1,000", err: true},
+		{in: "-2,500", err: true},
+		{in: "1,0", want: 1},
+		{in: "0,1234", want: 0.1234},
+		{in: "NaN", err: true},
+		{in: "Inf", err: true},
+		{in: "-Infinity", err: true},
+		{in: "1e400", err: true},
+		{in: "fast", err: true},
+		{in: "", err: true},
+	}
+	for _, tt := range tests {
+		got, err := parseNumber(tt.in)
+		if (err != nil) != tt.err {
+			t.Errorf("parseNumber(%q) error = %v, want error %t", tt.in, err, tt.err)
+			continue
+		}
+		if !tt.err && got != tt.want {
+			t.Errorf("parseNumber(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestUnitRegistryNormalize(t *testing.T) {
+	registry := DefaultUnitRegistry()
+	tests := []struct {
+		value, units string
+		want         Quantity
+		err          bool
+	}{
+		{value: "360", units: "km/h", want: Quantity{Value: 100, Unit: "m/s", Dimension: DimensionSpeed}},
+		{value: "360 km/h", want: Quantity{Value: 100, Unit: "m/s", Dimension: DimensionSpeed}},
+		{value: "2", units: "KM", want: Quantity{Value: 2000, Unit: "m", Dimension: DimensionLength}},
+		{value: "1,5", units: "ч", want: Quantity{Value: 5400, Unit: "s", Dimension: DimensionTime}},
+		{value: "1", units: "KiB", want: Quantity{Value: 1024, Unit: "B", Dimension: DimensionData}},
+		{value: "100", units: "EUR", want: Quantity{Value: 108, Unit: "usd", Dimension: DimensionCurrency}},
+		{value: "rm -rf", units: "bash code", err: true},
+		{value: "fast", units: "km/h", err: true},
+	}
+	for _, tt := range tests {
+		q, err := registry.Parse(tt.value, tt.units)
+		if err == nil {
+			q, err = registry.Normalize(q)
+		}
+		if (err != nil) != tt.err {
+			t.Errorf("%q %q: error = %v, want error %t", tt.value, tt.units, err, tt.err)
+			continue
+		}
+		if tt.err {
+			continue
+		}
+		if math.Abs(q.Value-tt.want.Value) > 1e-9 || q.Unit != tt.want.Unit || q.Dimension != tt.want.Dimension {
+			t.Errorf("%q %q: got %+v, want %+v", tt.value, tt.units, q, tt.want)
+		}
+	}
+}
+
+func TestUnitRegistryConvertAcrossDimensions(t *testing.T) {
+	registry := DefaultUnitRegistry()
+	if _, err := registry.Convert(Quantity{Value: 1, Unit: "km", Dimension: DimensionLength}, "kg"); err == nil {
+		t.Error("converting km to kg succeeded")
+	}
+	if _, err := registry.Convert(Quantity{Value: 1, Unit: "parsec"}, "m"); err == nil {
+		t.Error("converting an unknown unit succeeded")
+	}
+}
+
+func TestNewUnitRegistryUppercaseRates(t *testing.T) {
+	registry := NewUnitRegistry(CurrencyRates{Base: "USD", Rates: map[string]float64{"USD": 1, "EUR": 1.08, "Chf": 1.1}})
+	tests := []struct {
+		value, units string
+		want         float64
+	}{
+		{value: "100", units: "EUR", want: 108},
+		{value: "100", units: "eur", want: 108},
+		{value: "10", units: "CHF", want: 11},
+		{value: "5", units: "usd", want: 5},
+	}
+	for _, tt := range tests {
+		q, err := registry.Parse(tt.value, tt.units)
+		if err != nil {
+			t.Fatalf("%s %s: %v", tt.value, tt.units, err)
+		}
+		q, err = registry.Normalize(q)
+		if err != nil {
+			t.Fatalf("%s %s: %v", tt.value, tt.units, err)
+		}
+		if math.Abs(q.Value-tt.want) > 1e-9 || q.Unit != "usd" {
+			t.Errorf("%s %s = %v, want %v usd", tt.value, tt.units, q, tt.want)
+		}
+	}
+
+	toEUR, err := registry.Convert(Quantity{Value: 108, Unit: "usd", Dimension: DimensionCurrency}, "EUR")
+	if err != nil || math.Abs(toEUR.Value-100) > 1e-9 {
+		t.Errorf("108 usd in EUR = %v, %v, want 100", toEUR, err)
+	}
+}
+
+func TestLoadCurrencyRates(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		err  string
+	}{
+		{name: "valid", json: `{"base":"USD","rates":{"EUR":1.08}}`},
+		{name: "no base", json: `{"rates":{"eur":1.08}}`, err: "base currency is empty"},
+		{name: "zero rate", json: `{"base":"usd","rates":{"eur":0}}`, err: "rate of eur must be a positive number"},
+		{name: "negative rate", json: `{"base":"usd","rates":{"eur":-1}}`, err: "rate of eur must be a positive number"},
+		{name: "not json", json: `base: usd`, err: "parse rates"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "rates.json")
+			if err := os.WriteFile(path, []byte(tt.json), 0644); err != nil {
+				t.Fatal(err)
+			}
+			_, err := LoadCurrencyRates(path)
+			if tt.err == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("error = %v, want it to contain %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestZRspRulesAcceptRatesCurrencies(t *testing.T) {
+	schema := MustDefaultResponseSchema()
+	resp, err := schema.Parse([]byte(`{"items":[{"itemType":"car","itemName":"TT-34","value1Name":"cost","value1Units":"CHF","value1":"1000"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defaultVerdict, err := NewRuleInspector("zrsp", defaultZRspRules).Inspect(context.Background(), resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if defaultVerdict.Approved {
+		t.Error("built-in rules accepted CHF, which is not a built-in currency")
+	}
+
+	units := NewUnitRegistry(CurrencyRates{Base: "USD", Rates: map[string]float64{"CHF": 1.1}})
+	verdict, err := NewRuleInspector("zrsp", ZRspRules(units)).Inspect(context.Background(), resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !verdict.Approved {
+		t.Errorf("rules built from the -rates registry rejected CHF: %v", verdict.Issues)
+	}
+}
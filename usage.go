@@ -0,0 +1,496 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Agents that usage is recorded for.
+const (
+	usageAgentInterviewer = "interviewer"
+	usageAgentInspector   = "inspector"
+	usageAgentDigest      = "digest"
+	usageAgentTestgen     = "testgen"
+)
+
+// Sources of UsageRecord.CostSource.
+const (
+	// CostSourceProvider is a cost reported by the provider, e.g. OpenRouter.
+	CostSourceProvider = "provider"
+	// CostSourcePrice is a cost computed from the price table.
+	CostSourcePrice = "price"
+	// CostSourceUnknown means the model has no price, the cost is counted as 0.
+	CostSourceUnknown = "unknown"
+)
+
+// Periods of UsageBudget.
+const (
+	BudgetPeriodRun     = "run"
+	BudgetPeriodDay     = "day"
+	BudgetPeriodSession = "session"
+)
+
+var ErrBudgetExceeded = errors.New("usage budget exceeded")
+
+// UsageRecord is one completion in the usage log.
+type UsageRecord struct {
+	Time    time.Time `json:"time"`
+	Agent   string    `json:"agent"`
+	Session string    `json:"session,omitempty"`
+	// Model is the model that answered.
+	Model            string  `json:"model"`
+	PromptTokens     int     `json:"promptTokens"`
+	CompletionTokens int     `json:"completionTokens"`
+	TotalTokens      int     `json:"totalTokens"`
+	Cost             float64 `json:"cost"`
+	CostSource       string  `json:"costSource"`
+	// Price is the price the cost was computed from, nil for provider and unknown costs.
+	Price *ModelPrice `json:"price,omitempty"`
+	// DowngradedFrom is set when a budget replaced the requested model.
+	DowngradedFrom string `json:"downgradedFrom,omitempty"`
+}
+
+// ModelPrice is the price of a model in USD per million tokens.
+type ModelPrice struct {
+	Prompt     float64 `json:"prompt"`
+	Completion float64 `json:"completion"`
+}
+
+func (p ModelPrice) cost(usage ChatUsage) float64 {
+	return (float64(usage.PromptTokens)*p.Prompt + float64(usage.CompletionTokens)*p.Completion) / 1e6
+}
+
+// UsagePrices maps model names to prices. OpenRouter ":free" models cost nothing.
+type UsagePrices map[string]ModelPrice
+
+func (prices UsagePrices) lookup(model string) (ModelPrice, bool) {
+	if price, ok := prices[model]; ok {
+		return price, true
+	}
+	if strings.HasSuffix(model, ":free") {
+		return ModelPrice{}, true
+	}
+	return ModelPrice{}, false
+}
+
+// LoadUsagePrices reads a JSON object of model name to {"prompt": ..., "completion": ...} in USD per million tokens.
+func LoadUsagePrices(path string) (UsagePrices, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read prices: %w", err)
+	}
+	var prices UsagePrices
+	if err := json.Unmarshal(data, &prices); err != nil {
+		return nil, fmt.Errorf("parse prices %s: %w", path, err)
+	}
+	return prices, nil
+}
+
+// UsageBudget caps the spending of an agent, or of all agents together with agent "*".
+type UsageBudget struct {
+	Agent string `json:"agent"`
+	// Period is run (this process), day (the calendar day, earlier runs included) or session.
+	Period    string  `json:"period"`
+	MaxCost   float64 `json:"maxCost,omitempty"`
+	MaxTokens int     `json:"maxTokens,omitempty"`
+	// Downgrade is the model used once the budget is exceeded. Without it calls fail with ErrBudgetExceeded.
+	Downgrade string `json:"downgrade,omitempty"`
+}
+
+func (b UsageBudget) String() string {
+	limits := []string{}
+	if b.MaxCost > 0 {
+		limits = append(limits, fmt.Sprintf("$%.4f", b.MaxCost))
+	}
+	if b.MaxTokens > 0 {
+		limits = append(limits, fmt.Sprintf("%d tokens", b.MaxTokens))
+	}
+	return fmt.Sprintf("%s per %s %s", b.Agent, b.Period, strings.Join(limits, " / "))
+}
+
+func (b UsageBudget) exceeded(spent usageTotals) bool {
+	return (b.MaxCost > 0 && spent.Cost >= b.MaxCost) || (b.MaxTokens > 0 && spent.TotalTokens >= b.MaxTokens)
+}
+
+// LoadUsageBudgets reads {"budgets": [...]}.
+func LoadUsageBudgets(path string) ([]UsageBudget, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read budgets: %w", err)
+	}
+	var cfg struct {
+		Budgets []UsageBudget `json:"budgets"`
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parse budgets %s: %w", path, err)
+	}
+	for i, budget := range cfg.Budgets {
+		switch budget.Period {
+		case BudgetPeriodRun, BudgetPeriodDay, BudgetPeriodSession:
+		default:
+			return nil, fmt.Errorf("budget %d: unknown period %q, expected %s, %s or %s", i+1, budget.Period, BudgetPeriodRun, BudgetPeriodDay, BudgetPeriodSession)
+		}
+		if budget.Agent == "" {
+			return nil, fmt.Errorf("budget %d: agent is empty, use * for all agents", i+1)
+		}
+		if budget.MaxCost <= 0 && budget.MaxTokens <= 0 {
+			return nil, fmt.Errorf("budget %d: set maxCost or maxTokens", i+1)
+		}
+	}
+	return cfg.Budgets, nil
+}
+
+// usageTotals adds up UsageRecords.
+type usageTotals struct {
+	Calls            int
+	PromptTokens     int
+	CompletionTokens int
+	TotalTokens      int
+	Cost             float64
+	// UnknownCost counts calls of models without a price.
+	UnknownCost int
+}
+
+func (t *usageTotals) add(record UsageRecord) {
+	t.Calls++
+	t.PromptTokens += record.PromptTokens
+	t.CompletionTokens += record.CompletionTokens
+	t.TotalTokens += record.TotalTokens
+	t.Cost += record.Cost
+	if record.CostSource == CostSourceUnknown {
+		t.UnknownCost++
+	}
+}
+
+// UsageConfig is shared by the ChatModelConfigs of a command, the tracker is opened by the first
+// model that needs it.
+type UsageConfig struct {
+	// Path is the usage log, empty keeps the usage of this run in memory only.
+	Path        string
+	PricesPath  string
+	BudgetsPath string
+
+	once    sync.Once
+	tracker *UsageTracker
+	err     error
+}
+
+// UsageLogFromEnv returns ADVENT_USAGE_LOG, or defaultUsageLog.
+func UsageLogFromEnv() string {
+	if path := os.Getenv("ADVENT_USAGE_LOG"); path != "" {
+		return path
+	}
+	return defaultUsageLog
+}
+
+const defaultUsageLog = "usage.jsonl"
+
+// Tracker opens the tracker once.
+func (c *UsageConfig) Tracker() (*UsageTracker, error) {
+	c.once.Do(func() {
+		c.tracker, c.err = OpenUsageTracker(c.Path, c.PricesPath, c.BudgetsPath)
+	})
+	return c.tracker, c.err
+}
+
+// Close prints the usage of this run to w and closes the usage log, if the tracker was opened.
+func (c *UsageConfig) Close(w io.Writer) error {
+	if c == nil || c.tracker == nil {
+		return nil
+	}
+	c.tracker.PrintRunReport(w)
+	return c.tracker.Close()
+}
+
+// UsageTracker prices completions, appends them to the usage log and enforces budgets.
+type UsageTracker struct {
+	prices  UsagePrices
+	budgets []UsageBudget
+
+	mu   sync.Mutex
+	file *os.File
+	run  []UsageRecord
+	// day and session totals per agent, day ones include earlier runs of the log
+	day      string
+	dayTotal map[string]usageTotals
+	sessions map[string]map[string]usageTotals
+	warned   map[string]bool
+}
+
+// OpenUsageTracker loads the prices and budgets, and the totals of today and of every session
+// from the usage log at path. Empty paths are skipped.
+func OpenUsageTracker(path, pricesPath, budgetsPath string) (*UsageTracker, error) {
+	tracker := &UsageTracker{
+		prices:   UsagePrices{},
+		day:      time.Now().Format(time.DateOnly),
+		dayTotal: map[string]usageTotals{},
+		sessions: map[string]map[string]usageTotals{},
+		warned:   map[string]bool{},
+	}
+	var err error
+	if pricesPath != "" {
+		if tracker.prices, err = LoadUsagePrices(pricesPath); err != nil {
+			return nil, err
+		}
+	}
+	if budgetsPath != "" {
+		if tracker.budgets, err = LoadUsageBudgets(budgetsPath); err != nil {
+			return nil, err
+		}
+	}
+	if path == "" {
+		return tracker, nil
+	}
+
+	records, err := ReadUsageLog(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	for _, record := range records {
+		tracker.count(record)
+	}
+	if tracker.file, err = os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644); err != nil {
+		return nil, fmt.Errorf("open usage log: %w", err)
+	}
+	return tracker, nil
+}
+
+// count adds record to the day and session totals, the caller holds mu or owns the tracker.
+func (t *UsageTracker) count(record UsageRecord) {
+	if record.Time.Local().Format(time.DateOnly) == t.day {
+		totals := t.dayTotal[record.Agent]
+		totals.add(record)
+		t.dayTotal[record.Agent] = totals
+	}
+	if record.Session != "" {
+		if t.sessions[record.Session] == nil {
+			t.sessions[record.Session] = map[string]usageTotals{}
+		}
+		totals := t.sessions[record.Session][record.Agent]
+		totals.add(record)
+		t.sessions[record.Session][record.Agent] = totals
+	}
+}
+
+// Check returns the model to downgrade agent to when a budget with a downgrade is exceeded,
+// or ErrBudgetExceeded when a budget without one is.
+func (t *UsageTracker) Check(agent, session string) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rollDay()
+
+	downgrade := ""
+	for _, budget := range t.budgets {
+		if budget.Agent != "*" && budget.Agent != agent {
+			continue
+		}
+		if !budget.exceeded(t.spent(budget, agent, session)) {
+			continue
+		}
+		if budget.Downgrade == "" {
+			return "", fmt.Errorf("%w: %s", ErrBudgetExceeded, budget)
+		}
+		if downgrade == "" {
+			downgrade = budget.Downgrade
+			if key := budget.String() + session; !t.warned[key] {
+				t.warned[key] = true
+				fmt.Printf("usage: budget %s exceeded, %s downgraded to %s\n", budget, agent, downgrade)
+			}
+		}
+	}
+	return downgrade, nil
+}
+
+func (t *UsageTracker) spent(budget UsageBudget, agent, session string) usageTotals {
+	var byAgent map[string]usageTotals
+	switch budget.Period {
+	case BudgetPeriodRun:
+		byAgent = map[string]usageTotals{}
+		for _, record := range t.run {
+			totals := byAgent[record.Agent]
+			totals.add(record)
+			byAgent[record.Agent] = totals
+		}
+	case BudgetPeriodDay:
+		byAgent = t.dayTotal
+	case BudgetPeriodSession:
+		if session == "" {
+			return usageTotals{}
+		}
+		byAgent = t.sessions[session]
+	}
+	if budget.Agent != "*" {
+		return byAgent[agent]
+	}
+	var all usageTotals
+	for _, totals := range byAgent {
+		all.Calls += totals.Calls
+		all.TotalTokens += totals.TotalTokens
+		all.Cost += totals.Cost
+	}
+	return all
+}
+
+// rollDay starts new day totals after midnight, the caller holds mu.
+func (t *UsageTracker) rollDay() {
+	if today := time.Now().Format(time.DateOnly); today != t.day {
+		t.day = today
+		t.dayTotal = map[string]usageTotals{}
+	}
+}
+
+// Record prices a completion and appends it to the usage log.
+func (t *UsageTracker) Record(record UsageRecord, usage ChatUsage) UsageRecord {
+	record.PromptTokens = usage.PromptTokens
+	record.CompletionTokens = usage.CompletionTokens
+	record.TotalTokens = usage.TotalTokens
+	if record.TotalTokens == 0 {
+		record.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	}
+	// a cost reported by the provider wins over the price table, it knows discounts and cached tokens
+	if price, ok := t.prices.lookup(record.Model); usage.CostReported {
+		record.Cost, record.CostSource = usage.Cost, CostSourceProvider
+	} else if ok {
+		record.Cost, record.CostSource, record.Price = price.cost(usage), CostSourcePrice, &price
+	} else {
+		record.CostSource = CostSourceUnknown
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rollDay()
+	t.run = append(t.run, record)
+	t.count(record)
+	if t.file != nil {
+		line, err := json.Marshal(record)
+		if err == nil {
+			_, err = t.file.Write(append(line, '\n'))
+		}
+		if err != nil {
+			fmt.Printf("usage log: %v\n", err)
+		}
+	}
+	return record
+}
+
+// PrintRunReport prints the usage of this run by agent and model.
+func (t *UsageTracker) PrintRunReport(w io.Writer) {
+	t.mu.Lock()
+	records := append([]UsageRecord(nil), t.run...)
+	t.mu.Unlock()
+	if len(records) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "\nusage of this run:")
+	PrintUsageReport(w, records, []string{"agent", "model"})
+}
+
+func (t *UsageTracker) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.file == nil {
+		return nil
+	}
+	return t.file.Close()
+}
+
+// ReadUsageLog parses a usage log, unparsable lines are skipped.
+func ReadUsageLog(path string) ([]UsageRecord, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var records []UsageRecord
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var record UsageRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			fmt.Printf("usage log %s line %d: %v, skipped\n", path, line, err)
+			continue
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
+
+// usageGroupKeys are the columns PrintUsageReport can group by.
+var usageGroupKeys = map[string]func(UsageRecord) string{
+	"agent":   func(r UsageRecord) string { return r.Agent },
+	"model":   func(r UsageRecord) string { return r.Model },
+	"session": func(r UsageRecord) string { return r.Session },
+	"day":     func(r UsageRecord) string { return r.Time.Local().Format(time.DateOnly) },
+}
+
+// PrintUsageReport prints totals of records grouped by the by columns, and a total line.
+func PrintUsageReport(w io.Writer, records []UsageRecord, by []string) {
+	groups := map[string]*usageTotals{}
+	labels := map[string][]string{}
+	var total usageTotals
+	for _, record := range records {
+		label := make([]string, len(by))
+		for i, column := range by {
+			if label[i] = usageGroupKeys[column](record); label[i] == "" {
+				label[i] = "-"
+			}
+		}
+		key := strings.Join(label, "\x00")
+		if groups[key] == nil {
+			groups[key] = &usageTotals{}
+			labels[key] = label
+		}
+		groups[key].add(record)
+		total.add(record)
+	}
+	keys := make([]string, 0, len(groups))
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	table := [][]string{append(append([]string(nil), by...), "calls", "prompt", "completion", "total", "cost")}
+	row := func(label []string, totals usageTotals) []string {
+		cost := fmt.Sprintf("$%.4f", totals.Cost)
+		if totals.UnknownCost > 0 {
+			cost += fmt.Sprintf(" (+%d unpriced)", totals.UnknownCost)
+		}
+		return append(append([]string(nil), label...), fmt.Sprint(totals.Calls), fmt.Sprint(totals.PromptTokens),
+			fmt.Sprint(totals.CompletionTokens), fmt.Sprint(totals.TotalTokens), cost)
+	}
+	for _, key := range keys {
+		table = append(table, row(labels[key], *groups[key]))
+	}
+	totalLabel := make([]string, len(by))
+	totalLabel[0] = "total"
+	table = append(table, row(totalLabel, total))
+
+	widths := make([]int, len(table[0]))
+	for _, cells := range table {
+		for i, cell := range cells {
+			widths[i] = max(widths[i], len(cell))
+		}
+	}
+	for _, cells := range table {
+		padded := make([]string, len(cells))
+		for i, cell := range cells {
+			if i >= len(by) {
+				padded[i] = fmt.Sprintf("%*s", widths[i], cell)
+			} else {
+				padded[i] = fmt.Sprintf("%-*s", widths[i], cell)
+			}
+		}
+		fmt.Fprintln(w, strings.TrimRight(strings.Join(padded, "  "), " "))
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// usageChatModel answers with usage, from the requested model or "paid" when none is set.
+type usageChatModel struct {
+	usage ChatUsage
+}
+
+func (m usageChatModel) Chat(_ context.Context, req ChatRequest) (ChatResponse, error) {
+	model := req.Model
+	if model == "" {
+		model = "paid"
+	}
+	return ChatResponse{Model: model, Usage: m.usage}, nil
+}
+
+func writeUsageFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestUsageTrackerRecordCost(t *testing.T) {
+	prices := writeUsageFile(t, "prices.json", `{"paid":{"prompt":2,"completion":10}}`)
+	tracker, err := OpenUsageTracker("", prices, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	usage := ChatUsage{PromptTokens: 1000, CompletionTokens: 100}
+	tests := []struct {
+		model      string
+		usage      ChatUsage
+		wantCost   float64
+		wantSource string
+	}{
+		{model: "paid", usage: usage, wantCost: 0.003, wantSource: CostSourcePrice},
+		{model: "paid", usage: ChatUsage{PromptTokens: 1000, Cost: 0.5, CostReported: true}, wantCost: 0.5, wantSource: CostSourceProvider},
+		{model: "qwen/qwen3-coder:free", usage: usage, wantCost: 0, wantSource: CostSourcePrice},
+		{model: "local", usage: usage, wantCost: 0, wantSource: CostSourceUnknown},
+	}
+	for _, tt := range tests {
+		record := tracker.Record(UsageRecord{Agent: usageAgentInterviewer, Model: tt.model}, tt.usage)
+		if record.Cost != tt.wantCost || record.CostSource != tt.wantSource || record.TotalTokens != tt.usage.PromptTokens+tt.usage.CompletionTokens {
+			t.Errorf("%s: %+v, want cost %v from %s", tt.model, record, tt.wantCost, tt.wantSource)
+		}
+	}
+}
+
+func TestMeteredChatModelBudgets(t *testing.T) {
+	budgets := writeUsageFile(t, "budgets.json", `{"budgets":[
+		{"agent":"interviewer","period":"session","maxTokens":150},
+		{"agent":"*","period":"run","maxTokens":250,"downgrade":"cheap:free"}
+	]}`)
+	tracker, err := OpenUsageTracker("", "", budgets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inner := usageChatModel{usage: ChatUsage{PromptTokens: 80, CompletionTokens: 20}}
+	interviewer := NewMeteredChatModel(inner, tracker, usageAgentInterviewer, "paid")
+	inspector := NewMeteredChatModel(inner, tracker, usageAgentInspector, "paid")
+	s1 := WithUsageSession(context.Background(), "s1")
+	s2 := WithUsageSession(context.Background(), "s2")
+
+	for range 2 {
+		if _, err := interviewer.Chat(s1, ChatRequest{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 200 tokens in s1: its session budget is spent, other sessions go on
+	if _, err := interviewer.Chat(s1, ChatRequest{}); !errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("third call of s1 = %v, want ErrBudgetExceeded", err)
+	}
+	resp, err := inspector.Chat(s1, ChatRequest{})
+	if err != nil || resp.Model != "paid" {
+		t.Errorf("inspector call = %v with %s, want the inspector unaffected by the interviewer budget", err, resp.Model)
+	}
+
+	// 300 tokens in this run: every agent is downgraded
+	resp, err = interviewer.Chat(s2, ChatRequest{})
+	if err != nil || resp.Model != "cheap:free" {
+		t.Fatalf("call past the run budget = %v with %s, want the downgrade", err, resp.Model)
+	}
+	if last := tracker.run[len(tracker.run)-1]; last.DowngradedFrom != "paid" || last.Session != "s2" {
+		t.Errorf("recorded %+v, want the downgrade from paid in s2", last)
+	}
+}
+
+func TestUsageTrackerDayBudgetCountsEarlierRuns(t *testing.T) {
+	log := filepath.Join(t.TempDir(), "usage.jsonl")
+	budgets := writeUsageFile(t, "budgets.json", `{"budgets":[{"agent":"digest","period":"day","maxCost":1}]}`)
+
+	earlier, err := OpenUsageTracker(log, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	earlier.Record(UsageRecord{Time: time.Now().Add(-48 * time.Hour), Agent: usageAgentDigest, Model: "paid"}, ChatUsage{Cost: 5, CostReported: true})
+	earlier.Record(UsageRecord{Time: time.Now(), Agent: usageAgentDigest, Model: "paid"}, ChatUsage{Cost: 0.6, CostReported: true})
+	earlier.Close()
+
+	tracker, err := OpenUsageTracker(log, "", budgets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tracker.Close()
+	if _, err := tracker.Check(usageAgentDigest, ""); err != nil {
+		t.Fatalf("Check = %v, want $0.60 of $1 spent today", err)
+	}
+	tracker.Record(UsageRecord{Time: time.Now(), Agent: usageAgentDigest, Model: "paid"}, ChatUsage{Cost: 0.4, CostReported: true})
+	if _, err := tracker.Check(usageAgentDigest, ""); !errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("Check = %v, want ErrBudgetExceeded", err)
+	}
+
+	records, err := ReadUsageLog(log)
+	if err != nil || len(records) != 3 {
+		t.Errorf("usage log has %d records, %v, want all 3", len(records), err)
+	}
+}
+
+func TestLoadUsageBudgetsErrors(t *testing.T) {
+	for _, budget := range []string{
+		`{"agent":"interviewer","period":"week","maxCost":1}`,
+		`{"period":"run","maxCost":1}`,
+		`{"agent":"interviewer","period":"run"}`,
+	} {
+		if _, err := LoadUsageBudgets(writeUsageFile(t, "budgets.json", `{"budgets":[`+budget+`]}`)); err == nil {
+			t.Errorf("budget %s loaded", budget)
+		}
+	}
+}
+
+func TestPrintUsageReport(t *testing.T) {
+	records := []UsageRecord{
+		{Agent: "inspector", Model: "b", PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15, Cost: 0.25, CostSource: CostSourcePrice},
+		{Agent: "interviewer", Model: "a", PromptTokens: 100, CompletionTokens: 20, TotalTokens: 120, CostSource: CostSourceUnknown},
+		{Agent: "interviewer", Model: "a", PromptTokens: 50, CompletionTokens: 10, TotalTokens: 60, Cost: 0.5, CostSource: CostSourceProvider},
+	}
+	var sb strings.Builder
+	PrintUsageReport(&sb, records, []string{"agent", "model"})
+	want := `agent        model  calls  prompt  completion  total                   cost
+inspector    b          1      10           5     15                $0.2500
+interviewer  a          2     150          30    180  $0.5000 (+1 unpriced)
+total                   3     160          35    195  $0.7500 (+1 unpriced)
+`
+	if sb.String() != want {
+		t.Errorf("report\n%s\nwant\n%s", sb.String(), want)
+	}
+}